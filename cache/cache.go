@@ -0,0 +1,193 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"pojok_baca_api/config"
+	"pojok_baca_api/database"
+	"pojok_baca_api/metrics"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// cache.go
+// This file provides the catalog response cache. Reads go through
+// GetOrLoad, which serves a cached JSON payload or loads it once (even
+// under concurrent misses) and stores it. Mutations invalidate exactly the
+// keys they affect.
+
+// Store is a key/value backend for cached payloads.
+type Store interface {
+	// Get returns the value and true on a hit, or false on a miss.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+	// DeletePrefix removes every key starting with prefix.
+	DeletePrefix(ctx context.Context, prefix string) error
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+// Cache combines a Store with stampede protection and hit/miss metrics.
+type Cache struct {
+	store Store
+	ttl   time.Duration
+	group singleflight.Group
+
+	// settle is how long after an invalidation loads are not stored, as
+	// they may read from a replica that has not caught up yet
+	settle time.Duration
+	// generation counts invalidations; lastInvalidation is the UnixNano
+	// time of the latest
+	generation       atomic.Uint64
+	lastInvalidation atomic.Int64
+}
+
+// loadTimeout bounds a load shared by concurrent misses
+const loadTimeout = 30 * time.Second
+
+// Default is the cache used by the catalog handlers. It is an in-process
+// LRU until Setup replaces it with the configured backend.
+var Default = New(NewLRU(1000), 5*time.Minute)
+
+// New creates a Cache on top of store. A nil store disables caching.
+func New(store Store, ttl time.Duration) *Cache {
+	return &Cache{store: store, ttl: ttl}
+}
+
+// Setup builds the cache described by cfg and installs it as Default.
+// The "none" backend disables caching. settle is the replication lag to
+// allow for after an invalidation (zero without read replicas).
+func Setup(cfg config.CacheConfig, settle time.Duration) *Cache {
+	var store Store
+	switch cfg.Backend {
+	case "memory":
+		store = NewLRU(cfg.MaxEntries)
+	case "redis":
+		store = NewRedis(cfg.RedisAddr, cfg.RedisPassword, cfg.RedisDB)
+	}
+	Default = New(store, cfg.TTL)
+	Default.settle = settle
+	return Default
+}
+
+// Enabled reports whether a backend is configured.
+func (c *Cache) Enabled() bool {
+	return c.store != nil
+}
+
+// Store returns the underlying backend (nil when caching is disabled).
+func (c *Cache) Store() Store {
+	return c.store
+}
+
+// GetOrLoad returns the cached JSON for key, or calls load, caches its JSON
+// encoding and returns it. Concurrent misses for the same key share a
+// single call to load. resource labels the hit/miss metrics (e.g. "books").
+//
+// The shared load runs with a context detached from the caller that started
+// it, bounded by loadTimeout, so one caller timing out does not fail the
+// others; each caller still stops waiting at its own deadline. Requests
+// pinned to the primary (read-your-writes) bypass the cache, and a load is
+// not stored when the cache was invalidated while it ran or shortly before,
+// when it may have read from a lagging replica.
+func (c *Cache) GetOrLoad(ctx context.Context, resource, key string, load func(ctx context.Context) (interface{}, error)) (json.RawMessage, error) {
+	if c.store == nil || database.PinnedToPrimary(ctx) {
+		return loadJSON(ctx, load)
+	}
+
+	if value, ok, err := c.store.Get(ctx, key); err != nil {
+		log.Printf("Cache get %s gagal: %v", key, err)
+	} else if ok {
+		metrics.CacheHits.WithLabelValues(resource).Inc()
+		return value, nil
+	}
+	metrics.CacheMisses.WithLabelValues(resource).Inc()
+
+	ch := c.group.DoChan(key, func() (interface{}, error) {
+		loadCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), loadTimeout)
+		defer cancel()
+
+		generation := c.generation.Load()
+		started := time.Now()
+		value, err := loadJSON(loadCtx, load)
+		if err != nil {
+			return nil, err
+		}
+		if c.fresh(generation, started) {
+			if err := c.store.Set(loadCtx, key, value, c.ttl); err != nil {
+				log.Printf("Cache set %s gagal: %v", key, err)
+			}
+		}
+		return value, nil
+	})
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case result := <-ch:
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		return result.Val.(json.RawMessage), nil
+	}
+}
+
+// fresh reports whether a load that started at started, when the
+// invalidation generation was generation, may be stored: no invalidation
+// happened since, nor within the settle window before it
+func (c *Cache) fresh(generation uint64, started time.Time) bool {
+	if c.generation.Load() != generation {
+		return false
+	}
+	last := c.lastInvalidation.Load()
+	return last == 0 || started.Sub(time.Unix(0, last)) >= c.settle
+}
+
+func loadJSON(ctx context.Context, load func(ctx context.Context) (interface{}, error)) (json.RawMessage, error) {
+	data, err := load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(data)
+}
+
+// Invalidate removes the given keys. Prefixed keys (ending in "*") remove
+// every key sharing that prefix. Errors are logged, not returned: a failed
+// invalidation only means data may stay stale until its TTL. Loads running
+// in this process are not stored afterwards; other instances sharing a
+// Redis backend only learn of it through the deleted keys.
+func (c *Cache) Invalidate(ctx context.Context, keys ...string) {
+	if c.store == nil {
+		return
+	}
+	c.generation.Add(1)
+	c.lastInvalidation.Store(time.Now().UnixNano())
+	var exact []string
+	for _, key := range keys {
+		if prefix, ok := strings.CutSuffix(key, "*"); ok {
+			if err := c.store.DeletePrefix(ctx, prefix); err != nil {
+				log.Printf("Cache invalidate %s gagal: %v", key, err)
+			}
+			continue
+		}
+		exact = append(exact, key)
+	}
+	if len(exact) > 0 {
+		if err := c.store.Delete(ctx, exact...); err != nil {
+			log.Printf("Cache invalidate %v gagal: %v", exact, err)
+		}
+	}
+}
+
+// Close releases the backend.
+func (c *Cache) Close() error {
+	if c.store == nil {
+		return nil
+	}
+	return c.store.Close()
+}
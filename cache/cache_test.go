@@ -0,0 +1,123 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"pojok_baca_api/database"
+)
+
+func TestGetOrLoadCachesUntilInvalidated(t *testing.T) {
+	c := New(NewLRU(10), time.Minute)
+	ctx := context.Background()
+
+	loads := 0
+	load := func(context.Context) (interface{}, error) {
+		loads++
+		return loads, nil
+	}
+	for i := 0; i < 2; i++ {
+		if value, err := c.GetOrLoad(ctx, "books", "books:id:1", load); err != nil || string(value) != "1" {
+			t.Fatalf("GetOrLoad = %s, %v; want 1", value, err)
+		}
+	}
+
+	c.Invalidate(ctx, BookKey(1))
+	if value, err := c.GetOrLoad(ctx, "books", "books:id:1", load); err != nil || string(value) != "2" {
+		t.Fatalf("GetOrLoad after Invalidate = %s, %v; want 2", value, err)
+	}
+}
+
+func TestGetOrLoadBypassesPrimaryPinnedRequests(t *testing.T) {
+	c := New(NewLRU(10), time.Minute)
+	ctx := context.Background()
+	if _, err := c.GetOrLoad(ctx, "books", "books:id:1", func(context.Context) (interface{}, error) { return "stale", nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	pinned := database.WithPrimary(ctx)
+	value, err := c.GetOrLoad(pinned, "books", "books:id:1", func(context.Context) (interface{}, error) { return "fresh", nil })
+	if err != nil || string(value) != `"fresh"` {
+		t.Fatalf("pinned GetOrLoad = %s, %v; want fresh", value, err)
+	}
+	if cached, _, _ := c.Store().Get(ctx, "books:id:1"); string(cached) != `"stale"` {
+		t.Errorf("pinned load replaced the cached value with %s", cached)
+	}
+}
+
+func TestGetOrLoadSkipsStoreAfterConcurrentInvalidation(t *testing.T) {
+	c := New(NewLRU(10), time.Minute)
+	ctx := context.Background()
+
+	// The book changes while it is being loaded
+	_, err := c.GetOrLoad(ctx, "books", "books:id:1", func(context.Context) (interface{}, error) {
+		c.Invalidate(ctx, BookKey(1))
+		return "old", nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := c.Store().Get(ctx, "books:id:1"); ok {
+		t.Error("a load overtaken by an invalidation was stored")
+	}
+}
+
+func TestGetOrLoadSkipsStoreWithinSettleWindow(t *testing.T) {
+	c := New(NewLRU(10), time.Minute)
+	c.settle = time.Hour
+	ctx := context.Background()
+
+	c.Invalidate(ctx, BookKey(1))
+	if _, err := c.GetOrLoad(ctx, "books", "books:id:1", func(context.Context) (interface{}, error) { return 1, nil }); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := c.Store().Get(ctx, "books:id:1"); ok {
+		t.Error("a load right after an invalidation was stored")
+	}
+}
+
+func TestGetOrLoadWaiterSurvivesFirstCallerTimeout(t *testing.T) {
+	c := New(NewLRU(10), time.Minute)
+	started := make(chan struct{})
+	var once sync.Once
+	release := make(chan struct{})
+	load := func(ctx context.Context) (interface{}, error) {
+		once.Do(func() { close(started) })
+		select {
+		case <-release:
+			return "book", nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	first, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := c.GetOrLoad(first, "books", "books:id:1", load)
+		firstErr <- err
+	}()
+	<-started
+
+	second := make(chan string, 1)
+	go func() {
+		value, err := c.GetOrLoad(context.Background(), "books", "books:id:1", load)
+		if err != nil {
+			second <- err.Error()
+			return
+		}
+		second <- string(value)
+	}()
+
+	cancel()
+	if err := <-firstErr; !errors.Is(err, context.Canceled) {
+		t.Fatalf("first caller error = %v, want context.Canceled", err)
+	}
+	close(release)
+	if got := <-second; got != `"book"` {
+		t.Fatalf("second caller got %s, want the shared load's value", got)
+	}
+}
@@ -0,0 +1,117 @@
+package cache
+
+import "strconv"
+
+// Cache keys of the catalog. List keys carry the normalized query string so
+// filtered lists are cached separately; mutations drop every list variant
+// through the "*" prefix form.
+
+// BookListKey is the key of the book list for the given query string.
+func BookListKey(query string) string {
+	return "books:list:" + query
+}
+
+// BookKey is the key of a single book.
+func BookKey(id int) string {
+	return "books:id:" + strconv.Itoa(id)
+}
+
+// BookISBNKey is the key of an ISBN lookup (normalized ISBN-13).
+func BookISBNKey(isbn string) string {
+	return "books:isbn:" + isbn
+}
+
+// BookReviewsKey is the key of the visible reviews of a book.
+func BookReviewsKey(id int) string {
+	return "reviews:book:" + strconv.Itoa(id)
+}
+
+// SimilarBooksKey is the key of the similar books of a book for the given
+// query string.
+func SimilarBooksKey(id int, query string) string {
+	return "books:similar:" + strconv.Itoa(id) + ":" + query
+}
+
+// AuthorListKey is the key of the author list for the given query string.
+func AuthorListKey(query string) string {
+	return "authors:list:" + query
+}
+
+// AuthorKey is the key of a single author.
+func AuthorKey(id int) string {
+	return "authors:id:" + strconv.Itoa(id)
+}
+
+// AuthorBooksKey is the key of the book list of an author.
+func AuthorBooksKey(id int) string {
+	return "authors:books:" + strconv.Itoa(id)
+}
+
+// PublisherListKey is the key of the publisher list for the given query string.
+func PublisherListKey(query string) string {
+	return "publishers:list:" + query
+}
+
+// PublisherKey is the key of a single publisher.
+func PublisherKey(id int) string {
+	return "publishers:id:" + strconv.Itoa(id)
+}
+
+// CategoryListAggregatesKey is the key of the category list with the
+// aggregates selected by the given query string (?include=).
+func CategoryListAggregatesKey(query string) string {
+	return "categories:aggregates:list:" + query
+}
+
+// CategoryAggregatesKey is the key of a single category with the aggregates
+// selected by the given query string (?include=).
+func CategoryAggregatesKey(id int, query string) string {
+	return "categories:aggregates:id:" + strconv.Itoa(id) + ":" + query
+}
+
+// TagListKey is the key of the tag cloud for the given query string.
+func TagListKey(query string) string {
+	return "tags:list:" + query
+}
+
+// EnrichmentKey is the key of a metadata lookup (see enrichment.Query.Key).
+func EnrichmentKey(query string) string {
+	return "enrichment:" + query
+}
+
+// CategoryListKey is the key of the category list for the given query string.
+func CategoryListKey(query string) string {
+	return "categories:list:" + query
+}
+
+// CategoryKey is the key of a single category.
+func CategoryKey(id int) string {
+	return "categories:id:" + strconv.Itoa(id)
+}
+
+const (
+	// AllBookLists matches every cached book list.
+	AllBookLists = "books:list:*"
+	// AllBookISBNs matches every cached ISBN lookup.
+	AllBookISBNs = "books:isbn:*"
+	// AllBooks matches every cached book, book list and ISBN lookup.
+	AllBooks = "books:*"
+	// AllSimilarBooks matches every cached similar books list.
+	AllSimilarBooks = "books:similar:*"
+	// PopularBooksKey is the key of the most popular books overall.
+	PopularBooksKey = "books:popular"
+	// AllAuthorLists matches every cached author list.
+	AllAuthorLists = "authors:list:*"
+	// AllAuthorBooks matches every cached author book list.
+	AllAuthorBooks = "authors:books:*"
+	// AllPublisherLists matches every cached publisher list.
+	AllPublisherLists = "publishers:list:*"
+	// CategoryTreeKey is the key of the nested category tree.
+	CategoryTreeKey = "categories:tree"
+	// AllCategoryAggregates matches every cached category with aggregates.
+	AllCategoryAggregates = "categories:aggregates:*"
+	// AllTagLists matches every cached tag cloud.
+	AllTagLists = "tags:list:*"
+	// AllCategoryLists matches every cached category list.
+	AllCategoryLists = "categories:list:*"
+)
@@ -0,0 +1,250 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis is a local stand-in speaking just enough of the Redis protocol
+// (RESP2) for the commands the Redis store sends
+type fakeRedis struct {
+	mu   sync.Mutex
+	data map[string][]byte
+	ttls map[string]time.Duration
+	ln   net.Listener
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeRedis{data: map[string][]byte{}, ttls: map[string]time.Duration{}, ln: ln}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { ln.Close() })
+	return f
+}
+
+func (f *fakeRedis) addr() string {
+	return f.ln.Addr().String()
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		f.handle(w, args)
+		if err := w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// readCommand reads one command sent as an array of bulk strings
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return nil, fmt.Errorf("unexpected %q", line)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func writeBulk(w *bufio.Writer, value string) {
+	fmt.Fprintf(w, "$%d\r\n%s\r\n", len(value), value)
+}
+
+func (f *fakeRedis) handle(w *bufio.Writer, args []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		w.WriteString("+PONG\r\n")
+	case "GET":
+		value, ok := f.data[args[1]]
+		if !ok {
+			w.WriteString("$-1\r\n")
+			return
+		}
+		writeBulk(w, string(value))
+	case "SET":
+		f.data[args[1]] = []byte(args[2])
+		delete(f.ttls, args[1])
+		if len(args) == 5 {
+			n, _ := strconv.Atoi(args[4])
+			switch strings.ToUpper(args[3]) {
+			case "EX":
+				f.ttls[args[1]] = time.Duration(n) * time.Second
+			case "PX":
+				f.ttls[args[1]] = time.Duration(n) * time.Millisecond
+			}
+		}
+		w.WriteString("+OK\r\n")
+	case "DEL":
+		deleted := 0
+		for _, key := range args[1:] {
+			if _, ok := f.data[key]; ok {
+				delete(f.data, key)
+				delete(f.ttls, key)
+				deleted++
+			}
+		}
+		fmt.Fprintf(w, ":%d\r\n", deleted)
+	case "SCAN":
+		// Every match is returned in one page with cursor 0
+		pattern := "*"
+		for i := 2; i+1 < len(args); i += 2 {
+			if strings.EqualFold(args[i], "MATCH") {
+				pattern = args[i+1]
+			}
+		}
+		var keys []string
+		for key := range f.data {
+			if ok, _ := path.Match(pattern, key); ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		w.WriteString("*2\r\n")
+		writeBulk(w, "0")
+		fmt.Fprintf(w, "*%d\r\n", len(keys))
+		for _, key := range keys {
+			writeBulk(w, key)
+		}
+	default:
+		// HELLO, CLIENT SETINFO, ...: the client falls back to RESP2 defaults
+		fmt.Fprintf(w, "-ERR unknown command '%s'\r\n", args[0])
+	}
+}
+
+func TestRedisStore(t *testing.T) {
+	fake := newFakeRedis(t)
+	store := NewRedis(fake.addr(), "", 0)
+	defer store.Close()
+	ctx := context.Background()
+
+	if err := store.Ping(ctx); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+
+	if _, ok, err := store.Get(ctx, "books:list:"); err != nil || ok {
+		t.Fatalf("Get on empty store = ok %v, err %v; want miss", ok, err)
+	}
+
+	if err := store.Set(ctx, "books:list:", []byte(`[1]`), time.Minute); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	value, ok, err := store.Get(ctx, "books:list:")
+	if err != nil || !ok || string(value) != `[1]` {
+		t.Fatalf("Get = %q, %v, %v; want [1]", value, ok, err)
+	}
+	fake.mu.Lock()
+	ttl := fake.ttls[keyPrefix+"books:list:"]
+	fake.mu.Unlock()
+	if ttl != time.Minute {
+		t.Errorf("stored TTL = %v, want 1m", ttl)
+	}
+
+	if err := store.Delete(ctx, "books:list:"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, ok, _ := store.Get(ctx, "books:list:"); ok {
+		t.Fatal("Get after Delete hit")
+	}
+
+	// More keys than one DEL batch, plus one that must survive
+	for i := 0; i < 250; i++ {
+		if err := store.Set(ctx, "books:id:"+strconv.Itoa(i), []byte("{}"), time.Minute); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+	}
+	if err := store.Set(ctx, "authors:id:1", []byte("{}"), time.Minute); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := store.DeletePrefix(ctx, "books:"); err != nil {
+		t.Fatalf("DeletePrefix: %v", err)
+	}
+	fake.mu.Lock()
+	remaining := len(fake.data)
+	fake.mu.Unlock()
+	if remaining != 1 {
+		t.Errorf("%d keys left after DeletePrefix, want 1", remaining)
+	}
+	if _, ok, _ := store.Get(ctx, "authors:id:1"); !ok {
+		t.Error("DeletePrefix removed a key outside the prefix")
+	}
+}
+
+func TestCacheOverRedis(t *testing.T) {
+	fake := newFakeRedis(t)
+	c := New(NewRedis(fake.addr(), "", 0), time.Minute)
+	defer c.Close()
+	ctx := context.Background()
+
+	loads := 0
+	load := func(context.Context) (interface{}, error) {
+		loads++
+		return []int{1, 2}, nil
+	}
+	for i := 0; i < 2; i++ {
+		value, err := c.GetOrLoad(ctx, "books", "books:list:", load)
+		if err != nil || string(value) != "[1,2]" {
+			t.Fatalf("GetOrLoad = %s, %v", value, err)
+		}
+	}
+	if loads != 1 {
+		t.Errorf("loaded %d times, want 1", loads)
+	}
+
+	c.Invalidate(ctx, AllBookLists)
+	if _, ok, _ := c.Store().Get(ctx, "books:list:"); ok {
+		t.Error("key still cached after Invalidate")
+	}
+}
@@ -0,0 +1,551 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// config.go
+// This file defines the typed application configuration and how it is
+// loaded. Values are resolved with the following precedence (highest first):
+//
+//  1. Real environment variables (or KEY_FILE pointing to a file, for Docker secrets)
+//  2. Variables from an optional .env file
+//  3. An optional YAML file named by CONFIG_FILE
+//  4. Built-in defaults
+//
+// Empty environment variables count as unset. Only YAML configuration files
+// are supported.
+
+// Config is the root configuration passed explicitly to every subsystem.
+type Config struct {
+	App      AppConfig      `yaml:"app"`
+	Database DatabaseConfig `yaml:"database"`
+	SMTP     SMTPConfig     `yaml:"smtp"`
+	Tracing  TracingConfig  `yaml:"tracing"`
+	Cache    CacheConfig    `yaml:"cache"`
+	Auth     AuthConfig     `yaml:"auth"`
+
+	Enrichment      EnrichmentConfig      `yaml:"enrichment"`
+	Recommendations RecommendationsConfig `yaml:"recommendations"`
+}
+
+// AppConfig holds HTTP server settings.
+type AppConfig struct {
+	Port string `yaml:"port"`
+	// ShutdownTimeout bounds how long in-flight requests may drain on shutdown.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	// PublicDir is the directory served under /public (book and category images).
+	PublicDir string `yaml:"public_dir"`
+}
+
+// DatabaseConfig holds the MariaDB connection settings.
+type DatabaseConfig struct {
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	Host     string `yaml:"host"`
+	Port     string `yaml:"port"`
+	Name     string `yaml:"name"`
+	// AutoMigrate runs pending schema migrations on startup.
+	AutoMigrate bool `yaml:"auto_migrate"`
+
+	// Connection pool tuning.
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"`
+
+	// ConnectRetries is how many times the startup ping is retried, with the
+	// delay doubling from ConnectRetryDelay up to ConnectRetryMaxDelay.
+	ConnectRetries       int           `yaml:"connect_retries"`
+	ConnectRetryDelay    time.Duration `yaml:"connect_retry_delay"`
+	ConnectRetryMaxDelay time.Duration `yaml:"connect_retry_max_delay"`
+
+	// QueryTimeout bounds every query issued by a request handler.
+	QueryTimeout time.Duration `yaml:"query_timeout"`
+
+	// ReplicaHosts lists optional read replicas as "host:port". They share
+	// the primary's user, password and database name.
+	ReplicaHosts []string `yaml:"replica_hosts"`
+	// ReplicaHealthInterval is how often replicas are pinged.
+	ReplicaHealthInterval time.Duration `yaml:"replica_health_interval"`
+	// ReadYourWritesWindow pins a client's reads to the primary for this long
+	// after it made a change, so it never sees stale data from a lagging replica.
+	ReadYourWritesWindow time.Duration `yaml:"read_your_writes_window"`
+}
+
+// DSN builds the MySQL/MariaDB Data Source Name of the primary:
+// "user:password@tcp(host:port)/dbname?param=value"
+func (d DatabaseConfig) DSN() string {
+	return d.dsnFor(net.JoinHostPort(d.Host, d.Port))
+}
+
+// ReplicaDSNs builds the Data Source Names of the configured read replicas.
+func (d DatabaseConfig) ReplicaDSNs() []string {
+	dsns := make([]string, 0, len(d.ReplicaHosts))
+	for _, addr := range d.ReplicaHosts {
+		dsns = append(dsns, d.dsnFor(addr))
+	}
+	return dsns
+}
+
+func (d DatabaseConfig) dsnFor(addr string) string {
+	return fmt.Sprintf("%s:%s@tcp(%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+		d.User, d.Password, addr, d.Name)
+}
+
+// SMTPConfig holds the outgoing mail settings used for password reset emails.
+// Mail sending is disabled when Host is empty.
+type SMTPConfig struct {
+	Host     string `yaml:"host"`
+	Port     string `yaml:"port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	From     string `yaml:"from"`
+}
+
+// Enabled reports whether an SMTP server is configured.
+func (s SMTPConfig) Enabled() bool {
+	return s.Host != ""
+}
+
+// TracingConfig holds the OpenTelemetry tracing settings.
+type TracingConfig struct {
+	// Exporter is "none" (default), "stdout" for local debugging or "otlp".
+	Exporter    string `yaml:"exporter"`
+	ServiceName string `yaml:"service_name"`
+	// OTLPEndpoint is the collector URL, e.g. http://otel-collector:4318. When
+	// empty, the standard OTEL_EXPORTER_OTLP_* variables are used.
+	OTLPEndpoint string `yaml:"otlp_endpoint"`
+	// SampleRatio is the fraction of new traces to record (0.0-1.0).
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+// CacheConfig holds the catalog response cache settings.
+type CacheConfig struct {
+	// Backend is "memory" (in-process LRU, default), "redis" or "none".
+	Backend    string        `yaml:"backend"`
+	TTL        time.Duration `yaml:"ttl"`
+	MaxEntries int           `yaml:"max_entries"`
+
+	RedisAddr     string `yaml:"redis_addr"`
+	RedisPassword string `yaml:"redis_password"`
+	RedisDB       int    `yaml:"redis_db"`
+}
+
+// AuthConfig holds the login session settings.
+type AuthConfig struct {
+	// SessionTTL is how long a bearer token issued at login stays valid.
+	SessionTTL time.Duration `yaml:"session_ttl"`
+}
+
+// EnrichmentConfig holds the book metadata providers used to pre-fill new
+// books from an ISBN or title.
+type EnrichmentConfig struct {
+	// Providers are queried in order; the first value found for a field wins.
+	// An empty list disables enrichment.
+	Providers []string      `yaml:"providers"`
+	Timeout   time.Duration `yaml:"timeout"`
+	// OpenLibraryURL and OpenLibraryCoversURL can point at a local fake in tests.
+	OpenLibraryURL       string `yaml:"openlibrary_url"`
+	OpenLibraryCoversURL string `yaml:"openlibrary_covers_url"`
+}
+
+// RecommendationsConfig holds the background job that precomputes similar
+// books for recommendations.
+type RecommendationsConfig struct {
+	// Interval is how often the similarities are recomputed.
+	Interval time.Duration `yaml:"interval"`
+	// MaxSimilar is how many similar books are kept per book.
+	MaxSimilar int `yaml:"max_similar"`
+}
+
+// Default returns the configuration used before any source is applied.
+func Default() *Config {
+	return &Config{
+		App: AppConfig{
+			Port:            "3000",
+			ShutdownTimeout: 10 * time.Second,
+			PublicDir:       "./public",
+		},
+		Database: DatabaseConfig{
+			Host:        "127.0.0.1",
+			Port:        "3306",
+			AutoMigrate: true,
+
+			MaxOpenConns:    25,
+			MaxIdleConns:    10,
+			ConnMaxLifetime: 5 * time.Minute,
+			ConnMaxIdleTime: time.Minute,
+
+			ConnectRetries:       10,
+			ConnectRetryDelay:    500 * time.Millisecond,
+			ConnectRetryMaxDelay: 15 * time.Second,
+
+			QueryTimeout: 5 * time.Second,
+
+			ReplicaHealthInterval: 5 * time.Second,
+			ReadYourWritesWindow:  5 * time.Second,
+		},
+		SMTP: SMTPConfig{
+			Port: "587",
+		},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			ServiceName: "pojok-baca-api",
+			SampleRatio: 1.0,
+		},
+		Cache: CacheConfig{
+			Backend:    "memory",
+			TTL:        5 * time.Minute,
+			MaxEntries: 1000,
+			RedisAddr:  "127.0.0.1:6379",
+		},
+		Auth: AuthConfig{
+			SessionTTL: 7 * 24 * time.Hour,
+		},
+		Enrichment: EnrichmentConfig{
+			Providers:            []string{"openlibrary"},
+			Timeout:              5 * time.Second,
+			OpenLibraryURL:       "https://openlibrary.org",
+			OpenLibraryCoversURL: "https://covers.openlibrary.org",
+		},
+		Recommendations: RecommendationsConfig{
+			Interval:   time.Hour,
+			MaxSimilar: 20,
+		},
+	}
+}
+
+// binding ties an environment variable to a configuration field.
+type binding struct {
+	key    string
+	target interface{}
+}
+
+// bindings lists every environment variable understood by the application.
+func (c *Config) bindings() []binding {
+	return []binding{
+		{"APP_PORT", &c.App.Port},
+		{"APP_SHUTDOWN_TIMEOUT", &c.App.ShutdownTimeout},
+		{"APP_PUBLIC_DIR", &c.App.PublicDir},
+
+		{"DB_USER", &c.Database.User},
+		{"DB_PASSWORD", &c.Database.Password},
+		{"DB_HOST", &c.Database.Host},
+		{"DB_PORT", &c.Database.Port},
+		{"DB_NAME", &c.Database.Name},
+		{"DB_AUTO_MIGRATE", &c.Database.AutoMigrate},
+		{"DB_MAX_OPEN_CONNS", &c.Database.MaxOpenConns},
+		{"DB_MAX_IDLE_CONNS", &c.Database.MaxIdleConns},
+		{"DB_CONN_MAX_LIFETIME", &c.Database.ConnMaxLifetime},
+		{"DB_CONN_MAX_IDLE_TIME", &c.Database.ConnMaxIdleTime},
+		{"DB_CONNECT_RETRIES", &c.Database.ConnectRetries},
+		{"DB_CONNECT_RETRY_DELAY", &c.Database.ConnectRetryDelay},
+		{"DB_CONNECT_RETRY_MAX_DELAY", &c.Database.ConnectRetryMaxDelay},
+		{"DB_QUERY_TIMEOUT", &c.Database.QueryTimeout},
+		{"DB_REPLICA_HOSTS", &c.Database.ReplicaHosts},
+		{"DB_REPLICA_HEALTH_INTERVAL", &c.Database.ReplicaHealthInterval},
+		{"DB_READ_YOUR_WRITES_WINDOW", &c.Database.ReadYourWritesWindow},
+
+		{"SMTP_HOST", &c.SMTP.Host},
+		{"SMTP_PORT", &c.SMTP.Port},
+		{"SMTP_USERNAME", &c.SMTP.Username},
+		{"SMTP_PASSWORD", &c.SMTP.Password},
+		{"SMTP_FROM", &c.SMTP.From},
+
+		{"OTEL_TRACES_EXPORTER", &c.Tracing.Exporter},
+		{"OTEL_SERVICE_NAME", &c.Tracing.ServiceName},
+		{"OTEL_EXPORTER_OTLP_ENDPOINT", &c.Tracing.OTLPEndpoint},
+		{"OTEL_TRACES_SAMPLER_ARG", &c.Tracing.SampleRatio},
+
+		{"CACHE_BACKEND", &c.Cache.Backend},
+		{"CACHE_TTL", &c.Cache.TTL},
+		{"CACHE_MAX_ENTRIES", &c.Cache.MaxEntries},
+		{"REDIS_ADDR", &c.Cache.RedisAddr},
+		{"REDIS_PASSWORD", &c.Cache.RedisPassword},
+		{"REDIS_DB", &c.Cache.RedisDB},
+
+		{"AUTH_SESSION_TTL", &c.Auth.SessionTTL},
+
+		{"ENRICHMENT_PROVIDERS", &c.Enrichment.Providers},
+		{"ENRICHMENT_TIMEOUT", &c.Enrichment.Timeout},
+		{"OPENLIBRARY_URL", &c.Enrichment.OpenLibraryURL},
+		{"OPENLIBRARY_COVERS_URL", &c.Enrichment.OpenLibraryCoversURL},
+
+		{"RECOMMENDATIONS_INTERVAL", &c.Recommendations.Interval},
+		{"RECOMMENDATIONS_MAX_SIMILAR", &c.Recommendations.MaxSimilar},
+	}
+}
+
+// Load reads the configuration from all sources and validates it.
+func Load() (*Config, error) {
+	// A missing .env is fine: in containers the real environment is used instead.
+	if err := godotenv.Load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("gagal membaca file .env: %w", err)
+	}
+
+	cfg := Default()
+
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.loadEnv(); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// loadFile overlays values from a YAML configuration file.
+func (c *Config) loadFile(path string) error {
+	// A TOML file would otherwise fail with a confusing YAML parse error
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		return fmt.Errorf("file konfigurasi %s: format TOML tidak didukung, gunakan YAML", path)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("gagal membaca file konfigurasi %s: %w", path, err)
+	}
+	if err := yaml.Unmarshal(raw, c); err != nil {
+		return fmt.Errorf("gagal mem-parsing file konfigurasi %s: %w", path, err)
+	}
+	return nil
+}
+
+// loadEnv overlays values from environment variables. For every KEY,
+// KEY_FILE may name a file whose trimmed content is used as the value.
+func (c *Config) loadEnv() error {
+	var errs []error
+	for _, b := range c.bindings() {
+		value, ok, err := lookup(b.key)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if err := assign(b.target, value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", b.key, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// lookup resolves KEY from the environment, falling back to KEY_FILE. An
+// empty KEY counts as unset, so a blank line such as "DB_PASSWORD=" in .env
+// does not hide KEY_FILE or the configuration file.
+func lookup(key string) (string, bool, error) {
+	if value := os.Getenv(key); value != "" {
+		return value, true, nil
+	}
+	path, ok := os.LookupEnv(key + "_FILE")
+	if !ok || path == "" {
+		return "", false, nil
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return "", false, fmt.Errorf("%s_FILE: gagal membaca %s: %w", key, path, err)
+	}
+	return strings.TrimSpace(string(raw)), true, nil
+}
+
+// assign parses value into the field pointed to by target.
+func assign(target interface{}, value string) error {
+	switch t := target.(type) {
+	case *string:
+		*t = value
+	case *int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("harus berupa bilangan bulat, didapat %q", value)
+		}
+		*t = n
+	case *float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("harus berupa angka, didapat %q", value)
+		}
+		*t = f
+	case *bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("harus berupa boolean, didapat %q", value)
+		}
+		*t = b
+	case *time.Duration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("harus berupa durasi (misal 30s, 5m), didapat %q", value)
+		}
+		*t = d
+	case *[]string:
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		*t = items
+	default:
+		return fmt.Errorf("tipe konfigurasi tidak didukung: %T", target)
+	}
+	return nil
+}
+
+// Validate checks that required values are present and well-formed,
+// reporting every problem at once.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if err := validatePort("APP_PORT", c.App.Port); err != nil {
+		errs = append(errs, err)
+	}
+	if c.App.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("APP_SHUTDOWN_TIMEOUT harus lebih besar dari 0"))
+	}
+
+	if c.App.PublicDir == "" {
+		errs = append(errs, errors.New("APP_PUBLIC_DIR wajib diisi"))
+	}
+
+	if c.Database.User == "" {
+		errs = append(errs, errors.New("DB_USER wajib diisi"))
+	}
+	if c.Database.Host == "" {
+		errs = append(errs, errors.New("DB_HOST wajib diisi"))
+	}
+	if c.Database.Name == "" {
+		errs = append(errs, errors.New("DB_NAME wajib diisi"))
+	}
+	if err := validatePort("DB_PORT", c.Database.Port); err != nil {
+		errs = append(errs, err)
+	}
+	if c.Database.MaxOpenConns < 1 {
+		errs = append(errs, errors.New("DB_MAX_OPEN_CONNS minimal 1"))
+	}
+	if c.Database.MaxIdleConns < 0 || c.Database.MaxIdleConns > c.Database.MaxOpenConns {
+		errs = append(errs, errors.New("DB_MAX_IDLE_CONNS harus di antara 0 dan DB_MAX_OPEN_CONNS"))
+	}
+	if c.Database.ConnectRetries < 0 {
+		errs = append(errs, errors.New("DB_CONNECT_RETRIES tidak boleh negatif"))
+	}
+	if c.Database.ConnectRetryDelay <= 0 || c.Database.ConnectRetryMaxDelay < c.Database.ConnectRetryDelay {
+		errs = append(errs, errors.New("DB_CONNECT_RETRY_DELAY harus > 0 dan tidak melebihi DB_CONNECT_RETRY_MAX_DELAY"))
+	}
+	if c.Database.QueryTimeout <= 0 {
+		errs = append(errs, errors.New("DB_QUERY_TIMEOUT harus lebih besar dari 0"))
+	}
+	for _, addr := range c.Database.ReplicaHosts {
+		if _, port, err := net.SplitHostPort(addr); err != nil {
+			errs = append(errs, fmt.Errorf("DB_REPLICA_HOSTS: %q harus berformat host:port", addr))
+		} else if err := validatePort("DB_REPLICA_HOSTS", port); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(c.Database.ReplicaHosts) > 0 && c.Database.ReplicaHealthInterval <= 0 {
+		errs = append(errs, errors.New("DB_REPLICA_HEALTH_INTERVAL harus lebih besar dari 0"))
+	}
+	if c.Database.ReadYourWritesWindow < 0 {
+		errs = append(errs, errors.New("DB_READ_YOUR_WRITES_WINDOW tidak boleh negatif"))
+	}
+
+	if c.SMTP.Enabled() {
+		if err := validatePort("SMTP_PORT", c.SMTP.Port); err != nil {
+			errs = append(errs, err)
+		}
+		if c.SMTP.From == "" {
+			errs = append(errs, errors.New("SMTP_FROM wajib diisi jika SMTP_HOST diatur"))
+		}
+		if c.SMTP.Username != "" && c.SMTP.Password == "" {
+			errs = append(errs, errors.New("SMTP_PASSWORD wajib diisi jika SMTP_USERNAME diatur"))
+		}
+	}
+
+	switch c.Tracing.Exporter {
+	case "none", "stdout", "console", "otlp":
+	default:
+		errs = append(errs, fmt.Errorf("OTEL_TRACES_EXPORTER harus none, stdout atau otlp, didapat %q", c.Tracing.Exporter))
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		errs = append(errs, fmt.Errorf("OTEL_TRACES_SAMPLER_ARG harus di antara 0 dan 1, didapat %v", c.Tracing.SampleRatio))
+	}
+
+	switch c.Cache.Backend {
+	case "none":
+	case "memory":
+		if c.Cache.MaxEntries < 1 {
+			errs = append(errs, errors.New("CACHE_MAX_ENTRIES minimal 1"))
+		}
+	case "redis":
+		if c.Cache.RedisAddr == "" {
+			errs = append(errs, errors.New("REDIS_ADDR wajib diisi jika CACHE_BACKEND=redis"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("CACHE_BACKEND harus memory, redis atau none, didapat %q", c.Cache.Backend))
+	}
+	if c.Cache.TTL <= 0 {
+		errs = append(errs, errors.New("CACHE_TTL harus lebih besar dari 0"))
+	}
+
+	if c.Auth.SessionTTL <= 0 {
+		errs = append(errs, errors.New("AUTH_SESSION_TTL harus lebih besar dari 0"))
+	}
+
+	for _, provider := range c.Enrichment.Providers {
+		switch provider {
+		case "openlibrary":
+			for _, setting := range []struct{ key, value string }{
+				{"OPENLIBRARY_URL", c.Enrichment.OpenLibraryURL},
+				{"OPENLIBRARY_COVERS_URL", c.Enrichment.OpenLibraryCoversURL},
+			} {
+				if u, err := url.Parse(setting.value); err != nil || u.Scheme == "" || u.Host == "" {
+					errs = append(errs, fmt.Errorf("%s harus berupa URL lengkap, didapat %q", setting.key, setting.value))
+				}
+			}
+		default:
+			errs = append(errs, fmt.Errorf("ENRICHMENT_PROVIDERS hanya mendukung openlibrary, didapat %q", provider))
+		}
+	}
+	if len(c.Enrichment.Providers) > 0 && c.Enrichment.Timeout <= 0 {
+		errs = append(errs, errors.New("ENRICHMENT_TIMEOUT harus lebih besar dari 0"))
+	}
+
+	if c.Recommendations.Interval <= 0 {
+		errs = append(errs, errors.New("RECOMMENDATIONS_INTERVAL harus lebih besar dari 0"))
+	}
+	if c.Recommendations.MaxSimilar < 1 {
+		errs = append(errs, errors.New("RECOMMENDATIONS_MAX_SIMILAR minimal 1"))
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("konfigurasi tidak valid:\n%w", errors.Join(errs...))
+}
+
+// validatePort checks that value is a TCP port number.
+func validatePort(key, value string) error {
+	port, err := strconv.Atoi(value)
+	if err != nil || port < 1 || port > 65535 {
+		return fmt.Errorf("%s harus berupa nomor port 1-65535, didapat %q", key, value)
+	}
+	return nil
+}
@@ -1,11 +1,20 @@
 package database
 
 import (
-	"database/sql" // Package untuk interaksi SQL generik
-	"fmt"          // Package untuk format string
-	"log"          // Package untuk logging error dan informasi
-	"os"           // Package untuk mengakses variabel lingkungan
+	"context"
+	"database/sql"        // Package untuk interaksi SQL generik
+	"database/sql/driver" // Tipe argumen driver untuk atribut span
+	"fmt"                 // Package untuk format pesan error
+	"log"                 // Package untuk logging error dan informasi
+	"time"                // Package untuk timeout dan jeda retry
 
+	"pojok_baca_api/config"  // Konfigurasi aplikasi yang sudah divalidasi
+	"pojok_baca_api/tracing" // Sanitasi statement SQL untuk span
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+
+	"github.com/XSAM/otelsql"         // Instrumentasi OpenTelemetry untuk database/sql
 	_ "github.com/go-sql-driver/mysql" // Driver MySQL untuk MariaDB. Gunakan underscore (_) karena kita hanya mengimpor efek samping (register driver)
 )
 
@@ -13,40 +22,77 @@ import (
 // Ini bisa diakses dari package lain yang mengimpor package database.
 var DB *sql.DB
 
+// queryTimeout adalah batas waktu default untuk setiap query dari handler.
+var queryTimeout = 5 * time.Second
+
 // ConnectDB bertanggung jawab untuk menginisialisasi dan membuka koneksi ke database.
-func ConnectDB() {
+// Konfigurasi koneksi diberikan secara eksplisit dari package config.
+// Ping awal diulang dengan jeda yang semakin lama (backoff) agar aplikasi tetap
+// bisa start walaupun database baru siap beberapa detik kemudian (misal di docker-compose).
+func ConnectDB(ctx context.Context, cfg config.DatabaseConfig) error {
 	var err error
 
-	// Membuat DSN (Data Source Name) string dari variabel lingkungan.
+	// Membuat DSN (Data Source Name) string dari konfigurasi database.
 	// Format DSN untuk MySQL/MariaDB: "user:password@tcp(host:port)/dbname?param=value"
-	// os.Getenv() digunakan untuk mengambil nilai dari file .env yang sudah Anda buat.
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
-		os.Getenv("DB_USER"),     // Mengambil nama pengguna DB dari .env
-		os.Getenv("DB_PASSWORD"), // Mengambil password DB dari .env (bisa kosong jika tidak ada password)
-		os.Getenv("DB_HOST"),     // Mengambil host DB dari .env
-		os.Getenv("DB_PORT"),     // Mengambil port DB dari .env
-		os.Getenv("DB_NAME"),     // Mengambil nama database dari .env
-	)
-
-	// Membuka koneksi database menggunakan driver "mysql".
-	// Jika ada error, akan dicatat dan aplikasi akan berhenti.
-	DB, err = sql.Open("mysql", dsn)
+	dsn := cfg.DSN()
+
+	// Membuka koneksi database menggunakan driver "mysql" yang dibungkus otelsql,
+	// sehingga setiap query yang membawa context menghasilkan span tracing.
+	DB, err = otelsql.Open("mysql", dsn, tracingOptions()...)
 	if err != nil {
-		log.Fatalf("Gagal membuka koneksi database: %v", err)
+		return fmt.Errorf("gagal membuka koneksi database: %w", err)
 	}
 
-	// Memverifikasi koneksi ke database dengan mengirimkan ping.
-	// Jika gagal ping, berarti koneksi tidak berhasil atau ada masalah jaringan/DB.
-	if err = DB.Ping(); err != nil {
-		log.Fatalf("Gagal melakukan ping ke database: %v", err)
+	// Mengatur pool koneksi. Tanpa batas, lonjakan request bisa membuka
+	// koneksi sebanyak-banyaknya dan melebihi max_connections di MariaDB.
+	DB.SetMaxOpenConns(cfg.MaxOpenConns)
+	DB.SetMaxIdleConns(cfg.MaxIdleConns)
+	DB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	DB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	queryTimeout = cfg.QueryTimeout
+
+	// Memverifikasi koneksi ke database dengan mengirimkan ping, diulang jika gagal.
+	// Jika semua percobaan gagal, berarti ada masalah jaringan/DB.
+	delay := cfg.ConnectRetryDelay
+	for attempt := 0; ; attempt++ {
+		pingCtx, cancel := context.WithTimeout(ctx, cfg.QueryTimeout)
+		err = DB.PingContext(pingCtx)
+		cancel()
+		if err == nil {
+			break
+		}
+		if attempt >= cfg.ConnectRetries {
+			return fmt.Errorf("gagal melakukan ping ke database setelah %d percobaan: %w", attempt+1, err)
+		}
+
+		log.Printf("Ping database gagal (percobaan %d/%d): %v. Mencoba lagi dalam %s...", attempt+1, cfg.ConnectRetries+1, err, delay)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+		if delay > cfg.ConnectRetryMaxDelay {
+			delay = cfg.ConnectRetryMaxDelay
+		}
 	}
 
 	log.Println("Berhasil terhubung ke MariaDB! 🥳")
+	return nil
+}
+
+// WithTimeout menurunkan context untuk query-query sebuah handler dari context
+// request (c.UserContext()), dibatasi oleh DB_QUERY_TIMEOUT. Jika waktu habis,
+// atau klien memutus koneksi (lihat CancelOnDisconnect), query yang sedang
+// berjalan dibatalkan oleh driver.
+func WithTimeout(parent context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(parent, queryTimeout)
 }
 
 // CloseDB bertanggung jawab untuk menutup koneksi database ketika aplikasi berhenti.
 // Ini penting untuk membebaskan sumber daya database.
 func CloseDB() {
+	closeReplicas()
 	if DB != nil {
 		err := DB.Close()
 		if err != nil {
@@ -55,4 +101,23 @@ func CloseDB() {
 			log.Println("Koneksi database ditutup. 👋")
 		}
 	}
-}
\ No newline at end of file
+}
+
+// tracingOptions mengatur span SQL: statement dicatat dalam bentuk yang sudah
+// disanitasi (tanpa literal) dan tidak pernah menyertakan nilai argumen query.
+func tracingOptions() []otelsql.Option {
+	return []otelsql.Option{
+		otelsql.WithAttributes(semconv.DBSystemMySQL),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{
+			DisableQuery:         true,
+			DisableErrSkip:       true,
+			OmitConnResetSession: true,
+		}),
+		otelsql.WithAttributesGetter(func(ctx context.Context, method otelsql.Method, query string, args []driver.NamedValue) []attribute.KeyValue {
+			if query == "" {
+				return nil
+			}
+			return []attribute.KeyValue{semconv.DBQueryText(tracing.SanitizeSQL(query))}
+		}),
+	}
+}
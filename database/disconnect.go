@@ -0,0 +1,47 @@
+package database
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// disconnect.go
+// Fiber (fasthttp) tidak membatalkan c.UserContext() ketika klien memutus
+// koneksi. Middleware di sini mengawasi koneksi selama handler berjalan dan
+// membatalkan context request begitu klien menutupnya, sehingga query yang
+// diturunkan lewat WithTimeout ikut dibatalkan oleh driver.
+
+// CancelOnDisconnect adalah middleware yang membatalkan c.UserContext() ketika
+// klien menutup koneksinya sebelum handler selesai. Context tidak dibatalkan
+// saat handler selesai, karena respons yang di-stream (ekspor) masih
+// memakainya setelah itu. Klien yang hanya menutup sisi kirim koneksinya
+// (half-close) juga dianggap sudah pergi.
+func CancelOnDisconnect() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, cancel := context.WithCancel(c.UserContext())
+		c.SetUserContext(ctx)
+
+		stop := watchConn(c.Context().Conn(), cancel)
+		defer stop()
+		return c.Next()
+	}
+}
+
+// watchConn memanggil onClose jika klien menutup conn, sampai stop dipanggil.
+// Koneksi yang tidak dapat diawasi (misalnya TLS) diabaikan.
+func watchConn(conn net.Conn, onClose func()) (stop func()) {
+	done := make(chan struct{})
+	if !watchClose(conn, onClose, done) {
+		return func() {}
+	}
+	return func() {
+		// Deadline yang sudah lewat menghentikan pengawas, lalu dikembalikan
+		// agar fasthttp dapat membaca request berikutnya di koneksi ini
+		conn.SetReadDeadline(time.Now())
+		<-done
+		conn.SetReadDeadline(time.Time{})
+	}
+}
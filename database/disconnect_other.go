@@ -0,0 +1,11 @@
+//go:build !unix
+
+package database
+
+import "net"
+
+// watchClose tidak didukung di platform ini: context request hanya berakhir
+// oleh batas waktu WithTimeout.
+func watchClose(conn net.Conn, onClose func(), done chan struct{}) bool {
+	return false
+}
@@ -0,0 +1,158 @@
+package database
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// tcpPair returns the server and client ends of a loopback TCP connection
+func tcpPair(t *testing.T) (net.Conn, net.Conn) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	return server, client
+}
+
+func TestWatchConnReportsClientClose(t *testing.T) {
+	server, client := tcpPair(t)
+	closed := make(chan struct{})
+	stop := watchConn(server, func() { close(closed) })
+	defer stop()
+
+	client.Close()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("closing the client was not reported")
+	}
+}
+
+func TestWatchConnStopKeepsConnUsable(t *testing.T) {
+	server, client := tcpPair(t)
+	stop := watchConn(server, func() { t.Error("open connection reported as closed") })
+	stop()
+
+	if _, err := client.Write([]byte("GET")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 3)
+	server.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.ReadFull(server, buf); err != nil || string(buf) != "GET" {
+		t.Fatalf("read after stop = %q, %v", buf, err)
+	}
+}
+
+func TestWatchConnLeavesPipelinedDataUnread(t *testing.T) {
+	server, client := tcpPair(t)
+	if _, err := client.Write([]byte("GET /next")); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	stop := watchConn(server, func() { t.Error("open connection reported as closed") })
+	stop()
+
+	buf := make([]byte, 9)
+	server.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.ReadFull(server, buf); err != nil || string(buf) != "GET /next" {
+		t.Fatalf("read after stop = %q, %v", buf, err)
+	}
+}
+
+func TestCancelOnDisconnect(t *testing.T) {
+	cancelled := make(chan struct{})
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Use(CancelOnDisconnect())
+	app.Get("/", func(c *fiber.Ctx) error {
+		select {
+		case <-c.UserContext().Done():
+			close(cancelled)
+		case <-time.After(5 * time.Second):
+		}
+		return nil
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go app.Listener(ln)
+	defer app.Shutdown()
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Write([]byte("GET / HTTP/1.1\r\nHost: test\r\n\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	// Let the handler start before the client goes away
+	time.Sleep(100 * time.Millisecond)
+	client.Close()
+
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("request context was not cancelled when the client disconnected")
+	}
+}
+
+func TestCancelOnDisconnectKeepsConnectionAlive(t *testing.T) {
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Use(CancelOnDisconnect())
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("ok")
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go app.Listener(ln)
+	defer app.Shutdown()
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	client.SetDeadline(time.Now().Add(5 * time.Second))
+	r := bufio.NewReader(client)
+	// Several requests on one keep-alive connection
+	for i := 0; i < 3; i++ {
+		if _, err := client.Write([]byte("GET / HTTP/1.1\r\nHost: test\r\n\r\n")); err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.ReadResponse(r, nil)
+		if err != nil {
+			t.Fatalf("request %d: %v", i+1, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || string(body) != "ok" {
+			t.Fatalf("request %d = %d %q", i+1, resp.StatusCode, body)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+//go:build unix
+
+package database
+
+import (
+	"errors"
+	"net"
+	"syscall"
+)
+
+// watchClose mulai mengawasi conn di goroutine terpisah dan menutup done
+// ketika pengawasan berakhir. Data diintip dengan MSG_PEEK, sehingga tidak
+// ada byte yang diambil dari fasthttp. Hasilnya false jika conn tidak
+// mendukung pengawasan.
+func watchClose(conn net.Conn, onClose func(), done chan struct{}) bool {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return false
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return false
+	}
+
+	go func() {
+		defer close(done)
+		closed := false
+		buf := make([]byte, 1)
+		// Read menunggu sampai koneksi dapat dibaca atau deadline lewat
+		raw.Read(func(fd uintptr) bool {
+			n, _, err := syscall.Recvfrom(int(fd), buf, syscall.MSG_PEEK)
+			for errors.Is(err, syscall.EINTR) {
+				n, _, err = syscall.Recvfrom(int(fd), buf, syscall.MSG_PEEK)
+			}
+			switch {
+			case errors.Is(err, syscall.EAGAIN):
+				return false
+			case err != nil, n == 0:
+				// EOF atau koneksi di-reset: klien sudah pergi
+				closed = true
+			}
+			// Data request berikutnya (pipelining): klien masih ada
+			return true
+		})
+		if closed {
+			onClose()
+		}
+	}()
+	return true
+}
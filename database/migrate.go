@@ -0,0 +1,175 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"sort"
+	"strings"
+)
+
+// migrate.go
+// File ini menjalankan migrasi skema yang disimpan di folder migrations/.
+// Setiap file bernama "<versi>_<deskripsi>.sql" dan dijalankan sekali saja;
+// versi yang sudah dijalankan dicatat di tabel schema_migrations.
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migration adalah satu file migrasi.
+type Migration struct {
+	Version string // Contoh: "0001"
+	Name    string // Nama file lengkap, contoh: "0001_initial_schema.sql"
+	SQL     string
+}
+
+// MigrationStatus merangkum status migrasi pada database.
+type MigrationStatus struct {
+	Applied []string `json:"applied"`
+	Pending []string `json:"pending"`
+}
+
+const createMigrationsTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version VARCHAR(32) PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`
+
+// Migrations mengembalikan semua migrasi yang tersedia, diurutkan berdasarkan versi.
+func Migrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []Migration
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".sql") {
+			continue
+		}
+		raw, err := migrationFiles.ReadFile("migrations/" + name)
+		if err != nil {
+			return nil, err
+		}
+		version := strings.SplitN(name, "_", 2)[0]
+		migrations = append(migrations, Migration{Version: version, Name: name, SQL: string(raw)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Migrate menjalankan semua migrasi yang belum dijalankan, masing-masing di dalam transaksi.
+// Catatan: MariaDB melakukan commit implisit untuk perintah DDL, jadi migrasi sebaiknya
+// ditulis idempoten (misal dengan IF NOT EXISTS).
+func Migrate(ctx context.Context, db *sql.DB) error {
+	if _, err := db.ExecContext(ctx, createMigrationsTable); err != nil {
+		return fmt.Errorf("gagal membuat tabel schema_migrations: %w", err)
+	}
+
+	status, err := GetMigrationStatus(ctx, db)
+	if err != nil {
+		return err
+	}
+	pending := make(map[string]bool, len(status.Pending))
+	for _, version := range status.Pending {
+		pending[version] = true
+	}
+
+	migrations, err := Migrations()
+	if err != nil {
+		return err
+	}
+	for _, m := range migrations {
+		if !pending[m.Version] {
+			continue
+		}
+		if err := applyMigration(ctx, db, m); err != nil {
+			return fmt.Errorf("migrasi %s gagal: %w", m.Name, err)
+		}
+		log.Printf("Migrasi %s berhasil dijalankan", m.Name)
+	}
+	return nil
+}
+
+func applyMigration(ctx context.Context, db *sql.DB, m Migration) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range splitStatements(m.SQL) {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, name) VALUES (?, ?)", m.Version, m.Name); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// splitStatements memecah isi file migrasi menjadi perintah-perintah SQL.
+// Setiap perintah harus diakhiri dengan titik koma di akhir baris.
+// Baris komentar ("-- ...") diabaikan.
+func splitStatements(script string) []string {
+	var statements []string
+	var current strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			stmt := strings.TrimSuffix(strings.TrimSpace(current.String()), ";")
+			statements = append(statements, stmt)
+			current.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+	return statements
+}
+
+// GetMigrationStatus membandingkan migrasi yang tersedia dengan yang sudah dijalankan.
+func GetMigrationStatus(ctx context.Context, db *sql.DB) (MigrationStatus, error) {
+	status := MigrationStatus{Applied: []string{}, Pending: []string{}}
+
+	applied := make(map[string]bool)
+	rows, err := db.QueryContext(ctx, "SELECT version FROM schema_migrations")
+	if err != nil {
+		return status, fmt.Errorf("gagal membaca schema_migrations: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var version string
+		if err := rows.Scan(&version); err != nil {
+			return status, err
+		}
+		applied[version] = true
+	}
+	if err := rows.Err(); err != nil {
+		return status, err
+	}
+
+	migrations, err := Migrations()
+	if err != nil {
+		return status, err
+	}
+	for _, m := range migrations {
+		if applied[m.Version] {
+			status.Applied = append(status.Applied, m.Version)
+		} else {
+			status.Pending = append(status.Pending, m.Version)
+		}
+	}
+	return status, nil
+}
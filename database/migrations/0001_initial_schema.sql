@@ -0,0 +1,39 @@
+-- Skema awal Pojok Baca. Menggunakan IF NOT EXISTS agar aman dijalankan
+-- pada database yang sudah dibuat secara manual sebelum ada migrasi.
+
+CREATE TABLE IF NOT EXISTS users (
+    user_id INT AUTO_INCREMENT PRIMARY KEY,
+    nama_lengkap VARCHAR(100) NOT NULL,
+    nim VARCHAR(20) NOT NULL UNIQUE,
+    email VARCHAR(100) NOT NULL UNIQUE,
+    password VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS categories (
+    category_id INT AUTO_INCREMENT PRIMARY KEY,
+    nama_kategori VARCHAR(100) NOT NULL,
+    image_url VARCHAR(255) NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS books (
+    book_id INT AUTO_INCREMENT PRIMARY KEY,
+    judul VARCHAR(255) NOT NULL,
+    penulis VARCHAR(255) NOT NULL,
+    penerbit VARCHAR(255) NOT NULL,
+    tahun_terbit INT NOT NULL,
+    sinopsis TEXT,
+    image_url VARCHAR(255) NOT NULL DEFAULT '',
+    category_id INT NOT NULL,
+    CONSTRAINT fk_books_category FOREIGN KEY (category_id) REFERENCES categories (category_id)
+);
+
+CREATE TABLE IF NOT EXISTS password_reset_codes (
+    code_id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    reset_code VARCHAR(6) NOT NULL,
+    expires_at TIMESTAMP NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_reset_codes_user FOREIGN KEY (user_id) REFERENCES users (user_id) ON DELETE CASCADE
+);
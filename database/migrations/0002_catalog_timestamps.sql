@@ -0,0 +1,11 @@
+-- Menambahkan waktu pembuatan dan perubahan pada buku dan kategori untuk
+-- ETag/Last-Modified dan optimistic concurrency (If-Match).
+-- Presisi mikrodetik agar dua perubahan dalam detik yang sama tetap berbeda.
+
+ALTER TABLE books
+    ADD COLUMN IF NOT EXISTS created_at TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6);
+
+ALTER TABLE categories
+    ADD COLUMN IF NOT EXISTS created_at TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6);
@@ -0,0 +1,7 @@
+-- Menambahkan ISBN pada buku. Disimpan sebagai ISBN-13 tanpa tanda hubung;
+-- NULL untuk buku tanpa ISBN sehingga indeks unik hanya berlaku pada yang terisi.
+
+ALTER TABLE books
+    ADD COLUMN IF NOT EXISTS isbn CHAR(13) NULL AFTER judul;
+
+CREATE UNIQUE INDEX IF NOT EXISTS ux_books_isbn ON books (isbn);
@@ -0,0 +1,53 @@
+-- Penulis sebagai entitas tersendiri: satu buku dapat memiliki beberapa
+-- penulis, penerjemah dan editor, dan satu penulis dapat memiliki halaman
+-- daftar bukunya sendiri. Kolom books.penulis tetap ada sebagai teks tampilan.
+
+CREATE TABLE IF NOT EXISTS authors (
+    author_id INT AUTO_INCREMENT PRIMARY KEY,
+    nama_penulis VARCHAR(255) NOT NULL,
+    bio TEXT NOT NULL DEFAULT '',
+    foto_url VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    updated_at TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6),
+    UNIQUE KEY ux_authors_nama (nama_penulis)
+);
+
+CREATE TABLE IF NOT EXISTS book_authors (
+    book_id INT NOT NULL,
+    author_id INT NOT NULL,
+    peran ENUM('author', 'translator', 'editor') NOT NULL DEFAULT 'author',
+    urutan SMALLINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (book_id, author_id, peran),
+    KEY ix_book_authors_author (author_id),
+    CONSTRAINT fk_book_authors_book FOREIGN KEY (book_id) REFERENCES books (book_id) ON DELETE CASCADE,
+    CONSTRAINT fk_book_authors_author FOREIGN KEY (author_id) REFERENCES authors (author_id)
+);
+
+-- Memecah teks penulis yang ada ("A, B & C", "A dan B", "A; B") menjadi
+-- data penulis. Nama dibandingkan tanpa membedakan huruf besar/kecil
+-- (collation default), sehingga penulis yang sama cukup satu baris.
+-- Maksimal 10 nama per buku.
+
+INSERT IGNORE INTO authors (nama_penulis)
+SELECT DISTINCT TRIM(SUBSTRING_INDEX(SUBSTRING_INDEX(p.nama, ',', n.n), ',', -1))
+FROM (
+    SELECT REPLACE(REPLACE(REPLACE(REPLACE(penulis, ';', ','), '&', ','), ' dan ', ','), ' and ', ',') AS nama
+    FROM books
+) p
+JOIN (
+    SELECT 1 AS n UNION ALL SELECT 2 UNION ALL SELECT 3 UNION ALL SELECT 4 UNION ALL SELECT 5
+    UNION ALL SELECT 6 UNION ALL SELECT 7 UNION ALL SELECT 8 UNION ALL SELECT 9 UNION ALL SELECT 10
+) n ON n.n <= 1 + LENGTH(p.nama) - LENGTH(REPLACE(p.nama, ',', ''))
+WHERE TRIM(SUBSTRING_INDEX(SUBSTRING_INDEX(p.nama, ',', n.n), ',', -1)) <> '';
+
+INSERT IGNORE INTO book_authors (book_id, author_id, peran, urutan)
+SELECT p.book_id, a.author_id, 'author', n.n - 1
+FROM (
+    SELECT book_id, REPLACE(REPLACE(REPLACE(REPLACE(penulis, ';', ','), '&', ','), ' dan ', ','), ' and ', ',') AS nama
+    FROM books
+) p
+JOIN (
+    SELECT 1 AS n UNION ALL SELECT 2 UNION ALL SELECT 3 UNION ALL SELECT 4 UNION ALL SELECT 5
+    UNION ALL SELECT 6 UNION ALL SELECT 7 UNION ALL SELECT 8 UNION ALL SELECT 9 UNION ALL SELECT 10
+) n ON n.n <= 1 + LENGTH(p.nama) - LENGTH(REPLACE(p.nama, ',', ''))
+JOIN authors a ON a.nama_penulis = TRIM(SUBSTRING_INDEX(SUBSTRING_INDEX(p.nama, ',', n.n), ',', -1));
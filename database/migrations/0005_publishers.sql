@@ -0,0 +1,25 @@
+-- Penerbit sebagai entitas tersendiri. books.penerbit tetap ada sebagai teks
+-- tampilan dan selalu sama dengan nama penerbit yang ditautkan, sehingga
+-- varian seperti "Gramedia" dan "gramedia" dapat digabung.
+
+CREATE TABLE IF NOT EXISTS publishers (
+    publisher_id INT AUTO_INCREMENT PRIMARY KEY,
+    nama_penerbit VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    updated_at TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6),
+    UNIQUE KEY ux_publishers_nama (nama_penerbit)
+);
+
+ALTER TABLE books
+    ADD COLUMN IF NOT EXISTS publisher_id INT NULL AFTER penerbit,
+    ADD CONSTRAINT fk_books_publisher FOREIGN KEY IF NOT EXISTS (publisher_id) REFERENCES publishers (publisher_id);
+
+-- Satu penerbit untuk setiap nama yang sudah ada. Nama dibandingkan tanpa
+-- membedakan huruf besar/kecil (collation default).
+
+INSERT IGNORE INTO publishers (nama_penerbit)
+SELECT DISTINCT TRIM(penerbit) FROM books WHERE TRIM(penerbit) <> '';
+
+UPDATE books b
+JOIN publishers p ON p.nama_penerbit = TRIM(b.penerbit)
+SET b.publisher_id = p.publisher_id, b.penerbit = p.nama_penerbit;
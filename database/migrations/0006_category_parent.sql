@@ -0,0 +1,7 @@
+-- Subkategori: kategori dapat memiliki kategori induk (misalnya "Fiksi
+-- Ilmiah" di bawah "Novel"). NULL untuk kategori tingkat teratas. Kategori
+-- yang masih memiliki subkategori tidak dapat dihapus.
+
+ALTER TABLE categories
+    ADD COLUMN IF NOT EXISTS parent_id INT NULL AFTER category_id,
+    ADD CONSTRAINT fk_categories_parent FOREIGN KEY IF NOT EXISTS (parent_id) REFERENCES categories (category_id);
@@ -0,0 +1,34 @@
+-- Satu buku dapat masuk ke beberapa kategori ("Sapiens": sejarah dan
+-- non-fiksi). books.category_id tetap menjadi kategori utama dan selalu ikut
+-- tercatat di book_categories.
+
+CREATE TABLE IF NOT EXISTS book_categories (
+    book_id INT NOT NULL,
+    category_id INT NOT NULL,
+    PRIMARY KEY (book_id, category_id),
+    KEY ix_book_categories_category (category_id),
+    CONSTRAINT fk_book_categories_book FOREIGN KEY (book_id) REFERENCES books (book_id) ON DELETE CASCADE,
+    CONSTRAINT fk_book_categories_category FOREIGN KEY (category_id) REFERENCES categories (category_id)
+);
+
+INSERT IGNORE INTO book_categories (book_id, category_id)
+SELECT book_id, category_id FROM books;
+
+-- Tag bebas per buku. Nama tag disimpan dalam huruf kecil.
+
+CREATE TABLE IF NOT EXISTS tags (
+    tag_id INT AUTO_INCREMENT PRIMARY KEY,
+    nama_tag VARCHAR(50) NOT NULL,
+    created_at TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    updated_at TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6),
+    UNIQUE KEY ux_tags_nama (nama_tag)
+);
+
+CREATE TABLE IF NOT EXISTS book_tags (
+    book_id INT NOT NULL,
+    tag_id INT NOT NULL,
+    PRIMARY KEY (book_id, tag_id),
+    KEY ix_book_tags_tag (tag_id),
+    CONSTRAINT fk_book_tags_book FOREIGN KEY (book_id) REFERENCES books (book_id) ON DELETE CASCADE,
+    CONSTRAINT fk_book_tags_tag FOREIGN KEY (tag_id) REFERENCES tags (tag_id) ON DELETE CASCADE
+);
@@ -0,0 +1,17 @@
+-- Peran pengguna dan sesi login. Setiap pengguna terdaftar adalah member;
+-- pustakawan diangkat secara manual:
+--   UPDATE users SET role = 'librarian' WHERE email = '...';
+
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS role ENUM('member', 'librarian') NOT NULL DEFAULT 'member' AFTER password;
+
+-- Token disimpan sebagai hash SHA-256, sehingga isi tabel ini tidak dapat
+-- dipakai untuk masuk
+CREATE TABLE IF NOT EXISTS user_sessions (
+    token_hash CHAR(64) NOT NULL PRIMARY KEY,
+    user_id INT NOT NULL,
+    expires_at TIMESTAMP(6) NOT NULL,
+    created_at TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    KEY ix_user_sessions_user (user_id),
+    CONSTRAINT fk_user_sessions_user FOREIGN KEY (user_id) REFERENCES users (user_id) ON DELETE CASCADE
+);
@@ -0,0 +1,19 @@
+-- Ulasan dan rating bintang (1-5) dari member. Satu ulasan per member per
+-- buku; ulasan yang disembunyikan pustakawan tidak ikut dihitung di rating.
+
+CREATE TABLE IF NOT EXISTS book_reviews (
+    review_id INT AUTO_INCREMENT PRIMARY KEY,
+    book_id INT NOT NULL,
+    user_id INT NOT NULL,
+    rating TINYINT NOT NULL,
+    ulasan TEXT NOT NULL,
+    hidden BOOLEAN NOT NULL DEFAULT FALSE,
+    flagged BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    updated_at TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6),
+    UNIQUE KEY ux_book_reviews_book_user (book_id, user_id),
+    KEY ix_book_reviews_user (user_id),
+    CONSTRAINT chk_book_reviews_rating CHECK (rating BETWEEN 1 AND 5),
+    CONSTRAINT fk_book_reviews_book FOREIGN KEY (book_id) REFERENCES books (book_id) ON DELETE CASCADE,
+    CONSTRAINT fk_book_reviews_user FOREIGN KEY (user_id) REFERENCES users (user_id) ON DELETE CASCADE
+);
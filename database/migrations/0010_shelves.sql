@@ -0,0 +1,43 @@
+-- Rak baca pribadi. Setiap pengguna punya tiga rak bawaan (ingin dibaca,
+-- sedang dibaca, selesai dibaca; sebuah buku hanya ada di salah satunya)
+-- dan rak buatan sendiri. Rak dapat dibagikan lewat slug.
+
+CREATE TABLE IF NOT EXISTS shelves (
+    shelf_id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    jenis ENUM('want_to_read', 'reading', 'finished', 'custom') NOT NULL DEFAULT 'custom',
+    nama_rak VARCHAR(100) NOT NULL,
+    is_public BOOLEAN NOT NULL DEFAULT FALSE,
+    slug VARCHAR(64) NULL,
+    created_at TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    updated_at TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6),
+    UNIQUE KEY ux_shelves_user_nama (user_id, nama_rak),
+    UNIQUE KEY ux_shelves_slug (slug),
+    CONSTRAINT fk_shelves_user FOREIGN KEY (user_id) REFERENCES users (user_id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS shelf_books (
+    shelf_id INT NOT NULL,
+    book_id INT NOT NULL,
+    added_at TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    PRIMARY KEY (shelf_id, book_id),
+    KEY ix_shelf_books_book (book_id),
+    CONSTRAINT fk_shelf_books_shelf FOREIGN KEY (shelf_id) REFERENCES shelves (shelf_id) ON DELETE CASCADE,
+    CONSTRAINT fk_shelf_books_book FOREIGN KEY (book_id) REFERENCES books (book_id) ON DELETE CASCADE
+);
+
+-- Kemajuan membaca per pengguna per buku, terlepas dari rak tempat buku itu
+-- berada
+CREATE TABLE IF NOT EXISTS reading_progress (
+    user_id INT NOT NULL,
+    book_id INT NOT NULL,
+    progress TINYINT NOT NULL DEFAULT 0,
+    started_on DATE NULL,
+    finished_on DATE NULL,
+    updated_at TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6),
+    PRIMARY KEY (user_id, book_id),
+    KEY ix_reading_progress_book (book_id),
+    CONSTRAINT chk_reading_progress_progress CHECK (progress BETWEEN 0 AND 100),
+    CONSTRAINT fk_reading_progress_user FOREIGN KEY (user_id) REFERENCES users (user_id) ON DELETE CASCADE,
+    CONSTRAINT fk_reading_progress_book FOREIGN KEY (book_id) REFERENCES books (book_id) ON DELETE CASCADE
+);
@@ -0,0 +1,24 @@
+-- Rekomendasi buku. user_book_views mencatat buku yang dibuka pengguna yang
+-- sedang masuk; book_similarities diisi ulang secara berkala oleh job latar
+-- belakang dari data tersebut dan dari kemiripan isi buku.
+
+CREATE TABLE IF NOT EXISTS user_book_views (
+    user_id INT NOT NULL,
+    book_id INT NOT NULL,
+    view_count INT NOT NULL DEFAULT 1,
+    last_viewed_at TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    PRIMARY KEY (user_id, book_id),
+    KEY ix_user_book_views_book (book_id),
+    CONSTRAINT fk_user_book_views_user FOREIGN KEY (user_id) REFERENCES users (user_id) ON DELETE CASCADE,
+    CONSTRAINT fk_user_book_views_book FOREIGN KEY (book_id) REFERENCES books (book_id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS book_similarities (
+    book_id INT NOT NULL,
+    similar_book_id INT NOT NULL,
+    score DOUBLE NOT NULL,
+    PRIMARY KEY (book_id, similar_book_id),
+    KEY ix_book_similarities_similar (similar_book_id),
+    CONSTRAINT fk_book_similarities_book FOREIGN KEY (book_id) REFERENCES books (book_id) ON DELETE CASCADE,
+    CONSTRAINT fk_book_similarities_similar FOREIGN KEY (similar_book_id) REFERENCES books (book_id) ON DELETE CASCADE
+);
@@ -0,0 +1,15 @@
+-- Token sekali pakai untuk mengganti password. Kode reset yang benar ditukar
+-- dengan token ini, dan password hanya dapat diganti dengan token yang masih
+-- berlaku. Seperti sesi, hanya hash SHA-256 token yang disimpan.
+--
+-- Password pengguna kini disimpan sebagai hash bcrypt. Password lama yang
+-- masih berupa teks biasa di-hash ulang saat pengguna berhasil masuk.
+
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+    token_hash CHAR(64) NOT NULL PRIMARY KEY,
+    user_id INT NOT NULL,
+    expires_at TIMESTAMP(6) NOT NULL,
+    created_at TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    KEY ix_password_reset_tokens_user (user_id),
+    CONSTRAINT fk_password_reset_tokens_user FOREIGN KEY (user_id) REFERENCES users (user_id) ON DELETE CASCADE
+);
@@ -0,0 +1,199 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"pojok_baca_api/config"
+
+	"github.com/XSAM/otelsql"
+	"github.com/gofiber/fiber/v2"
+)
+
+// replica.go
+// File ini mengatur read replica opsional. Query baca katalog diarahkan ke
+// replica yang sehat secara bergiliran (round-robin); jika tidak ada replica
+// yang sehat, query kembali ke primary (DB). Klien yang baru saja melakukan
+// perubahan dipaksa membaca dari primary selama beberapa detik (read-your-writes).
+
+// Replica adalah satu koneksi read replica beserta status kesehatannya.
+type Replica struct {
+	Name    string
+	DB      *sql.DB
+	healthy atomic.Bool
+}
+
+// Healthy melaporkan hasil health check terakhir replica ini.
+func (r *Replica) Healthy() bool {
+	return r.healthy.Load()
+}
+
+var (
+	// Replicas berisi semua read replica yang dikonfigurasi (boleh kosong).
+	Replicas []*Replica
+
+	nextReplica atomic.Uint64
+)
+
+type primaryKey struct{}
+
+// WithPrimary menandai context agar Reader selalu memakai primary.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// PinnedToPrimary melaporkan apakah context ditandai WithPrimary, misalnya oleh
+// ReadYourWrites untuk klien yang baru saja melakukan perubahan.
+func PinnedToPrimary(ctx context.Context) bool {
+	pinned, _ := ctx.Value(primaryKey{}).(bool)
+	return pinned
+}
+
+// Reader memilih koneksi untuk query baca (read-only). Replica yang sehat
+// dipilih bergiliran; primary dipakai jika context ditandai WithPrimary atau
+// tidak ada replica yang sehat.
+func Reader(ctx context.Context) *sql.DB {
+	if PinnedToPrimary(ctx) || len(Replicas) == 0 {
+		return DB
+	}
+	start := nextReplica.Add(1)
+	for i := range Replicas {
+		r := Replicas[(int(start)+i)%len(Replicas)]
+		if r.Healthy() {
+			return r.DB
+		}
+	}
+	return DB
+}
+
+// ConnectReplicas membuka koneksi ke setiap read replica. Replica yang belum
+// bisa di-ping tetap didaftarkan sebagai tidak sehat dan akan dicek ulang oleh
+// health check, sehingga replica yang mati tidak menghalangi aplikasi start.
+func ConnectReplicas(ctx context.Context, cfg config.DatabaseConfig) error {
+	for i, dsn := range cfg.ReplicaDSNs() {
+		db, err := otelsql.Open("mysql", dsn, tracingOptions()...)
+		if err != nil {
+			return fmt.Errorf("gagal membuka koneksi replica %s: %w", cfg.ReplicaHosts[i], err)
+		}
+		db.SetMaxOpenConns(cfg.MaxOpenConns)
+		db.SetMaxIdleConns(cfg.MaxIdleConns)
+		db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+		db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+		r := &Replica{Name: fmt.Sprintf("replica-%d", i+1), DB: db}
+		checkReplica(ctx, r)
+		Replicas = append(Replicas, r)
+	}
+	return nil
+}
+
+// RunReplicaHealthChecks melakukan ping ke setiap replica secara berkala
+// sampai ctx dibatalkan.
+func RunReplicaHealthChecks(ctx context.Context, interval time.Duration) {
+	if len(Replicas) == 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, r := range Replicas {
+				checkReplica(ctx, r)
+			}
+		}
+	}
+}
+
+func checkReplica(ctx context.Context, r *Replica) {
+	pingCtx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+	err := r.DB.PingContext(pingCtx)
+
+	healthy := err == nil
+	if was := r.healthy.Swap(healthy); was != healthy {
+		if healthy {
+			log.Printf("Replica %s kembali sehat", r.Name)
+		} else {
+			log.Printf("Replica %s tidak sehat, query baca dialihkan: %v", r.Name, err)
+		}
+	}
+}
+
+// closeReplicas menutup semua koneksi replica.
+func closeReplicas() {
+	for _, r := range Replicas {
+		if err := r.DB.Close(); err != nil {
+			log.Printf("Error saat menutup koneksi %s: %v", r.Name, err)
+		}
+	}
+	Replicas = nil
+}
+
+// writeTracker mencatat kapan terakhir kali setiap klien melakukan perubahan.
+type writeTracker struct {
+	mu        sync.Mutex
+	lastWrite map[string]time.Time
+}
+
+// recentlyWrote melaporkan apakah klien melakukan perubahan dalam window terakhir.
+func (t *writeTracker) recentlyWrote(client string, window time.Duration) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	at, ok := t.lastWrite[client]
+	return ok && time.Since(at) < window
+}
+
+// record mencatat perubahan oleh klien dan membuang catatan yang sudah kedaluwarsa.
+func (t *writeTracker) record(client string, window time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	t.lastWrite[client] = now
+	if len(t.lastWrite) > 1024 {
+		for key, at := range t.lastWrite {
+			if now.Sub(at) >= window {
+				delete(t.lastWrite, key)
+			}
+		}
+	}
+}
+
+// ReadYourWrites adalah middleware yang menjamin klien membaca tulisannya sendiri:
+// setelah request POST/PUT/PATCH/DELETE yang berhasil, semua query baca dari
+// klien yang sama diarahkan ke primary selama window. Klien dikenali dari
+// header Authorization jika ada, atau dari alamat IP.
+func ReadYourWrites(window time.Duration) fiber.Handler {
+	tracker := &writeTracker{lastWrite: make(map[string]time.Time)}
+	return func(c *fiber.Ctx) error {
+		if len(Replicas) == 0 || window <= 0 {
+			return c.Next()
+		}
+
+		client := c.Get(fiber.HeaderAuthorization)
+		if client == "" {
+			client = c.IP()
+		}
+
+		if tracker.recentlyWrote(client, window) {
+			c.SetUserContext(WithPrimary(c.UserContext()))
+		}
+
+		err := c.Next()
+
+		switch c.Method() {
+		case fiber.MethodPost, fiber.MethodPut, fiber.MethodPatch, fiber.MethodDelete:
+			if err == nil && c.Response().StatusCode() < fiber.StatusBadRequest {
+				tracker.record(client, window)
+			}
+		}
+		return err
+	}
+}
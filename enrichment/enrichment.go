@@ -0,0 +1,171 @@
+// Package enrichment looks up bibliographic metadata for a book from
+// external providers so librarians do not have to type it by hand.
+//
+// Providers are pluggable: each implements Provider, and a Service queries
+// them in order, keeping the first value found for every field. The result
+// is a Proposal the librarian can accept or reject field by field.
+package enrichment
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+
+	"pojok_baca_api/config"
+	"pojok_baca_api/metrics"
+	"pojok_baca_api/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// ErrNotFound is returned when no provider knows the book.
+var ErrNotFound = errors.New("metadata buku tidak ditemukan")
+
+// ErrDisabled is returned by Lookup when no provider is configured.
+var ErrDisabled = errors.New("enrichment tidak aktif")
+
+// Fields lists the book fields a proposal can fill, by their JSON name.
+var Fields = []string{"judul", "isbn", "penulis", "penerbit", "tahun_terbit", "sinopsis", "image_url"}
+
+// Query identifies the book to look up. ISBN is preferred when both are set.
+type Query struct {
+	ISBN  string `json:"isbn,omitempty"`
+	Title string `json:"title,omitempty"`
+}
+
+// Key identifies the query for caching.
+func (q Query) Key() string {
+	if q.ISBN != "" {
+		return "isbn:" + q.ISBN
+	}
+	return "title:" + strings.ToLower(strings.Join(strings.Fields(q.Title), " "))
+}
+
+// Metadata is what a provider knows about a book. Empty fields are unknown.
+type Metadata struct {
+	ISBN        string
+	Judul       string
+	Penulis     string
+	Penerbit    string
+	TahunTerbit int
+	Sinopsis    string
+	CoverURL    string
+}
+
+// Provider is a source of book metadata.
+type Provider interface {
+	// Name identifies the provider in proposals, logs and metrics.
+	Name() string
+	// Lookup returns the metadata of the book, or ErrNotFound.
+	Lookup(ctx context.Context, q Query) (*Metadata, error)
+}
+
+// Suggestion is a proposed value for one field and the provider it came from.
+type Suggestion struct {
+	Value  interface{} `json:"value"`
+	Source string      `json:"source"`
+}
+
+// Proposal holds the suggested value of every field some provider knew.
+type Proposal struct {
+	Query  Query                 `json:"query"`
+	Fields map[string]Suggestion `json:"fields"`
+}
+
+// Service queries the configured providers.
+type Service struct {
+	providers []Provider
+}
+
+// New creates a Service with the providers named in the configuration.
+func New(cfg config.EnrichmentConfig) *Service {
+	s := &Service{}
+	for _, name := range cfg.Providers {
+		switch name {
+		case "openlibrary":
+			s.providers = append(s.providers, NewOpenLibrary(cfg.OpenLibraryURL, cfg.OpenLibraryCoversURL, cfg.Timeout))
+		}
+	}
+	return s
+}
+
+// NewWithProviders creates a Service querying the given providers in order.
+func NewWithProviders(providers ...Provider) *Service {
+	return &Service{providers: providers}
+}
+
+// Enabled reports whether any provider is configured.
+func (s *Service) Enabled() bool {
+	return s != nil && len(s.providers) > 0
+}
+
+// Lookup asks every provider about the book and merges their answers. A
+// failing provider is skipped; Lookup only fails when all of them failed.
+func (s *Service) Lookup(ctx context.Context, q Query) (*Proposal, error) {
+	if !s.Enabled() {
+		return nil, ErrDisabled
+	}
+
+	proposal := &Proposal{Query: q, Fields: map[string]Suggestion{}}
+	var lastErr error
+	failed := 0
+	for _, p := range s.providers {
+		md, err := s.lookup(ctx, p, q)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			log.Printf("Enrichment %s gagal: %v", p.Name(), err)
+			lastErr = err
+			failed++
+			continue
+		}
+		proposal.merge(p.Name(), md)
+	}
+
+	if len(proposal.Fields) > 0 {
+		return proposal, nil
+	}
+	if failed == len(s.providers) {
+		return nil, lastErr
+	}
+	return nil, ErrNotFound
+}
+
+// lookup calls one provider inside a span and records the outcome.
+func (s *Service) lookup(ctx context.Context, p Provider, q Query) (*Metadata, error) {
+	ctx, span := tracing.Tracer("enrichment").Start(ctx, "enrichment.lookup")
+	span.SetAttributes(attribute.String("enrichment.provider", p.Name()))
+	defer span.End()
+
+	md, err := p.Lookup(ctx, q)
+	switch {
+	case err == nil:
+		metrics.EnrichmentLookups.WithLabelValues(p.Name(), "found").Inc()
+	case errors.Is(err, ErrNotFound):
+		metrics.EnrichmentLookups.WithLabelValues(p.Name(), "not_found").Inc()
+	default:
+		metrics.EnrichmentLookups.WithLabelValues(p.Name(), "error").Inc()
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return md, err
+}
+
+// merge adds the fields of md that no earlier provider filled.
+func (p *Proposal) merge(source string, md *Metadata) {
+	set := func(field string, value interface{}, known bool) {
+		if _, taken := p.Fields[field]; known && !taken {
+			p.Fields[field] = Suggestion{Value: value, Source: source}
+		}
+	}
+	set("judul", md.Judul, md.Judul != "")
+	set("isbn", md.ISBN, md.ISBN != "")
+	set("penulis", md.Penulis, md.Penulis != "")
+	set("penerbit", md.Penerbit, md.Penerbit != "")
+	set("tahun_terbit", md.TahunTerbit, md.TahunTerbit > 0)
+	set("sinopsis", md.Sinopsis, md.Sinopsis != "")
+	set("image_url", md.CoverURL, md.CoverURL != "")
+}
@@ -0,0 +1,76 @@
+package enrichment
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// stubProvider returns fixed metadata or an error
+type stubProvider struct {
+	name string
+	md   *Metadata
+	err  error
+}
+
+func (s stubProvider) Name() string { return s.name }
+
+func (s stubProvider) Lookup(context.Context, Query) (*Metadata, error) {
+	return s.md, s.err
+}
+
+func TestLookupMergesInProviderOrder(t *testing.T) {
+	s := NewWithProviders(
+		stubProvider{name: "broken", err: errors.New("timeout")},
+		stubProvider{name: "first", md: &Metadata{Judul: "Laskar Pelangi", TahunTerbit: 2005}},
+		stubProvider{name: "missing", err: ErrNotFound},
+		stubProvider{name: "second", md: &Metadata{Judul: "Laskar Pelangi (Edisi Baru)", Penulis: "Andrea Hirata", TahunTerbit: 2008}},
+	)
+
+	p, err := s.Lookup(context.Background(), Query{ISBN: "9789792248616"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]Suggestion{
+		"judul":        {Value: "Laskar Pelangi", Source: "first"},
+		"tahun_terbit": {Value: 2005, Source: "first"},
+		"penulis":      {Value: "Andrea Hirata", Source: "second"},
+	}
+	if len(p.Fields) != len(want) {
+		t.Errorf("fields = %v, want %v", p.Fields, want)
+	}
+	for field, suggestion := range want {
+		if p.Fields[field] != suggestion {
+			t.Errorf("%s = %+v, want %+v", field, p.Fields[field], suggestion)
+		}
+	}
+}
+
+func TestLookupFailures(t *testing.T) {
+	ctx := context.Background()
+	q := Query{Title: "Bumi Manusia"}
+
+	if _, err := NewWithProviders().Lookup(ctx, q); !errors.Is(err, ErrDisabled) {
+		t.Errorf("no providers: %v, want ErrDisabled", err)
+	}
+
+	failure := errors.New("status 503")
+	s := NewWithProviders(stubProvider{name: "a", err: failure}, stubProvider{name: "b", err: failure})
+	if _, err := s.Lookup(ctx, q); !errors.Is(err, failure) {
+		t.Errorf("all failing: %v, want the provider error", err)
+	}
+
+	s = NewWithProviders(stubProvider{name: "a", err: failure}, stubProvider{name: "b", err: ErrNotFound})
+	if _, err := s.Lookup(ctx, q); !errors.Is(err, ErrNotFound) {
+		t.Errorf("one failing, one not found: %v, want ErrNotFound", err)
+	}
+}
+
+func TestQueryKey(t *testing.T) {
+	if got := (Query{ISBN: "9789792248616", Title: "x"}).Key(); got != "isbn:9789792248616" {
+		t.Errorf("ISBN key = %q", got)
+	}
+	if got := (Query{Title: "  Bumi   MANUSIA "}).Key(); got != "title:bumi manusia" {
+		t.Errorf("title key = %q", got)
+	}
+}
@@ -0,0 +1,188 @@
+package enrichment
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OpenLibrary looks books up through the Open Library API. Any server
+// speaking the same API (for example a local fake in tests) can be used by
+// changing the base URLs.
+type OpenLibrary struct {
+	baseURL   string
+	coversURL string
+	client    *http.Client
+}
+
+// NewOpenLibrary creates an Open Library provider. baseURL serves /api/books
+// and /search.json; coversURL serves cover images.
+func NewOpenLibrary(baseURL, coversURL string, timeout time.Duration) *OpenLibrary {
+	return &OpenLibrary{
+		baseURL:   strings.TrimRight(baseURL, "/"),
+		coversURL: strings.TrimRight(coversURL, "/"),
+		client:    &http.Client{Timeout: timeout},
+	}
+}
+
+// Name implements Provider.
+func (o *OpenLibrary) Name() string {
+	return "openlibrary"
+}
+
+// Lookup implements Provider. ISBNs go through the Books API, titles through
+// the search API.
+func (o *OpenLibrary) Lookup(ctx context.Context, q Query) (*Metadata, error) {
+	if q.ISBN != "" {
+		return o.byISBN(ctx, q.ISBN)
+	}
+	if strings.TrimSpace(q.Title) != "" {
+		return o.byTitle(ctx, q.Title)
+	}
+	return nil, ErrNotFound
+}
+
+// olText is a string that Open Library sometimes wraps as {"type": ..., "value": ...}.
+type olText string
+
+func (t *olText) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*t = olText(s)
+		return nil
+	}
+	var wrapped struct {
+		Value string `json:"value"`
+	}
+	if err := json.Unmarshal(data, &wrapped); err != nil {
+		return err
+	}
+	*t = olText(wrapped.Value)
+	return nil
+}
+
+type olName struct {
+	Name string `json:"name"`
+}
+
+type olBook struct {
+	Title       string   `json:"title"`
+	Subtitle    string   `json:"subtitle"`
+	Authors     []olName `json:"authors"`
+	Publishers  []olName `json:"publishers"`
+	PublishDate string   `json:"publish_date"`
+	Notes       olText   `json:"notes"`
+	Excerpts    []struct {
+		Text string `json:"text"`
+	} `json:"excerpts"`
+	Cover struct {
+		Large  string `json:"large"`
+		Medium string `json:"medium"`
+	} `json:"cover"`
+}
+
+var yearPattern = regexp.MustCompile(`[0-9]{4}`)
+
+func (o *OpenLibrary) byISBN(ctx context.Context, isbn string) (*Metadata, error) {
+	params := url.Values{"bibkeys": {"ISBN:" + isbn}, "format": {"json"}, "jscmd": {"data"}}
+	var books map[string]olBook
+	if err := o.get(ctx, "/api/books?"+params.Encode(), &books); err != nil {
+		return nil, err
+	}
+	book, ok := books["ISBN:"+isbn]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	md := &Metadata{
+		ISBN:     isbn,
+		Judul:    book.Title,
+		Sinopsis: string(book.Notes),
+		CoverURL: book.Cover.Large,
+	}
+	if book.Subtitle != "" {
+		md.Judul += ": " + book.Subtitle
+	}
+	if len(book.Authors) > 0 {
+		md.Penulis = book.Authors[0].Name
+	}
+	if len(book.Publishers) > 0 {
+		md.Penerbit = book.Publishers[0].Name
+	}
+	md.TahunTerbit, _ = strconv.Atoi(yearPattern.FindString(book.PublishDate))
+	if md.Sinopsis == "" && len(book.Excerpts) > 0 {
+		md.Sinopsis = book.Excerpts[0].Text
+	}
+	if md.CoverURL == "" {
+		md.CoverURL = book.Cover.Medium
+	}
+	return md, nil
+}
+
+// byTitle looks a work up by title. The search merges every edition of the
+// work, so no ISBN is proposed: it could belong to another edition than the
+// year and publisher.
+func (o *OpenLibrary) byTitle(ctx context.Context, title string) (*Metadata, error) {
+	params := url.Values{
+		"title":  {title},
+		"limit":  {"1"},
+		"fields": {"title,author_name,publisher,first_publish_year,cover_i"},
+	}
+	var result struct {
+		Docs []struct {
+			Title            string   `json:"title"`
+			AuthorName       []string `json:"author_name"`
+			Publisher        []string `json:"publisher"`
+			FirstPublishYear int      `json:"first_publish_year"`
+			CoverID          int      `json:"cover_i"`
+		} `json:"docs"`
+	}
+	if err := o.get(ctx, "/search.json?"+params.Encode(), &result); err != nil {
+		return nil, err
+	}
+	if len(result.Docs) == 0 {
+		return nil, ErrNotFound
+	}
+
+	doc := result.Docs[0]
+	md := &Metadata{Judul: doc.Title, TahunTerbit: doc.FirstPublishYear}
+	if len(doc.AuthorName) > 0 {
+		md.Penulis = doc.AuthorName[0]
+	}
+	if len(doc.Publisher) > 0 {
+		md.Penerbit = doc.Publisher[0]
+	}
+	if doc.CoverID > 0 {
+		md.CoverURL = fmt.Sprintf("%s/b/id/%d-L.jpg", o.coversURL, doc.CoverID)
+	}
+	return md, nil
+}
+
+// get fetches path from the API and decodes the JSON response into v.
+func (o *OpenLibrary) get(ctx context.Context, path string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, o.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("openlibrary: status %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,144 @@
+package enrichment
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeOpenLibrary serves canned Open Library responses and records the
+// query of every request
+func fakeOpenLibrary(t *testing.T, routes map[string]string) (*OpenLibrary, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var queries []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		queries = append(queries, r.URL.RawQuery)
+		mu.Unlock()
+		body, ok := routes[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if body == "" {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return NewOpenLibrary(srv.URL+"/", "https://covers.example", time.Second), func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), queries...)
+	}
+}
+
+func TestOpenLibraryByISBN(t *testing.T) {
+	ol, queries := fakeOpenLibrary(t, map[string]string{
+		"/api/books": `{"ISBN:9789792248616": {
+			"title": "Laskar Pelangi", "subtitle": "Sebuah Novel",
+			"authors": [{"name": "Andrea Hirata"}, {"name": "Editor"}],
+			"publishers": [{"name": "Bentang Pustaka"}],
+			"publish_date": "Juni 2005",
+			"notes": {"type": "/type/text", "value": "Kisah sepuluh anak Belitung."},
+			"cover": {"medium": "https://covers.example/b/id/1-M.jpg"}
+		}}`,
+	})
+
+	md, err := ol.Lookup(context.Background(), Query{ISBN: "9789792248616", Title: "ignored"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Metadata{
+		ISBN:        "9789792248616",
+		Judul:       "Laskar Pelangi: Sebuah Novel",
+		Penulis:     "Andrea Hirata",
+		Penerbit:    "Bentang Pustaka",
+		TahunTerbit: 2005,
+		Sinopsis:    "Kisah sepuluh anak Belitung.",
+		CoverURL:    "https://covers.example/b/id/1-M.jpg",
+	}
+	if !reflect.DeepEqual(md, want) {
+		t.Errorf("Lookup =\n %+v\nwant\n %+v", md, want)
+	}
+	if want := "bibkeys=ISBN%3A9789792248616&format=json&jscmd=data"; queries()[0] != want {
+		t.Errorf("query = %q, want %q", queries()[0], want)
+	}
+}
+
+func TestOpenLibraryByISBNPlainNotesAndExcerpt(t *testing.T) {
+	ol, _ := fakeOpenLibrary(t, map[string]string{
+		"/api/books": `{
+			"ISBN:1": {"title": "A", "notes": "Catatan polos."},
+			"ISBN:2": {"title": "B", "excerpts": [{"text": "Kutipan."}], "cover": {"large": "L", "medium": "M"}}
+		}`,
+	})
+
+	md, err := ol.Lookup(context.Background(), Query{ISBN: "1"})
+	if err != nil || md.Sinopsis != "Catatan polos." {
+		t.Errorf("plain notes: %+v, %v", md, err)
+	}
+	md, err = ol.Lookup(context.Background(), Query{ISBN: "2"})
+	if err != nil || md.Sinopsis != "Kutipan." || md.CoverURL != "L" {
+		t.Errorf("excerpt and large cover: %+v, %v", md, err)
+	}
+}
+
+func TestOpenLibraryByTitle(t *testing.T) {
+	ol, queries := fakeOpenLibrary(t, map[string]string{
+		"/search.json": `{"docs": [{
+			"title": "Bumi Manusia", "author_name": ["Pramoedya Ananta Toer"],
+			"publisher": ["Hasta Mitra", "Lentera Dipantara"], "first_publish_year": 1980,
+			"isbn": ["9789799731234", "979973123X"], "cover_i": 42
+		}]}`,
+	})
+
+	md, err := ol.Lookup(context.Background(), Query{Title: "Bumi Manusia"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Metadata{
+		Judul:       "Bumi Manusia",
+		Penulis:     "Pramoedya Ananta Toer",
+		Penerbit:    "Hasta Mitra",
+		TahunTerbit: 1980,
+		CoverURL:    "https://covers.example/b/id/42-L.jpg",
+	}
+	// The ISBNs of a title search may belong to any edition
+	if !reflect.DeepEqual(md, want) {
+		t.Errorf("Lookup =\n %+v\nwant\n %+v", md, want)
+	}
+	if want := "fields=title%2Cauthor_name%2Cpublisher%2Cfirst_publish_year%2Ccover_i&limit=1&title=Bumi+Manusia"; queries()[0] != want {
+		t.Errorf("query = %q, want %q", queries()[0], want)
+	}
+}
+
+func TestOpenLibraryNotFoundAndErrors(t *testing.T) {
+	ol, _ := fakeOpenLibrary(t, map[string]string{
+		"/api/books":   `{}`,
+		"/search.json": `{"docs": []}`,
+	})
+	for _, q := range []Query{{ISBN: "9780000000002"}, {Title: "Tidak Ada"}, {}} {
+		if _, err := ol.Lookup(context.Background(), q); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Lookup(%+v) error = %v, want ErrNotFound", q, err)
+		}
+	}
+
+	missing, _ := fakeOpenLibrary(t, map[string]string{})
+	if _, err := missing.Lookup(context.Background(), Query{ISBN: "1"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("HTTP 404 error = %v, want ErrNotFound", err)
+	}
+
+	down, _ := fakeOpenLibrary(t, map[string]string{"/api/books": ""})
+	if _, err := down.Lookup(context.Background(), Query{ISBN: "1"}); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("HTTP 503 error = %v, want a provider error", err)
+	}
+}
@@ -5,50 +5,79 @@ import (
 	"fmt"
 	"log"
 	"math/rand"
-	"net/smtp"
 	"strings"
 	"time"
 
 	"pojok_baca_api/database"
+	"pojok_baca_api/i18n"
+	"pojok_baca_api/mailer"
+	"pojok_baca_api/metrics"
 	"pojok_baca_api/models"
 	"pojok_baca_api/utils"
 
 	"github.com/gofiber/fiber/v2"
 )
 
-// Login handles user authentication
-func Login(c *fiber.Ctx) error {
+// Login handles user authentication. A successful login issues a bearer
+// token, valid for sessionTTL, for the endpoints that need a signed-in user.
+// POST /api/v1/login
+func Login(sessionTTL time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return login(c, sessionTTL)
+	}
+}
+
+// login checks the credentials and starts a session
+func login(c *fiber.Ctx, sessionTTL time.Duration) error {
 	userLogin := new(models.UserLogin)
 
 	if err := c.BodyParser(userLogin); err != nil {
-		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Invalid request body")
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "common.invalid_body")
 	}
 
 	userLogin.Email = strings.TrimSpace(userLogin.Email)
 	userLogin.Password = strings.TrimSpace(userLogin.Password)
 
-	if userLogin.Email == "" || userLogin.Password == "" {
-		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Email dan Password harus diisi")
+	if errs := utils.ValidateStruct(userLogin); errs != nil {
+		return utils.ValidationErrorResponse(c, errs)
 	}
 
 	user := new(models.User)
-	err := database.DB.QueryRow("SELECT user_id, nama_lengkap, nim, email, password FROM users WHERE email = ?", userLogin.Email).Scan(&user.UserID, &user.NamaLengkap, &user.NIM, &user.Email, &user.Password)
+	ctx, cancel := database.WithTimeout(c.UserContext())
+	defer cancel()
+
+	err := database.DB.QueryRowContext(ctx, "SELECT user_id, nama_lengkap, nim, email, password, role FROM users WHERE email = ?", userLogin.Email).Scan(&user.UserID, &user.NamaLengkap, &user.NIM, &user.Email, &user.Password, &user.Role)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return utils.ErrorResponse(c, fiber.StatusUnauthorized, "Email atau password salah")
+			metrics.LoginFailures.Inc()
+			return utils.ErrorResponse(c, fiber.StatusUnauthorized, "auth.invalid_credentials")
 		}
-		return utils.ErrorResponse(c, fiber.StatusInternalServerError, fmt.Sprintf("Database error during login attempt: %v", err))
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "auth.login_db_error", err)
 	}
 
-	if userLogin.Password != user.Password {
-		return utils.ErrorResponse(c, fiber.StatusUnauthorized, "Email atau password salah")
+	if !checkPassword(user.Password, userLogin.Password) {
+		metrics.LoginFailures.Inc()
+		return utils.ErrorResponse(c, fiber.StatusUnauthorized, "auth.invalid_credentials")
+	}
+	if !isPasswordHash(user.Password) {
+		upgradePassword(ctx, user.UserID, user.Password, userLogin.Password)
 	}
 
-	return utils.JSONResponse(c, fiber.StatusOK, "Login successful", fiber.Map{
+	token, expiresAt, err := createSession(ctx, user.UserID, sessionTTL)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "auth.login_db_error", err)
+	}
+
+	metrics.Logins.Inc()
+
+	return utils.JSONResponse(c, fiber.StatusOK, "auth.login_success", fiber.Map{
 		"user_id":      user.UserID,
 		"nim":          user.NIM,
 		"nama_lengkap": user.NamaLengkap,
 		"email":        user.Email,
+		"role":         user.Role,
+		"token":        token,
+		"expires_at":   expiresAt,
 	})
 }
 
@@ -57,7 +86,7 @@ func Register(c *fiber.Ctx) error {
 	user := new(models.User)
 
 	if err := c.BodyParser(user); err != nil {
-		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Invalid request body")
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "common.invalid_body")
 	}
 
 	user.NamaLengkap = strings.TrimSpace(user.NamaLengkap)
@@ -65,180 +94,255 @@ func Register(c *fiber.Ctx) error {
 	user.Email = strings.TrimSpace(user.Email)
 	user.Password = strings.TrimSpace(user.Password)
 
-	if user.NIM == "" || user.Email == "" || user.Password == "" || user.NamaLengkap == "" {
-		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Semua kolom (Nama Lengkap, NIM, Email, Password) harus diisi")
+	if errs := utils.ValidateStruct(user); errs != nil {
+		return utils.ValidationErrorResponse(c, errs)
 	}
 
 	var count int
-	database.DB.QueryRow("SELECT COUNT(*) FROM users WHERE email = ? OR nim = ?", user.Email, user.NIM).Scan(&count)
+	ctx, cancel := database.WithTimeout(c.UserContext())
+	defer cancel()
+
+	database.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM users WHERE email = ? OR nim = ?", user.Email, user.NIM).Scan(&count)
 	if count > 0 {
-		return utils.ErrorResponse(c, fiber.StatusConflict, "NIM atau Email sudah terdaftar")
+		return utils.ErrorResponse(c, fiber.StatusConflict, "auth.already_registered")
+	}
+
+	hash, err := hashPassword(user.Password)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "auth.register_failed", err)
 	}
 
-	result, err := database.DB.Exec(
+	result, err := database.DB.ExecContext(ctx,
 		"INSERT INTO users (nama_lengkap, nim, email, password) VALUES (?, ?, ?, ?)",
-		user.NamaLengkap, user.NIM, user.Email, user.Password,
+		user.NamaLengkap, user.NIM, user.Email, hash,
 	)
 	if err != nil {
-		return utils.ErrorResponse(c, fiber.StatusInternalServerError, fmt.Sprintf("Gagal mendaftarkan pengguna: %v", err))
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "auth.register_failed", err)
 	}
 
+	metrics.Registrations.Inc()
+
 	id, _ := result.LastInsertId()
 	user.UserID = int(id)
 	user.Password = ""
+	user.Role = models.RoleMember
 
-	return utils.JSONResponse(c, fiber.StatusCreated, "Pengguna berhasil didaftarkan", user)
+	return utils.JSONResponse(c, fiber.StatusCreated, "auth.register_success", user)
 }
 
-// RequestPasswordReset handles request to initiate password reset process
+// RequestPasswordReset handles request to initiate password reset process.
+// The reset code is delivered by email through the given mailer.
 // POST /api/v1/password-reset/request
-func RequestPasswordReset(c *fiber.Ctx) error {
-	type RequestBody struct {
-		Email string `json:"email"`
-	}
-	req := new(RequestBody)
-	if err := c.BodyParser(req); err != nil {
-		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Invalid request body")
-	}
-
-	req.Email = strings.TrimSpace(req.Email)
-	if req.Email == "" {
-		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Email diperlukan")
-	}
+func RequestPasswordReset(mail *mailer.Mailer) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		type RequestBody struct {
+			Email string `json:"email" validate:"required,email"`
+		}
+		req := new(RequestBody)
+		if err := c.BodyParser(req); err != nil {
+			return utils.ErrorResponse(c, fiber.StatusBadRequest, "common.invalid_body")
+		}
 
-	user := new(models.User)
-	err := database.DB.QueryRow("SELECT user_id FROM users WHERE email = ?", req.Email).Scan(&user.UserID)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			log.Printf("Password reset requested for non-existent email: %s", req.Email)
-			return utils.JSONResponse(c, fiber.StatusOK, "Jika email terdaftar, kode reset akan dikirim.", nil)
+		req.Email = strings.TrimSpace(req.Email)
+		if errs := utils.ValidateStruct(req); errs != nil {
+			return utils.ValidationErrorResponse(c, errs)
 		}
-		return utils.ErrorResponse(c, fiber.StatusInternalServerError, fmt.Sprintf("Database error: %v", err))
-	}
 
-	_, err = database.DB.Exec("DELETE FROM password_reset_codes WHERE user_id = ?", user.UserID)
-	if err != nil {
-		log.Printf("Failed to delete old reset codes for user %d: %v", user.UserID, err)
-	}
+		user := new(models.User)
+		ctx, cancel := database.WithTimeout(c.UserContext())
+		defer cancel()
+
+		err := database.DB.QueryRowContext(ctx, "SELECT user_id FROM users WHERE email = ?", req.Email).Scan(&user.UserID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				log.Printf("Password reset requested for non-existent email: %s", req.Email)
+				return utils.JSONResponse(c, fiber.StatusOK, "password_reset.requested", nil)
+			}
+			return utils.ErrorResponse(c, fiber.StatusInternalServerError, "common.database_error", err)
+		}
 
-	rand.Seed(time.Now().UnixNano())
-	resetCode := fmt.Sprintf("%06d", rand.Intn(1000000))
+		_, err = database.DB.ExecContext(ctx, "DELETE FROM password_reset_codes WHERE user_id = ?", user.UserID)
+		if err != nil {
+			log.Printf("Failed to delete old reset codes for user %d: %v", user.UserID, err)
+		}
 
-	_, err = database.DB.Exec(
-		"INSERT INTO password_reset_codes (user_id, reset_code) VALUES (?, ?)",
-		user.UserID, resetCode,
-	)
-	if err != nil {
-		return utils.ErrorResponse(c, fiber.StatusInternalServerError, fmt.Sprintf("Failed to save reset code: %v", err))
-	}
+		rand.Seed(time.Now().UnixNano())
+		resetCode := fmt.Sprintf("%06d", rand.Intn(1000000))
 
-	const (
-		smtpHost     = "smtp.gmail.com"
-		smtpPort     = "587"
-		senderEmail  = "asrama.putera.murakatahst@gmail.com"
-		senderPass   = "yblp ditv bddp dclj" // GANTI INI DENGAN PASSWORD APLIKASI ASLI AKUN GOOGLE ANDA!
-	)
+		_, err = database.DB.ExecContext(ctx,
+			"INSERT INTO password_reset_codes (user_id, reset_code) VALUES (?, ?)",
+			user.UserID, resetCode,
+		)
+		if err != nil {
+			return utils.ErrorResponse(c, fiber.StatusInternalServerError, "password_reset.save_failed", err)
+		}
 
-	to := []string{req.Email}
-	msg := []byte(
-		"To: " + req.Email + "\r\n" +
-			"From: " + senderEmail + "\r\n" +
-			"Subject: Kode Reset Password Pojok Baca\r\n" +
-			"Content-Type: text/plain; charset=UTF-8\r\n" +
-			"\r\n" +
-			"Halo Pengguna Pojok Baca,\n\n" +
-			"Anda telah meminta reset password. Berikut adalah kode reset Anda:\n\n" +
-			"Kode Reset: " + resetCode + "\n\n" +
-			"Kode ini tidak memiliki masa kedaluwarsa, namun hanya dapat digunakan satu kali.\n\n" +
-			"Jika Anda tidak meminta reset password ini, harap abaikan email ini.\n\n" +
-			"Terima kasih,\nTim Pojok Baca\n",
-	)
+		// Email subject and body follow the language the request was made in.
+		locale := i18n.Locale(c)
+		subject := i18n.T(locale, "password_reset.email_subject")
+		body := i18n.T(locale, "password_reset.email_body", resetCode)
 
-	auth := smtp.PlainAuth("", senderEmail, senderPass, smtpHost)
+		if err := mail.Send(c.UserContext(), []string{req.Email}, subject, body); err != nil {
+			metrics.MailSent.WithLabelValues("password_reset", "failure").Inc()
+			log.Printf("Gagal mengirim email ke %s: %v", req.Email, err)
+		} else {
+			metrics.MailSent.WithLabelValues("password_reset", "success").Inc()
+		}
 
-	err = smtp.SendMail(smtpHost+":"+smtpPort, auth, senderEmail, to, msg)
-	if err != nil {
-		log.Printf("Gagal mengirim email ke %s: %v", req.Email, err)
+		return utils.JSONResponse(c, fiber.StatusOK, "password_reset.requested", nil)
 	}
-
-	return utils.JSONResponse(c, fiber.StatusOK, "Jika email terdaftar, kode reset akan dikirim.", nil)
 }
 
-// VerifyResetCode handles verification of the reset code
+// resetTokenTTL is how long the token issued by VerifyResetCode can be
+// used to set a new password
+const resetTokenTTL = 15 * time.Minute
+
+// VerifyResetCode handles verification of the reset code. A valid code is
+// used up and exchanged for a single-use reset token, valid for
+// resetTokenTTL, that SetNewPassword requires.
 // POST /api/v1/password-reset/verify
 func VerifyResetCode(c *fiber.Ctx) error {
 	type RequestBody struct {
-		Email     string `json:"email"`
-		ResetCode string `json:"reset_code"`
+		Email     string `json:"email" validate:"required,email"`
+		ResetCode string `json:"reset_code" validate:"required,len=6,numeric"`
 	}
 	req := new(RequestBody)
 	if err := c.BodyParser(req); err != nil {
-		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Invalid request body")
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "common.invalid_body")
 	}
 
 	req.Email = strings.TrimSpace(req.Email)
 	req.ResetCode = strings.TrimSpace(req.ResetCode)
 
-	if req.Email == "" || req.ResetCode == "" {
-		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Email dan kode reset diperlukan")
+	if errs := utils.ValidateStruct(req); errs != nil {
+		return utils.ValidationErrorResponse(c, errs)
 	}
 
 	var storedUserID int
 
-	err := database.DB.QueryRow(
+	ctx, cancel := database.WithTimeout(c.UserContext())
+	defer cancel()
+
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "common.database_error", err)
+	}
+	defer tx.Rollback()
+
+	// Locked so that a code is exchanged for a token only once
+	err = tx.QueryRowContext(ctx,
 		`SELECT prc.user_id FROM password_reset_codes prc
 		 JOIN users u ON prc.user_id = u.user_id
-		 WHERE u.email = ? AND prc.reset_code = ?`,
+		 WHERE u.email = ? AND prc.reset_code = ?
+		 FOR UPDATE`,
 		req.Email, req.ResetCode,
 	).Scan(&storedUserID)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return utils.ErrorResponse(c, fiber.StatusBadRequest, "Kode reset tidak valid atau tidak cocok dengan email")
+			return utils.ErrorResponse(c, fiber.StatusBadRequest, "password_reset.invalid_code")
 		}
-		return utils.ErrorResponse(c, fiber.StatusInternalServerError, fmt.Sprintf("Database error: %v", err))
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "common.database_error", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM password_reset_codes WHERE user_id = ?", storedUserID); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "common.database_error", err)
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "common.database_error", err)
 	}
+	expiresAt := time.Now().Add(resetTokenTTL)
 
-	_, err = database.DB.Exec("DELETE FROM password_reset_codes WHERE user_id = ?", storedUserID)
+	// Only the newest token of a user is valid
+	if _, err := tx.ExecContext(ctx, "DELETE FROM password_reset_tokens WHERE user_id = ?", storedUserID); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "common.database_error", err)
+	}
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO password_reset_tokens (token_hash, user_id, expires_at) VALUES (?, ?, ?)",
+		hashSessionToken(token), storedUserID, expiresAt,
+	)
 	if err != nil {
-		log.Printf("Gagal menghapus kode reset yang sudah digunakan untuk user %d: %v", storedUserID, err)
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "common.database_error", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "common.database_error", err)
 	}
 
-	return utils.JSONResponse(c, fiber.StatusOK, "Kode reset valid", nil)
+	return utils.JSONResponse(c, fiber.StatusOK, "password_reset.code_valid", fiber.Map{
+		"reset_token": token,
+		"expires_at":  expiresAt,
+	})
 }
 
-// SetNewPassword handles setting a new password after successful code verification
+// SetNewPassword handles setting a new password after successful code
+// verification. It requires the reset token issued by VerifyResetCode and
+// uses it up.
 // POST /api/v1/password-reset/set-new-password
 func SetNewPassword(c *fiber.Ctx) error {
 	type RequestBody struct {
-		Email       string `json:"email"`
-		NewPassword string `json:"new_password"`
+		Email       string `json:"email" validate:"required,email"`
+		ResetToken  string `json:"reset_token" validate:"required,len=64,hexadecimal"`
+		NewPassword string `json:"new_password" validate:"required,strongpassword,max=72"`
 	}
 	req := new(RequestBody)
 	if err := c.BodyParser(req); err != nil {
-		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Invalid request body")
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "common.invalid_body")
 	}
 
 	req.Email = strings.TrimSpace(req.Email)
+	req.ResetToken = strings.TrimSpace(req.ResetToken)
 	req.NewPassword = strings.TrimSpace(req.NewPassword)
 
-	if req.Email == "" || req.NewPassword == "" {
-		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Email dan password baru diperlukan")
+	if errs := utils.ValidateStruct(req); errs != nil {
+		return utils.ValidationErrorResponse(c, errs)
 	}
 
-	res, err := database.DB.Exec(
-		"UPDATE users SET password = ? WHERE email = ?",
-		req.NewPassword,
-		req.Email,
-	)
+	ctx, cancel := database.WithTimeout(c.UserContext())
+	defer cancel()
+
+	tx, err := database.DB.BeginTx(ctx, nil)
 	if err != nil {
-		return utils.ErrorResponse(c, fiber.StatusInternalServerError, fmt.Sprintf("Failed to update password: %v", err))
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "password_reset.update_failed", err)
+	}
+	defer tx.Rollback()
+
+	// The token is locked and deleted with the update, so it works once
+	var userID int
+	err = tx.QueryRowContext(ctx,
+		`SELECT t.user_id FROM password_reset_tokens t
+		 JOIN users u ON t.user_id = u.user_id
+		 WHERE u.email = ? AND t.token_hash = ? AND t.expires_at > CURRENT_TIMESTAMP(6)
+		 FOR UPDATE`,
+		req.Email, hashSessionToken(req.ResetToken),
+	).Scan(&userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return utils.ErrorResponse(c, fiber.StatusBadRequest, "password_reset.invalid_token")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "password_reset.update_failed", err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM password_reset_tokens WHERE user_id = ?", userID); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "password_reset.update_failed", err)
+	}
+
+	hash, err := hashPassword(req.NewPassword)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "password_reset.update_failed", err)
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET password = ? WHERE user_id = ?", hash, userID); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "password_reset.update_failed", err)
 	}
 
-	rowsAffected, _ := res.RowsAffected()
-	if rowsAffected == 0 {
-		return utils.ErrorResponse(c, fiber.StatusNotFound, "Pengguna tidak ditemukan")
+	// A new password logs out every session, including any stolen token
+	_, err = tx.ExecContext(ctx, "DELETE FROM user_sessions WHERE user_id = ?", userID)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "password_reset.update_failed", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "password_reset.update_failed", err)
 	}
 
-	return utils.JSONResponse(c, fiber.StatusOK, "Password berhasil diubah", nil)
+	return utils.JSONResponse(c, fiber.StatusOK, "password_reset.success", nil)
 }
\ No newline at end of file
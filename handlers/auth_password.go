@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"log"
+
+	"pojok_baca_api/database"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// hashPassword returns the bcrypt hash stored in users.password
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// isPasswordHash reports whether a stored password is a bcrypt hash rather
+// than a plaintext password saved before passwords were hashed
+func isPasswordHash(stored string) bool {
+	_, err := bcrypt.Cost([]byte(stored))
+	return err == nil
+}
+
+// checkPassword reports whether password matches the stored password,
+// hashed or still in plaintext
+func checkPassword(stored, password string) bool {
+	if isPasswordHash(stored) {
+		return bcrypt.CompareHashAndPassword([]byte(stored), []byte(password)) == nil
+	}
+	return subtle.ConstantTimeCompare([]byte(stored), []byte(password)) == 1
+}
+
+// upgradePassword replaces a plaintext password with its hash after a
+// successful login. Failures are only logged: the next login retries.
+func upgradePassword(ctx context.Context, userID int, stored, password string) {
+	hash, err := hashPassword(password)
+	if err == nil {
+		// Only if the password was not changed in the meantime
+		_, err = database.DB.ExecContext(ctx, "UPDATE users SET password = ? WHERE user_id = ? AND password = ?", hash, userID, stored)
+	}
+	if err != nil {
+		log.Printf("Gagal meng-hash ulang password pengguna %d: %v", userID, err)
+	}
+}
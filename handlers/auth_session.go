@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"pojok_baca_api/database"
+	"pojok_baca_api/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// sessionUserKey is the c.Locals key under which RequireAuth stores the
+// authenticated user
+const sessionUserKey = "session_user"
+
+// sessionUser is the user a request is authenticated as
+type sessionUser struct {
+	UserID      int
+	NamaLengkap string
+	Role        string
+}
+
+// hashSessionToken returns the hex SHA-256 of a token; only the hash is
+// stored, so a leaked sessions table cannot be used to log in
+func hashSessionToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// newToken returns a random 64-character hex token
+func newToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
+
+// createSession issues a new bearer token for a user, valid for ttl
+func createSession(ctx context.Context, userID int, ttl time.Duration) (string, time.Time, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	expiresAt := time.Now().Add(ttl)
+
+	// Expired sessions of the user are cleaned up on the way
+	if _, err := database.DB.ExecContext(ctx, "DELETE FROM user_sessions WHERE user_id = ? AND expires_at < CURRENT_TIMESTAMP(6)", userID); err != nil {
+		return "", time.Time{}, err
+	}
+	_, err = database.DB.ExecContext(ctx,
+		"INSERT INTO user_sessions (token_hash, user_id, expires_at) VALUES (?, ?, ?)",
+		hashSessionToken(token), userID, expiresAt)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
+
+// bearerToken extracts the token from an "Authorization: Bearer <token>"
+// header; it returns "" when there is none
+func bearerToken(c *fiber.Ctx) string {
+	scheme, token, ok := strings.Cut(c.Get(fiber.HeaderAuthorization), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// lookupSession returns the user of a valid, unexpired token; it returns
+// sql.ErrNoRows when there is none
+func lookupSession(ctx context.Context, token string) (*sessionUser, error) {
+	// Sessions are read from the primary: a token is used right after login
+	user := new(sessionUser)
+	err := database.DB.QueryRowContext(ctx,
+		"SELECT u.user_id, u.nama_lengkap, u.role FROM user_sessions s JOIN users u ON u.user_id = s.user_id"+
+			" WHERE s.token_hash = ? AND s.expires_at > CURRENT_TIMESTAMP(6)", hashSessionToken(token),
+	).Scan(&user.UserID, &user.NamaLengkap, &user.Role)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// RequireAuth rejects requests without a valid, unexpired bearer token
+// with 401 and makes the authenticated user available to the handlers
+func RequireAuth(c *fiber.Ctx) error {
+	token := bearerToken(c)
+	if token == "" {
+		return utils.ErrorResponse(c, fiber.StatusUnauthorized, "auth.unauthorized")
+	}
+
+	ctx, cancel := database.WithTimeout(c.UserContext())
+	defer cancel()
+
+	user, err := lookupSession(ctx, token)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return utils.ErrorResponse(c, fiber.StatusUnauthorized, "auth.unauthorized")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "common.database_error", err)
+	}
+
+	c.Locals(sessionUserKey, user)
+	return c.Next()
+}
+
+// OptionalAuth makes the authenticated user available to public endpoints
+// that personalize their work for signed-in users. A missing, expired or
+// unknown token leaves the request anonymous instead of rejecting it.
+func OptionalAuth(c *fiber.Ctx) error {
+	if token := bearerToken(c); token != "" {
+		ctx, cancel := database.WithTimeout(c.UserContext())
+		user, err := lookupSession(ctx, token)
+		cancel()
+		if err == nil {
+			c.Locals(sessionUserKey, user)
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			return utils.ErrorResponse(c, fiber.StatusInternalServerError, "common.database_error", err)
+		}
+	}
+	return c.Next()
+}
+
+// RequireRole rejects authenticated users without the given role with 403.
+// It must run after RequireAuth.
+func RequireRole(role string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if user := currentUser(c); user == nil || user.Role != role {
+			return utils.ErrorResponse(c, fiber.StatusForbidden, "auth.forbidden")
+		}
+		return c.Next()
+	}
+}
+
+// currentUser returns the user authenticated by RequireAuth, or nil
+func currentUser(c *fiber.Ctx) *sessionUser {
+	user, _ := c.Locals(sessionUserKey).(*sessionUser)
+	return user
+}
+
+// Logout revokes the bearer token of the request
+// POST /api/v1/logout
+func Logout(c *fiber.Ctx) error {
+	ctx, cancel := database.WithTimeout(c.UserContext())
+	defer cancel()
+
+	if _, err := database.DB.ExecContext(ctx, "DELETE FROM user_sessions WHERE token_hash = ?", hashSessionToken(bearerToken(c))); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "common.database_error", err)
+	}
+	return utils.JSONResponse(c, fiber.StatusOK, "auth.logout_success", nil)
+}
@@ -0,0 +1,441 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"pojok_baca_api/cache"
+	"pojok_baca_api/database"
+	"pojok_baca_api/models"
+	"pojok_baca_api/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// authorColumns is the column list shared by every author SELECT, in scanAuthor order
+const authorColumns = "author_id, nama_penulis, bio, foto_url, created_at, updated_at"
+
+// scanAuthor scans a row selected with authorColumns into an Author
+func scanAuthor(row rowScanner, author *models.Author) error {
+	return row.Scan(&author.AuthorID, &author.NamaPenulis, &author.Bio, &author.FotoURL, &author.CreatedAt, &author.UpdatedAt)
+}
+
+// queryAuthors loads every author whose name contains search (all when
+// empty), never returning a nil slice
+func queryAuthors(ctx context.Context, search string) ([]models.Author, error) {
+	query := "SELECT " + authorColumns + " FROM authors"
+	var args []interface{}
+	if search != "" {
+		query += " WHERE nama_penulis LIKE ?"
+		args = append(args, "%"+escapeLike(search)+"%")
+	}
+	// Read-only query: served by a healthy read replica when configured
+	rows, err := database.Reader(ctx).QueryContext(ctx, query+" ORDER BY nama_penulis", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	authors := []models.Author{}
+	for rows.Next() {
+		var author models.Author
+		if err := scanAuthor(rows, &author); err != nil {
+			return nil, err
+		}
+		authors = append(authors, author)
+	}
+	return authors, rows.Err()
+}
+
+// queryAuthorByID loads a single author; it returns sql.ErrNoRows when it does not exist
+func queryAuthorByID(ctx context.Context, id int) (*models.Author, error) {
+	author := new(models.Author)
+	err := scanAuthor(database.Reader(ctx).QueryRowContext(ctx, "SELECT "+authorColumns+" FROM authors WHERE author_id = ?", id), author)
+	if err != nil {
+		return nil, err
+	}
+	return author, nil
+}
+
+// queryAuthorBooks loads the books an author is linked to, with the author's role
+func queryAuthorBooks(ctx context.Context, id int) ([]models.AuthorBook, error) {
+	rows, err := database.Reader(ctx).QueryContext(ctx,
+		"SELECT "+prefixedBookColumns+", ba.peran FROM book_authors ba JOIN books b ON b.book_id = ba.book_id"+
+			" WHERE ba.author_id = ? ORDER BY b.tahun_terbit, b.judul", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var books []models.Book
+	var roles []string
+	for rows.Next() {
+		var book models.Book
+		var role string
+		if err := rows.Scan(append(bookDest(&book), &role)...); err != nil {
+			return nil, err
+		}
+		books = append(books, book)
+		roles = append(roles, role)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := attachRelations(ctx, books); err != nil {
+		return nil, err
+	}
+
+	result := make([]models.AuthorBook, len(books))
+	for i := range books {
+		result[i] = models.AuthorBook{Book: books[i], Peran: roles[i]}
+	}
+	return result, nil
+}
+
+// authorETag computes the ETag of an author's JSON representation, matching
+// the ETag GetAuthorByID sends for the same data
+func authorETag(author *models.Author) string {
+	payload, _ := json.Marshal(author)
+	return utils.ETag(payload)
+}
+
+// respondWithAuthor reloads an author from the primary after a write and
+// sends it with its new ETag
+func respondWithAuthor(c *fiber.Ctx, ctx context.Context, status int, message string, id int) error {
+	author, err := queryAuthorByID(database.WithPrimary(ctx), id)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "author.get_failed", err)
+	}
+	c.Set(fiber.HeaderETag, authorETag(author))
+	return utils.JSONResponse(c, status, message, author)
+}
+
+// invalidateAuthorCache drops the cached author lists and, if id > 0, the
+// cached author and every cached book (books embed their authors' names)
+func invalidateAuthorCache(ctx context.Context, id int) {
+	keys := []string{cache.AllAuthorLists}
+	if id > 0 {
+		keys = append(keys, cache.AuthorKey(id), cache.AllAuthorBooks, cache.AllBooks)
+	}
+	cache.Default.Invalidate(ctx, keys...)
+}
+
+// GetAllAuthors gets all authors, optionally filtered by ?q= on the name
+// GET /api/v1/authors
+func GetAllAuthors(c *fiber.Ctx) error {
+	ctx, cancel := database.WithTimeout(c.UserContext())
+	defer cancel()
+
+	search := strings.TrimSpace(c.Query("q"))
+	authors, err := cache.Default.GetOrLoad(ctx, "authors", cache.AuthorListKey(normalizedQuery(c)), func(ctx context.Context) (interface{}, error) {
+		return queryAuthors(ctx, search)
+	})
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "author.list_failed", err)
+	}
+
+	// Clients holding the same list get 304 Not Modified
+	if utils.NotModified(c, utils.ETag(authors), time.Time{}) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	if isEmptyJSONArray(authors) {
+		return utils.JSONResponse(c, fiber.StatusOK, "author.list_empty", []models.Author{})
+	}
+
+	return utils.JSONResponse(c, fiber.StatusOK, "author.list_success", authors)
+}
+
+// GetAuthorByID gets a single author by its ID
+// GET /api/v1/authors/:id
+func GetAuthorByID(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "author.invalid_id")
+	}
+
+	ctx, cancel := database.WithTimeout(c.UserContext())
+	defer cancel()
+
+	author, err := cache.Default.GetOrLoad(ctx, "authors", cache.AuthorKey(id), func(ctx context.Context) (interface{}, error) {
+		return queryAuthorByID(ctx, id)
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return utils.ErrorResponse(c, fiber.StatusNotFound, "author.not_found")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "author.get_failed", err)
+	}
+
+	// Honor If-None-Match / If-Modified-Since
+	if utils.NotModified(c, utils.ETag(author), lastModified(author)) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	return utils.JSONResponse(c, fiber.StatusOK, "author.get_success", author)
+}
+
+// GetAuthorBooks lists the books an author wrote, translated or edited
+// GET /api/v1/authors/:id/books
+func GetAuthorBooks(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "author.invalid_id")
+	}
+
+	ctx, cancel := database.WithTimeout(c.UserContext())
+	defer cancel()
+
+	books, err := cache.Default.GetOrLoad(ctx, "authors", cache.AuthorBooksKey(id), func(ctx context.Context) (interface{}, error) {
+		// An unknown author is a 404, not an empty list
+		if _, err := queryAuthorByID(ctx, id); err != nil {
+			return nil, err
+		}
+		books, err := queryAuthorBooks(ctx, id)
+		if books == nil && err == nil {
+			books = []models.AuthorBook{}
+		}
+		return books, err
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return utils.ErrorResponse(c, fiber.StatusNotFound, "author.not_found")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "book.list_failed", err)
+	}
+
+	if utils.NotModified(c, utils.ETag(books), time.Time{}) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	if isEmptyJSONArray(books) {
+		return utils.JSONResponse(c, fiber.StatusOK, "book.list_empty", []models.AuthorBook{})
+	}
+
+	return utils.JSONResponse(c, fiber.StatusOK, "book.list_success", books)
+}
+
+// CreateAuthor adds a new author
+// POST /api/v1/authors
+func CreateAuthor(c *fiber.Ctx) error {
+	author := new(models.Author)
+	if err := c.BodyParser(author); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "common.invalid_body")
+	}
+	author.NamaPenulis = strings.Join(strings.Fields(author.NamaPenulis), " ")
+
+	if errs := utils.ValidateStruct(author); errs != nil {
+		return utils.ValidationErrorResponse(c, errs)
+	}
+
+	ctx, cancel := database.WithTimeout(c.UserContext())
+	defer cancel()
+
+	result, err := database.DB.ExecContext(ctx,
+		"INSERT INTO authors (nama_penulis, bio, foto_url) VALUES (?, ?, ?)",
+		author.NamaPenulis, author.Bio, author.FotoURL,
+	)
+	if err != nil {
+		if isDuplicateKey(err) {
+			return utils.ErrorResponse(c, fiber.StatusConflict, "author.duplicate_name")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "author.create_failed", err)
+	}
+
+	id, _ := result.LastInsertId()
+	invalidateAuthorCache(ctx, 0)
+
+	return respondWithAuthor(c, ctx, fiber.StatusCreated, "author.created", int(id))
+}
+
+// UpdateAuthor updates an existing author
+// PUT /api/v1/authors/:id
+func UpdateAuthor(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "author.invalid_id")
+	}
+
+	author := new(models.Author)
+	if err := c.BodyParser(author); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "common.invalid_body")
+	}
+	author.NamaPenulis = strings.Join(strings.Fields(author.NamaPenulis), " ")
+
+	if errs := utils.ValidateStruct(author); errs != nil {
+		return utils.ValidationErrorResponse(c, errs)
+	}
+
+	ctx, cancel := database.WithTimeout(c.UserContext())
+	defer cancel()
+
+	query := "UPDATE authors SET nama_penulis = ?, bio = ?, foto_url = ?, updated_at = CURRENT_TIMESTAMP(6) WHERE author_id = ?"
+	args := []interface{}{author.NamaPenulis, author.Bio, author.FotoURL, id}
+
+	// Optimistic concurrency: with If-Match the update only applies to the version the client saw
+	if utils.HasIfMatch(c) {
+		current, err := queryAuthorByID(database.WithPrimary(ctx), id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return utils.ErrorResponse(c, fiber.StatusNotFound, "author.not_found")
+			}
+			return utils.ErrorResponse(c, fiber.StatusInternalServerError, "author.get_failed", err)
+		}
+		if utils.PreconditionFailed(c, authorETag(current)) {
+			return utils.ErrorResponse(c, fiber.StatusPreconditionFailed, "common.precondition_failed")
+		}
+		query += " AND updated_at = ?"
+		args = append(args, current.UpdatedAt)
+	}
+
+	res, err := database.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		if isDuplicateKey(err) {
+			return utils.ErrorResponse(c, fiber.StatusConflict, "author.duplicate_name")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "author.update_failed", err)
+	}
+
+	// updated_at always changes, so no affected rows means the author is gone
+	// or, with If-Match, that someone else changed it first
+	rowsAffected, _ := res.RowsAffected()
+	if rowsAffected == 0 {
+		if utils.HasIfMatch(c) {
+			return utils.ErrorResponse(c, fiber.StatusPreconditionFailed, "common.precondition_failed")
+		}
+		return utils.ErrorResponse(c, fiber.StatusNotFound, "author.not_found")
+	}
+
+	invalidateAuthorCache(ctx, id)
+
+	return respondWithAuthor(c, ctx, fiber.StatusOK, "author.updated", id)
+}
+
+// PatchAuthor partially updates an author with a JSON Merge Patch or a JSON
+// Patch; only columns that actually change are written.
+// PATCH /api/v1/authors/:id
+func PatchAuthor(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "author.invalid_id")
+	}
+
+	ctx, cancel := database.WithTimeout(c.UserContext())
+	defer cancel()
+
+	current, err := queryAuthorByID(database.WithPrimary(ctx), id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return utils.ErrorResponse(c, fiber.StatusNotFound, "author.not_found")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "author.get_failed", err)
+	}
+	if utils.PreconditionFailed(c, authorETag(current)) {
+		return utils.ErrorResponse(c, fiber.StatusPreconditionFailed, "common.precondition_failed")
+	}
+
+	original, _ := json.Marshal(current)
+	patched, perr := applyPatch(c, original)
+	if perr != nil {
+		if perr.err != nil {
+			return utils.ErrorResponse(c, perr.status, perr.message, perr.err)
+		}
+		return utils.ErrorResponse(c, perr.status, perr.message)
+	}
+
+	author := new(models.Author)
+	if err := json.Unmarshal(patched, author); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusUnprocessableEntity, "patch.invalid_result", err)
+	}
+	author.NamaPenulis = strings.Join(strings.Fields(author.NamaPenulis), " ")
+
+	if errs := utils.ValidateStruct(author); errs != nil {
+		return utils.ValidationErrorResponse(c, errs)
+	}
+
+	columns, values := changedColumns(current, author, "author_id", "created_at", "updated_at")
+	if len(columns) == 0 {
+		c.Set(fiber.HeaderETag, authorETag(current))
+		return utils.JSONResponse(c, fiber.StatusOK, "author.updated", current)
+	}
+
+	query := "UPDATE authors SET " + setClause(columns) + ", updated_at = CURRENT_TIMESTAMP(6) WHERE author_id = ? AND updated_at = ?"
+	args := append(values, id, current.UpdatedAt)
+
+	res, err := database.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		if isDuplicateKey(err) {
+			return utils.ErrorResponse(c, fiber.StatusConflict, "author.duplicate_name")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "author.update_failed", err)
+	}
+
+	rowsAffected, _ := res.RowsAffected()
+	if rowsAffected == 0 {
+		if utils.HasIfMatch(c) {
+			return utils.ErrorResponse(c, fiber.StatusPreconditionFailed, "common.precondition_failed")
+		}
+		return utils.ErrorResponse(c, fiber.StatusConflict, "common.concurrent_update")
+	}
+
+	invalidateAuthorCache(ctx, id)
+
+	return respondWithAuthor(c, ctx, fiber.StatusOK, "author.updated", id)
+}
+
+// DeleteAuthor deletes an author who is no longer linked to any book
+// DELETE /api/v1/authors/:id
+func DeleteAuthor(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "author.invalid_id")
+	}
+
+	ctx, cancel := database.WithTimeout(c.UserContext())
+	defer cancel()
+
+	query := "DELETE FROM authors WHERE author_id = ?"
+	args := []interface{}{id}
+
+	// Optimistic concurrency: only delete the version the client saw
+	if utils.HasIfMatch(c) {
+		current, err := queryAuthorByID(database.WithPrimary(ctx), id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return utils.ErrorResponse(c, fiber.StatusNotFound, "author.not_found")
+			}
+			return utils.ErrorResponse(c, fiber.StatusInternalServerError, "author.get_failed", err)
+		}
+		if utils.PreconditionFailed(c, authorETag(current)) {
+			return utils.ErrorResponse(c, fiber.StatusPreconditionFailed, "common.precondition_failed")
+		}
+		query += " AND updated_at = ?"
+		args = append(args, current.UpdatedAt)
+	}
+
+	res, err := database.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		// book_authors still references the author
+		if isForeignKeyViolation(err) {
+			return utils.ErrorResponse(c, fiber.StatusConflict, "author.in_use")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "author.delete_failed", err)
+	}
+
+	rowsAffected, _ := res.RowsAffected()
+	if rowsAffected == 0 {
+		if utils.HasIfMatch(c) {
+			return utils.ErrorResponse(c, fiber.StatusPreconditionFailed, "common.precondition_failed")
+		}
+		return utils.ErrorResponse(c, fiber.StatusNotFound, "author.not_found")
+	}
+
+	invalidateAuthorCache(ctx, id)
+
+	return utils.JSONResponse(c, fiber.StatusOK, "author.deleted", nil)
+}
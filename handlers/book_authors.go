@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"strings"
+
+	"pojok_baca_api/database"
+	"pojok_baca_api/models"
+	"pojok_baca_api/utils"
+)
+
+// dbExecutor is implemented by both *sql.DB and *sql.Tx, so the author link
+// helpers work inside and outside a transaction
+type dbExecutor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// authorSeparators splits a penulis text into names, the same way migration
+// 0004_authors does: "A, B & C", "A dan B", "A; B"
+var authorSeparators = regexp.MustCompile(`\s*(?:[,;&]|\s(?:dan|and)\s)\s*`)
+
+// splitAuthorNames returns the distinct names in a penulis text, in order
+func splitAuthorNames(penulis string) []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, name := range authorSeparators.Split(penulis, -1) {
+		name = strings.Join(strings.Fields(name), " ")
+		if key := strings.ToLower(name); name != "" && !seen[key] {
+			seen[key] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// linkAuthorsByName links a book to the authors named in its penulis text,
+// creating the authors that do not exist yet. Only the 'author' role is
+// replaced; translators and editors linked explicitly are kept.
+func linkAuthorsByName(ctx context.Context, db dbExecutor, bookID int, penulis string) error {
+	if _, err := db.ExecContext(ctx, "DELETE FROM book_authors WHERE book_id = ? AND peran = 'author'", bookID); err != nil {
+		return err
+	}
+	for i, name := range splitAuthorNames(penulis) {
+		// LAST_INSERT_ID(author_id) makes LastInsertId return the existing row's id
+		res, err := db.ExecContext(ctx,
+			"INSERT INTO authors (nama_penulis) VALUES (?) ON DUPLICATE KEY UPDATE author_id = LAST_INSERT_ID(author_id)", name)
+		if err != nil {
+			return err
+		}
+		authorID, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		if _, err := db.ExecContext(ctx,
+			"INSERT IGNORE INTO book_authors (book_id, author_id, peran, urutan) VALUES (?, ?, 'author', ?)", bookID, authorID, i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setBookAuthors replaces every author link of a book
+func setBookAuthors(ctx context.Context, db dbExecutor, bookID int, authors []models.BookAuthor) error {
+	if _, err := db.ExecContext(ctx, "DELETE FROM book_authors WHERE book_id = ?", bookID); err != nil {
+		return err
+	}
+	for i, author := range authors {
+		if _, err := db.ExecContext(ctx,
+			"INSERT IGNORE INTO book_authors (book_id, author_id, peran, urutan) VALUES (?, ?, ?, ?)",
+			bookID, author.AuthorID, authorRole(author), i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// authorRole returns the link's role, defaulting to author
+func authorRole(author models.BookAuthor) string {
+	if author.Peran == "" {
+		return "author"
+	}
+	return author.Peran
+}
+
+// resolveBookAuthors checks that every linked author exists and fills in
+// their names. When the book has no penulis text, it is derived from the
+// linked authors (or every linked person when none has the author role).
+func resolveBookAuthors(ctx context.Context, book *models.Book) ([]utils.FieldError, error) {
+	if len(book.Authors) == 0 {
+		return nil, nil
+	}
+
+	var errs []utils.FieldError
+	var names, everyone []string
+	for i := range book.Authors {
+		link := &book.Authors[i]
+		link.Peran = authorRole(*link)
+		err := database.Reader(ctx).QueryRowContext(ctx, "SELECT nama_penulis FROM authors WHERE author_id = ?", link.AuthorID).Scan(&link.NamaPenulis)
+		if err == sql.ErrNoRows {
+			errs = append(errs, utils.FieldError{Field: "author_id", Rule: "exists"})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		everyone = append(everyone, link.NamaPenulis)
+		if link.Peran == "author" {
+			names = append(names, link.NamaPenulis)
+		}
+	}
+
+	if strings.TrimSpace(book.Penulis) == "" && errs == nil {
+		if len(names) == 0 {
+			names = everyone
+		}
+		book.Penulis = strings.Join(names, ", ")
+	}
+	return errs, nil
+}
+
+// attachAuthors loads the linked authors of books with a single query
+func attachAuthors(ctx context.Context, books []models.Book) error {
+	index, placeholders, args := bookIDArgs(books)
+	for i := range books {
+		books[i].Authors = []models.BookAuthor{}
+	}
+	if len(books) == 0 {
+		return nil
+	}
+
+	rows, err := database.Reader(ctx).QueryContext(ctx,
+		"SELECT ba.book_id, a.author_id, a.nama_penulis, ba.peran FROM book_authors ba JOIN authors a ON a.author_id = ba.author_id"+
+			" WHERE ba.book_id IN ("+placeholders+") ORDER BY ba.book_id, ba.urutan, a.nama_penulis", args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var bookID int
+		var author models.BookAuthor
+		if err := rows.Scan(&bookID, &author.AuthorID, &author.NamaPenulis, &author.Peran); err != nil {
+			return err
+		}
+		if i, ok := index[bookID]; ok {
+			books[i].Authors = append(books[i].Authors, author)
+		}
+	}
+	return rows.Err()
+}
+
+// sameAuthors reports whether two author lists link the same people in the
+// same roles and order
+func sameAuthors(a, b []models.BookAuthor) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].AuthorID != b[i].AuthorID || authorRole(a[i]) != authorRole(b[i]) {
+			return false
+		}
+	}
+	return true
+}
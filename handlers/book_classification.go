@@ -0,0 +1,290 @@
+package handlers
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"pojok_baca_api/database"
+	"pojok_baca_api/models"
+	"pojok_baca_api/utils"
+)
+
+// normalizeTag lower-cases a tag and collapses its whitespace
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.Join(strings.Fields(tag), " "))
+}
+
+// splitTags splits a comma-separated tag list, as given in ?tag= and in
+// import files, dropping empty and repeated tags
+func splitTags(value string) []string {
+	tags := []string{}
+	seen := map[string]bool{}
+	for _, tag := range strings.Split(value, ",") {
+		if tag = normalizeTag(tag); tag != "" && !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// normalizeBookTags normalizes the tags of a book, dropping empty and
+// repeated ones. A nil list (tags not given) stays nil.
+func normalizeBookTags(book *models.Book) {
+	if book.Tags == nil {
+		return
+	}
+	tags := []string{}
+	seen := map[string]bool{}
+	for _, tag := range book.Tags {
+		if tag = normalizeTag(tag); tag != "" && !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	sort.Strings(tags)
+	book.Tags = tags
+}
+
+// resolveBookCategories sets category_ids to the book's full category list:
+// the primary category_id first, then the other given categories or, when
+// category_ids is omitted, the other current ones. Every category must exist.
+func resolveBookCategories(ctx context.Context, book, current *models.Book) ([]utils.FieldError, error) {
+	others := book.CategoryIDs
+	if others == nil && current != nil {
+		others = current.CategoryIDs
+		if current.CategoryID != book.CategoryID {
+			// The old primary category is replaced, not kept as a secondary one
+			others = removeInt(others, current.CategoryID)
+		}
+	}
+
+	// Primary first, the others by id, as attachCategories loads them
+	sorted := append([]int(nil), others...)
+	sort.Ints(sorted)
+	var ids []int
+	seen := map[int]bool{}
+	for _, id := range append([]int{book.CategoryID}, sorted...) {
+		if id > 0 && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	book.CategoryIDs = ids
+	if len(ids) == 0 {
+		book.CategoryIDs = []int{}
+		return nil, nil
+	}
+
+	placeholders, args := intArgs(ids)
+	rows, err := database.Reader(ctx).QueryContext(ctx, "SELECT category_id FROM categories WHERE category_id IN ("+placeholders+")", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	found := map[int]bool{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		found[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var errs []utils.FieldError
+	for i, id := range ids {
+		if found[id] {
+			continue
+		}
+		field := "category_ids"
+		if i == 0 && id == book.CategoryID {
+			field = "category_id"
+		}
+		errs = append(errs, utils.FieldError{Field: field, Rule: "exists"})
+	}
+	return errs, nil
+}
+
+// setBookCategories replaces every category assignment of a book
+func setBookCategories(ctx context.Context, db dbExecutor, bookID int, ids []int) error {
+	if _, err := db.ExecContext(ctx, "DELETE FROM book_categories WHERE book_id = ?", bookID); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if _, err := db.ExecContext(ctx, "INSERT IGNORE INTO book_categories (book_id, category_id) VALUES (?, ?)", bookID, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setBookTags replaces every tag of a book, creating the tags that do not
+// exist yet
+func setBookTags(ctx context.Context, db dbExecutor, bookID int, tags []string) error {
+	if _, err := db.ExecContext(ctx, "DELETE FROM book_tags WHERE book_id = ?", bookID); err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		// LAST_INSERT_ID(tag_id) makes LastInsertId return the existing row's id
+		res, err := db.ExecContext(ctx,
+			"INSERT INTO tags (nama_tag) VALUES (?) ON DUPLICATE KEY UPDATE tag_id = LAST_INSERT_ID(tag_id)", tag)
+		if err != nil {
+			return err
+		}
+		tagID, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		if _, err := db.ExecContext(ctx, "INSERT IGNORE INTO book_tags (book_id, tag_id) VALUES (?, ?)", bookID, tagID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// linkBookClassification updates the category assignments and tags of a
+// written book when they differ from current (nil for a new book). Omitted
+// tags are left as they are.
+func linkBookClassification(ctx context.Context, db dbExecutor, id int, book, current *models.Book) error {
+	if current == nil || !sameInts(book.CategoryIDs, current.CategoryIDs) {
+		if err := setBookCategories(ctx, db, id, book.CategoryIDs); err != nil {
+			return err
+		}
+	}
+	if tagsChanged(book, current) {
+		return setBookTags(ctx, db, id, book.Tags)
+	}
+	return nil
+}
+
+// classificationChanged reports whether book has other categories or tags than current
+func classificationChanged(book, current *models.Book) bool {
+	return !sameInts(book.CategoryIDs, current.CategoryIDs) || tagsChanged(book, current)
+}
+
+// tagsChanged reports whether book explicitly has other tags than current
+func tagsChanged(book, current *models.Book) bool {
+	if current == nil {
+		return len(book.Tags) > 0
+	}
+	if book.Tags == nil || len(book.Tags) != len(current.Tags) {
+		return book.Tags != nil
+	}
+	for i := range book.Tags {
+		if book.Tags[i] != current.Tags[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// attachCategories loads the category ids of books with a single query,
+// primary category first
+func attachCategories(ctx context.Context, books []models.Book) error {
+	index, placeholders, args := bookIDArgs(books)
+	for i := range books {
+		books[i].CategoryIDs = []int{books[i].CategoryID}
+	}
+	if len(books) == 0 {
+		return nil
+	}
+
+	rows, err := database.Reader(ctx).QueryContext(ctx,
+		"SELECT book_id, category_id FROM book_categories WHERE book_id IN ("+placeholders+") ORDER BY book_id, category_id", args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var bookID, categoryID int
+		if err := rows.Scan(&bookID, &categoryID); err != nil {
+			return err
+		}
+		if i, ok := index[bookID]; ok && categoryID != books[i].CategoryID {
+			books[i].CategoryIDs = append(books[i].CategoryIDs, categoryID)
+		}
+	}
+	return rows.Err()
+}
+
+// attachTags loads the tags of books with a single query, in alphabetical order
+func attachTags(ctx context.Context, books []models.Book) error {
+	index, placeholders, args := bookIDArgs(books)
+	for i := range books {
+		books[i].Tags = []string{}
+	}
+	if len(books) == 0 {
+		return nil
+	}
+
+	rows, err := database.Reader(ctx).QueryContext(ctx,
+		"SELECT bt.book_id, t.nama_tag FROM book_tags bt JOIN tags t ON t.tag_id = bt.tag_id"+
+			" WHERE bt.book_id IN ("+placeholders+") ORDER BY bt.book_id, t.nama_tag", args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var bookID int
+		var tag string
+		if err := rows.Scan(&bookID, &tag); err != nil {
+			return err
+		}
+		if i, ok := index[bookID]; ok {
+			books[i].Tags = append(books[i].Tags, tag)
+		}
+	}
+	return rows.Err()
+}
+
+// bookIDArgs indexes books by id and returns the placeholders and
+// arguments of an IN list over their ids
+func bookIDArgs(books []models.Book) (map[int]int, string, []interface{}) {
+	index := make(map[int]int, len(books))
+	ids := make([]int, len(books))
+	for i := range books {
+		index[books[i].BookID] = i
+		ids[i] = books[i].BookID
+	}
+	placeholders, args := intArgs(ids)
+	return index, placeholders, args
+}
+
+// intArgs returns "?, ?, ..." and the arguments for an IN list over ids
+func intArgs(ids []int) (string, []interface{}) {
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", "), args
+}
+
+// sameInts reports whether two id lists are equal, in order
+func sameInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// removeInt returns ids without id
+func removeInt(ids []int, id int) []int {
+	var kept []int
+	for _, v := range ids {
+		if v != id {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
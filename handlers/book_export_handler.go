@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"pojok_baca_api/database"
+	"pojok_baca_api/marc"
+	"pojok_baca_api/models"
+	"pojok_baca_api/utils"
+	"pojok_baca_api/xlsx"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// exportTimeout bounds a whole export. It is longer than the per-query
+// timeout because the rows are streamed to the client as they are read.
+const exportTimeout = 5 * time.Minute
+
+// exportColumns selects bookColumns plus the category name, in scanExportedBook order
+var exportColumns = "b." + strings.ReplaceAll(bookColumns, ", ", ", b.") + ", COALESCE(c.nama_kategori, '')"
+
+// exportHeader names the columns of CSV and XLSX exports
+var exportHeader = []string{"book_id", "judul", "isbn", "penulis", "penerbit", "publisher_id", "tahun_terbit", "sinopsis", "image_url", "category_id", "nama_kategori", "created_at", "updated_at"}
+
+// exportedBook is a book joined with the name of its category
+type exportedBook struct {
+	models.Book
+	NamaKategori string `json:"nama_kategori"`
+}
+
+func scanExportedBook(rows *sql.Rows, b *exportedBook) error {
+	return rows.Scan(&b.BookID, &b.Judul, &b.ISBN, &b.Penulis, &b.Penerbit, &b.PublisherID, &b.TahunTerbit, &b.Sinopsis, &b.ImageURL, &b.CategoryID, &b.CreatedAt, &b.UpdatedAt, &b.NamaKategori)
+}
+
+// cells returns the book's values in exportHeader order
+func (b *exportedBook) cells() []interface{} {
+	return []interface{}{b.BookID, b.Judul, b.isbn(), b.Penulis, b.Penerbit, b.publisherID(), b.TahunTerbit, b.Sinopsis, b.ImageURL, b.CategoryID, b.NamaKategori, b.CreatedAt.Format(time.RFC3339), b.UpdatedAt.Format(time.RFC3339)}
+}
+
+// isbn returns the ISBN, or "" when the book has none
+func (b *exportedBook) isbn() string {
+	if b.ISBN == nil {
+		return ""
+	}
+	return *b.ISBN
+}
+
+// publisherID returns the publisher id, or "" when the book has none
+func (b *exportedBook) publisherID() interface{} {
+	if b.PublisherID == nil {
+		return ""
+	}
+	return *b.PublisherID
+}
+
+// exportFormats maps each export format to its file extension and media type
+var exportFormats = map[string]struct{ ext, contentType string }{
+	"csv":     {"csv", "text/csv; charset=utf-8"},
+	"jsonl":   {"jsonl", "application/x-ndjson"},
+	"xlsx":    {"xlsx", xlsx.ContentType},
+	"marc":    {"mrc", marc.ContentType},
+	"marcxml": {"xml", marc.XMLContentType},
+}
+
+// ExportBooks streams the catalog as CSV, JSON Lines, XLSX or MARC 21 (ISO
+// 2709 or MARCXML; see book_marc.go for the field mapping). It accepts the
+// same filters as GetAllBooks. Rows go to the client as they are read from
+// the database, so the catalog is never held in memory.
+// GET /api/v1/books/export?format=csv|jsonl|xlsx|marc|marcxml
+func ExportBooks(c *fiber.Ctx) error {
+	format := strings.ToLower(c.Query("format", "csv"))
+	spec, ok := exportFormats[format]
+	if !ok {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "export.unsupported_format", format)
+	}
+
+	filter, invalid := parseBookFilter(c)
+	if invalid != "" {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "book.invalid_filter", invalid)
+	}
+
+	// The context outlives this handler: it is cancelled once streaming ends
+	ctx, cancel := context.WithTimeout(c.UserContext(), exportTimeout)
+
+	where, args := filter.where()
+	// Read-only query: served by a healthy read replica when configured
+	rows, err := database.Reader(ctx).QueryContext(ctx,
+		"SELECT "+exportColumns+" FROM books b LEFT JOIN categories c ON c.category_id = b.category_id"+where+filter.orderBy(), args...)
+	if err != nil {
+		cancel()
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "book.list_failed", err)
+	}
+
+	c.Attachment("pojok-baca-books-" + time.Now().Format("20060102") + "." + spec.ext)
+	c.Set(fiber.HeaderContentType, spec.contentType)
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer cancel()
+		defer rows.Close()
+
+		var err error
+		switch format {
+		case "csv":
+			err = exportCSV(w, rows)
+		case "jsonl":
+			err = exportJSONL(w, rows)
+		case "xlsx":
+			err = exportXLSX(w, rows)
+		case "marc", "marcxml":
+			err = exportMARC(w, rows, format == "marcxml")
+		}
+		if err == nil {
+			err = w.Flush()
+		}
+		// Headers are already sent, so a failure can only cut the download short
+		if err != nil {
+			log.Printf("Ekspor buku (%s) terhenti: %v", format, err)
+		}
+	})
+	return nil
+}
+
+func exportCSV(w *bufio.Writer, rows *sql.Rows) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(exportHeader); err != nil {
+		return err
+	}
+	record := make([]string, len(exportHeader))
+	for rows.Next() {
+		var book exportedBook
+		if err := scanExportedBook(rows, &book); err != nil {
+			return err
+		}
+		for i, cell := range book.cells() {
+			switch v := cell.(type) {
+			case int:
+				record[i] = strconv.Itoa(v)
+			default:
+				record[i] = csvSafe(v.(string))
+			}
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return err
+	}
+	return rows.Err()
+}
+
+// csvSafe prefixes a cell that a spreadsheet would evaluate as a formula
+// with a quote, so exported titles or synopses cannot run formulas when the
+// file is opened in Excel (CSV injection)
+func csvSafe(cell string) string {
+	if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
+
+func exportJSONL(w *bufio.Writer, rows *sql.Rows) error {
+	enc := json.NewEncoder(w) // Encode terminates every value with a newline
+	for rows.Next() {
+		var book exportedBook
+		if err := scanExportedBook(rows, &book); err != nil {
+			return err
+		}
+		if err := enc.Encode(&book); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func exportXLSX(w *bufio.Writer, rows *sql.Rows) error {
+	xw, err := xlsx.NewWriter(w, "Buku")
+	if err != nil {
+		return err
+	}
+	header := make([]interface{}, len(exportHeader))
+	for i, name := range exportHeader {
+		header[i] = name
+	}
+	if err := xw.WriteRow(header...); err != nil {
+		return err
+	}
+	for rows.Next() {
+		var book exportedBook
+		if err := scanExportedBook(rows, &book); err != nil {
+			return err
+		}
+		if err := xw.WriteRow(book.cells()...); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return xw.Close()
+}
+
+func exportMARC(w *bufio.Writer, rows *sql.Rows, asXML bool) error {
+	xw := marc.NewXMLWriter(w)
+	for rows.Next() {
+		var book exportedBook
+		if err := scanExportedBook(rows, &book); err != nil {
+			return err
+		}
+		record := bookToMARC(&book)
+		if asXML {
+			if err := xw.Write(record); err != nil {
+				return err
+			}
+			continue
+		}
+		raw, err := marc.Marshal(record)
+		if err != nil {
+			return fmt.Errorf("book %d: %w", book.BookID, err)
+		}
+		if _, err := w.Write(raw); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if asXML {
+		return xw.Close()
+	}
+	return nil
+}
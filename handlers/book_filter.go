@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// bookFilter holds the list filters shared by GetAllBooks and ExportBooks:
+//
+//	?q=          matches judul, penulis or penerbit (substring)
+//	?penulis=    matches penulis (substring)
+//	?category_id= any of the book's categories, including subcategories
+//	?publisher_id=
+//	?tahun_terbit=
+//	?tag=         comma-separated; books carrying every listed tag
+//	?sort=        rating (best rated first) or reviews (most reviewed
+//	              first); by book_id otherwise
+type bookFilter struct {
+	Search      string
+	Penulis     string
+	CategoryID  int
+	PublisherID int
+	TahunTerbit int
+	Tags        []string
+	Sort        string
+}
+
+// bookOrders maps the accepted ?sort= values to their ORDER BY terms. Books
+// without reviews sort last, as NULL sorts after every rating in DESC.
+var bookOrders = map[string]string{
+	"":        "b.book_id",
+	"rating":  bookAverageRating + " DESC, " + bookReviewCount + " DESC, b.book_id",
+	"reviews": bookReviewCount + " DESC, " + bookAverageRating + " DESC, b.book_id",
+}
+
+// parseBookFilter reads a bookFilter from the query string. It returns the
+// name of the offending parameter when a numeric filter is not a number.
+func parseBookFilter(c *fiber.Ctx) (bookFilter, string) {
+	f := bookFilter{
+		Search:  strings.TrimSpace(c.Query("q")),
+		Penulis: strings.TrimSpace(c.Query("penulis")),
+		Tags:    splitTags(c.Query("tag")),
+		Sort:    strings.TrimSpace(c.Query("sort")),
+	}
+	if _, ok := bookOrders[f.Sort]; !ok {
+		return f, "sort"
+	}
+	for name, dest := range map[string]*int{"category_id": &f.CategoryID, "publisher_id": &f.PublisherID, "tahun_terbit": &f.TahunTerbit} {
+		value := c.Query(name)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return f, name
+		}
+		*dest = n
+	}
+	return f, ""
+}
+
+// where renders the filter as a WHERE clause (empty when there is no
+// filter) over the books table aliased as b, with its arguments
+func (f bookFilter) where() (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+	if f.Search != "" {
+		like := "%" + escapeLike(f.Search) + "%"
+		conds = append(conds, "(b.judul LIKE ? OR b.penulis LIKE ? OR b.penerbit LIKE ?)")
+		args = append(args, like, like, like)
+	}
+	if f.Penulis != "" {
+		conds = append(conds, "b.penulis LIKE ?")
+		args = append(args, "%"+escapeLike(f.Penulis)+"%")
+	}
+	if f.CategoryID > 0 {
+		conds = append(conds, "b.book_id IN (SELECT bc.book_id FROM book_categories bc WHERE bc.category_id IN ("+descendantCategories+"))")
+		args = append(args, f.CategoryID)
+	}
+	if f.PublisherID > 0 {
+		conds = append(conds, "b.publisher_id = ?")
+		args = append(args, f.PublisherID)
+	}
+	if f.TahunTerbit > 0 {
+		conds = append(conds, "b.tahun_terbit = ?")
+		args = append(args, f.TahunTerbit)
+	}
+	if len(f.Tags) > 0 {
+		conds = append(conds, "b.book_id IN (SELECT bt.book_id FROM book_tags bt JOIN tags t ON t.tag_id = bt.tag_id"+
+			" WHERE t.nama_tag IN ("+strings.TrimSuffix(strings.Repeat("?, ", len(f.Tags)), ", ")+") GROUP BY bt.book_id HAVING COUNT(*) = ?)")
+		for _, tag := range f.Tags {
+			args = append(args, tag)
+		}
+		args = append(args, len(f.Tags))
+	}
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// orderBy renders the ORDER BY clause of the filter's sort order
+func (f bookFilter) orderBy() string {
+	return " ORDER BY " + bookOrders[f.Sort]
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// escapeLike escapes the LIKE wildcards in user input
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
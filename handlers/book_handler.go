@@ -1,45 +1,232 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
+	"encoding/json"
+	"errors"
 	"github.com/gofiber/fiber/v2"
+	"pojok_baca_api/cache"
 	"pojok_baca_api/database"
+	"pojok_baca_api/enrichment"
+	"pojok_baca_api/metrics"
 	"pojok_baca_api/models"
+	"pojok_baca_api/recommend"
 	"pojok_baca_api/utils"
 	"strconv" // For converting string to int
+	"strings"
+	"time"
 )
 
-// GetAllBooks gets all books from the database
-// GET /api/v1/books
-func GetAllBooks(c *fiber.Ctx) error {
-	rows, err := database.DB.Query("SELECT book_id, judul, penulis, penerbit, tahun_terbit, sinopsis, image_url, category_id FROM books")
+// bookColumns is the column list shared by every book SELECT, in scanBook order
+const bookColumns = "book_id, judul, isbn, penulis, penerbit, publisher_id, tahun_terbit, sinopsis, image_url, category_id, created_at, updated_at"
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanBook scans a row selected with bookColumns into a Book
+func scanBook(row rowScanner, book *models.Book) error {
+	return row.Scan(bookDest(book)...)
+}
+
+// bookDest returns the Scan destinations of bookColumns, for queries that
+// select further columns after them
+func bookDest(book *models.Book) []interface{} {
+	return []interface{}{&book.BookID, &book.Judul, &book.ISBN, &book.Penulis, &book.Penerbit, &book.PublisherID, &book.TahunTerbit, &book.Sinopsis, &book.ImageURL, &book.CategoryID, &book.CreatedAt, &book.UpdatedAt}
+}
+
+// prefixedBookColumns is bookColumns qualified with the books alias b, for joins
+var prefixedBookColumns = "b." + strings.ReplaceAll(bookColumns, ", ", ", b.")
+
+// queryBooks loads every book matching filter. It never returns a nil slice
+// so an empty catalog serializes as [].
+func queryBooks(ctx context.Context, filter bookFilter) ([]models.Book, error) {
+	where, args := filter.where()
+	// Read-only query: served by a healthy read replica when configured
+	rows, err := database.Reader(ctx).QueryContext(ctx, "SELECT "+bookColumns+" FROM books b"+where+filter.orderBy(), args...)
 	if err != nil {
-		return utils.ErrorResponse(c, fiber.StatusInternalServerError, fmt.Sprintf("Failed to retrieve books: %v", err))
+		return nil, err
 	}
 	defer rows.Close()
 
-	var books []models.Book
+	books := []models.Book{}
 	for rows.Next() {
 		var book models.Book
 		// Scan into book struct, including the image_url field
-		if err := rows.Scan(&book.BookID, &book.Judul, &book.Penulis, &book.Penerbit, &book.TahunTerbit, &book.Sinopsis, &book.ImageURL, &book.CategoryID); err != nil {
-			return utils.ErrorResponse(c, fiber.StatusInternalServerError, fmt.Sprintf("Failed to scan book data: %v", err))
+		if err := scanBook(rows, &book); err != nil {
+			return nil, err
 		}
 		books = append(books, book)
 	}
 
 	// Check for any errors during row iteration
-	if err = rows.Err(); err != nil {
-		return utils.ErrorResponse(c, fiber.StatusInternalServerError, fmt.Sprintf("Error during rows iteration: %v", err))
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return books, attachRelations(ctx, books)
+}
+
+// queryBookByID loads a single book; it returns sql.ErrNoRows when it does not exist
+func queryBookByID(ctx context.Context, id int) (*models.Book, error) {
+	book := new(models.Book)
+	err := scanBook(database.Reader(ctx).QueryRowContext(ctx, "SELECT "+bookColumns+" FROM books WHERE book_id = ?", id), book)
+	if err != nil {
+		return nil, err
+	}
+	return book, attachBookRelations(ctx, book)
+}
+
+// queryBookByISBN loads the book with a normalized ISBN-13; it returns
+// sql.ErrNoRows when there is none
+func queryBookByISBN(ctx context.Context, isbn string) (*models.Book, error) {
+	book := new(models.Book)
+	err := scanBook(database.Reader(ctx).QueryRowContext(ctx, "SELECT "+bookColumns+" FROM books WHERE isbn = ?", isbn), book)
+	if err != nil {
+		return nil, err
+	}
+	return book, attachBookRelations(ctx, book)
+}
+
+// attachRelations loads the authors, categories, tags and ratings of books
+func attachRelations(ctx context.Context, books []models.Book) error {
+	if err := attachAuthors(ctx, books); err != nil {
+		return err
+	}
+	if err := attachCategories(ctx, books); err != nil {
+		return err
+	}
+	if err := attachTags(ctx, books); err != nil {
+		return err
+	}
+	return attachRatings(ctx, books)
+}
+
+// attachBookRelations loads the authors, categories, tags and ratings of a single book
+func attachBookRelations(ctx context.Context, book *models.Book) error {
+	books := []models.Book{*book}
+	if err := attachRelations(ctx, books); err != nil {
+		return err
+	}
+	*book = books[0]
+	return nil
+}
+
+// normalizeBookISBN stores a valid ISBN as ISBN-13 without hyphens and an
+// empty one as nil; invalid ISBNs are left for validation to report
+func normalizeBookISBN(book *models.Book) {
+	if book.ISBN == nil {
+		return
+	}
+	if strings.TrimSpace(*book.ISBN) == "" {
+		book.ISBN = nil
+		return
+	}
+	if isbn, ok := utils.NormalizeISBN(*book.ISBN); ok {
+		book.ISBN = &isbn
+	}
+}
+
+// respondDuplicateISBN answers 409 Conflict with the book that already has isbn
+func respondDuplicateISBN(c *fiber.Ctx, ctx context.Context, isbn string) error {
+	existing, err := queryBookByISBN(database.WithPrimary(ctx), isbn)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusConflict, "book.duplicate_isbn")
+	}
+	c.Set(fiber.HeaderETag, bookETag(existing))
+	return utils.JSONResponse(c, fiber.StatusConflict, "book.duplicate_isbn", existing)
+}
+
+// bookETag computes the ETag of a book's JSON representation, matching the
+// ETag GetBookByID sends for the same data
+func bookETag(book *models.Book) string {
+	payload, _ := json.Marshal(book)
+	return utils.ETag(payload)
+}
+
+// respondWithBook reloads a book from the primary after a write and sends it
+// with its new ETag, so clients can chain conditional requests
+func respondWithBook(c *fiber.Ctx, ctx context.Context, status int, message string, id int) error {
+	book, err := queryBookByID(database.WithPrimary(ctx), id)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "book.get_failed", err)
+	}
+	c.Set(fiber.HeaderETag, bookETag(book))
+	return utils.JSONResponse(c, status, message, book)
+}
+
+// invalidateBookCache drops the cached lists, ISBN lookups, author lists,
+// tag cloud, category aggregates and recommendations (writing a book can
+// link or create authors and tags, and changes book counts) and, if id > 0,
+// the cached book
+func invalidateBookCache(ctx context.Context, id int) {
+	keys := []string{cache.AllBookLists, cache.AllBookISBNs, cache.AllAuthorLists, cache.AllAuthorBooks, cache.AllTagLists, cache.AllCategoryAggregates, cache.AllSimilarBooks, cache.PopularBooksKey}
+	if id > 0 {
+		keys = append(keys, cache.BookKey(id), cache.BookReviewsKey(id))
+	}
+	cache.Default.Invalidate(ctx, keys...)
+}
+
+// GetAllBooks gets all books from the database (or the catalog cache),
+// optionally filtered and sorted (see bookFilter)
+// GET /api/v1/books
+func GetAllBooks(c *fiber.Ctx) error {
+	filter, invalid := parseBookFilter(c)
+	if invalid != "" {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "book.invalid_filter", invalid)
+	}
+
+	ctx, cancel := database.WithTimeout(c.UserContext())
+	defer cancel()
+
+	books, err := cache.Default.GetOrLoad(ctx, "books", cache.BookListKey(normalizedQuery(c)), func(ctx context.Context) (interface{}, error) {
+		return queryBooks(ctx, filter)
+	})
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "book.list_failed", err)
+	}
+
+	// Clients holding the same list get 304 Not Modified
+	if utils.NotModified(c, utils.ETag(books), time.Time{}) {
+		return c.SendStatus(fiber.StatusNotModified)
 	}
 
 	// If no books are found, return an empty array with a success status
-	if len(books) == 0 {
-		return utils.JSONResponse(c, fiber.StatusOK, "No books found", []models.Book{})
+	if isEmptyJSONArray(books) {
+		return utils.JSONResponse(c, fiber.StatusOK, "book.list_empty", []models.Book{})
+	}
+
+	return utils.JSONResponse(c, fiber.StatusOK, "book.list_success", books)
+}
+
+// GetBookByISBN looks a book up by its ISBN-10 or ISBN-13, with or without hyphens
+// GET /api/v1/books/isbn/:isbn
+func GetBookByISBN(c *fiber.Ctx) error {
+	isbn, ok := utils.NormalizeISBN(c.Params("isbn"))
+	if !ok {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "book.invalid_isbn")
+	}
+
+	ctx, cancel := database.WithTimeout(c.UserContext())
+	defer cancel()
+
+	book, err := cache.Default.GetOrLoad(ctx, "books", cache.BookISBNKey(isbn), func(ctx context.Context) (interface{}, error) {
+		return queryBookByISBN(ctx, isbn)
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return utils.ErrorResponse(c, fiber.StatusNotFound, "book.not_found")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "book.get_failed", err)
+	}
+
+	// No Last-Modified: the ratings change without touching books.updated_at
+	if utils.NotModified(c, utils.ETag(book), time.Time{}) {
+		return c.SendStatus(fiber.StatusNotModified)
 	}
 
-	return utils.JSONResponse(c, fiber.StatusOK, "Books retrieved successfully", books)
+	return utils.JSONResponse(c, fiber.StatusOK, "book.get_success", book)
 }
 
 // GetBookByID gets a single book by its ID
@@ -47,92 +234,392 @@ func GetAllBooks(c *fiber.Ctx) error {
 func GetBookByID(c *fiber.Ctx) error {
 	id, err := strconv.Atoi(c.Params("id")) // Get ID from URL parameter and convert to int
 	if err != nil {
-		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Invalid book ID")
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "book.invalid_id")
 	}
 
-	book := new(models.Book)
-	// Query a single book by ID
-	err = database.DB.QueryRow("SELECT book_id, judul, penulis, penerbit, tahun_terbit, sinopsis, image_url, category_id FROM books WHERE book_id = ?", id).
-		Scan(&book.BookID, &book.Judul, &book.Penulis, &book.Penerbit, &book.TahunTerbit, &book.Sinopsis, &book.ImageURL, &book.CategoryID)
+	ctx, cancel := database.WithTimeout(c.UserContext())
+	defer cancel()
+
+	// Query a single book by ID (not-found results are not cached)
+	book, err := cache.Default.GetOrLoad(ctx, "books", cache.BookKey(id), func(ctx context.Context) (interface{}, error) {
+		return queryBookByID(ctx, id)
+	})
 	if err != nil {
 		// Handle case where book is not found
-		if err == sql.ErrNoRows {
-			return utils.ErrorResponse(c, fiber.StatusNotFound, "Book not found")
+		if errors.Is(err, sql.ErrNoRows) {
+			return utils.ErrorResponse(c, fiber.StatusNotFound, "book.not_found")
 		}
 		// Handle other database errors
-		return utils.ErrorResponse(c, fiber.StatusInternalServerError, fmt.Sprintf("Failed to retrieve book: %v", err))
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "book.get_failed", err)
 	}
 
-	return utils.JSONResponse(c, fiber.StatusOK, "Book retrieved successfully", book)
+	// Honor If-None-Match; no Last-Modified, as the ratings change without
+	// touching books.updated_at
+	if utils.NotModified(c, utils.ETag(book), time.Time{}) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	// Views of signed-in users feed the recommendations; revalidations
+	// are not views
+	if user := currentUser(c); user != nil {
+		recommend.RecordView(user.UserID, id)
+	}
+
+	return utils.JSONResponse(c, fiber.StatusOK, "book.get_success", book)
 }
 
-// CreateBook adds a new book to the database
+// CreateBook adds a new book to the database. With ?enrich=field,... (or
+// ?enrich=all) the listed fields are taken from the metadata providers,
+// looked up by the book's ISBN or, without one, its title.
 // POST /api/v1/books
-func CreateBook(c *fiber.Ctx) error {
-	book := new(models.Book)
+func CreateBook(enricher *enrichment.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		book := new(models.Book)
 
-	// Parse request body into Book struct
-	if err := c.BodyParser(book); err != nil {
-		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Invalid request body")
+		// Parse request body into Book struct
+		if err := c.BodyParser(book); err != nil {
+			return utils.ErrorResponse(c, fiber.StatusBadRequest, "common.invalid_body")
+		}
+
+		if enrich := c.Query("enrich"); enrich != "" {
+			accept, unknown := acceptedFields(enrich)
+			if unknown != "" {
+				return utils.ErrorResponse(c, fiber.StatusBadRequest, "enrichment.invalid_field", unknown)
+			}
+			normalizeBookISBN(book)
+			q := enrichment.Query{Title: strings.TrimSpace(book.Judul)}
+			if book.ISBN != nil {
+				q = enrichment.Query{ISBN: *book.ISBN}
+			}
+			if q.ISBN == "" && q.Title == "" {
+				return utils.ErrorResponse(c, fiber.StatusBadRequest, "enrichment.missing_query")
+			}
+			proposal, err := lookupMetadata(c.UserContext(), enricher, q)
+			if err != nil {
+				return enrichmentError(c, err)
+			}
+			if err := applyProposal(book, proposal, accept); err != nil {
+				return utils.ErrorResponse(c, fiber.StatusInternalServerError, "enrichment.failed", err)
+			}
+		}
+
+		return createBook(c, book)
 	}
+}
+
+// createBook validates and inserts a parsed book
+func createBook(c *fiber.Ctx, book *models.Book) error {
+	normalizeBookISBN(book)
+
+	ctx, cancel := database.WithTimeout(c.UserContext())
+	defer cancel()
 
-	// Basic validation for required fields
-	if book.Judul == "" || book.Penulis == "" || book.Penerbit == "" || book.TahunTerbit == 0 || book.CategoryID == 0 {
-		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Judul, Penulis, Penerbit, Tahun Terbit, and Category ID are required")
+	// Validate fields against the struct tags on models.Book; linked authors
+	// and the publisher must exist and provide penulis and penerbit
+	if errs, err := validateBook(ctx, book, nil); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "book.create_failed", err)
+	} else if errs != nil {
+		return utils.ValidationErrorResponse(c, errs)
+	}
+
+	// The same edition must not be catalogued twice
+	if book.ISBN != nil {
+		if _, err := queryBookByISBN(database.WithPrimary(ctx), *book.ISBN); err == nil {
+			return respondDuplicateISBN(c, ctx, *book.ISBN)
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			return utils.ErrorResponse(c, fiber.StatusInternalServerError, "book.create_failed", err)
+		}
+	}
+
+	// The book, its publisher and its author, category and tag links are written together
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "book.create_failed", err)
+	}
+	defer tx.Rollback()
+
+	if err := assignPublisher(ctx, tx, book); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "book.create_failed", err)
 	}
 
 	// Insert the new book into the database
-	result, err := database.DB.Exec(
-		"INSERT INTO books (judul, penulis, penerbit, tahun_terbit, sinopsis, image_url, category_id) VALUES (?, ?, ?, ?, ?, ?, ?)",
-		book.Judul, book.Penulis, book.Penerbit, book.TahunTerbit, book.Sinopsis, book.ImageURL, book.CategoryID,
+	result, err := tx.ExecContext(ctx,
+		"INSERT INTO books (judul, isbn, penulis, penerbit, publisher_id, tahun_terbit, sinopsis, image_url, category_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		book.Judul, book.ISBN, book.Penulis, book.Penerbit, book.PublisherID, book.TahunTerbit, book.Sinopsis, book.ImageURL, book.CategoryID,
 	)
 	if err != nil {
-		return utils.ErrorResponse(c, fiber.StatusInternalServerError, fmt.Sprintf("Failed to create book: %v", err))
+		// Lost a race with another request creating the same ISBN
+		if isDuplicateKey(err) && book.ISBN != nil {
+			return respondDuplicateISBN(c, ctx, *book.ISBN)
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "book.create_failed", err)
 	}
 
 	// Get the ID of the newly inserted book
 	id, _ := result.LastInsertId()
-	book.BookID = int(id) // Set the newly generated ID back to the struct for response
+	if err := linkBookRelations(ctx, tx, int(id), book, nil); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "book.create_failed", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "book.create_failed", err)
+	}
 
-	return utils.JSONResponse(c, fiber.StatusCreated, "Book created successfully", book)
+	metrics.BooksCreated.Inc()
+	invalidateBookCache(ctx, 0)
+
+	// Return the new book with its timestamps
+	return respondWithBook(c, ctx, fiber.StatusCreated, "book.created", int(id))
+}
+
+// validateBook checks a book against its struct tags, its linked authors,
+// its publisher and its categories. current is the stored version, nil for
+// a new book.
+func validateBook(ctx context.Context, book, current *models.Book) ([]utils.FieldError, error) {
+	normalizeBookTags(book)
+	authorErrs, err := resolveBookAuthors(database.WithPrimary(ctx), book)
+	if err != nil {
+		return nil, err
+	}
+	publisherErrs, err := resolveBookPublisher(database.WithPrimary(ctx), book, current)
+	if err != nil {
+		return nil, err
+	}
+	categoryErrs, err := resolveBookCategories(database.WithPrimary(ctx), book, current)
+	if err != nil {
+		return nil, err
+	}
+	errs := append(append(append(authorErrs, publisherErrs...), categoryErrs...), utils.ValidateStruct(book)...)
+	if len(errs) == 0 {
+		return nil, nil
+	}
+	return errs, nil
+}
+
+// linkBookAuthors updates the author links of a written book. Explicitly
+// given authors replace the links when they differ from the current ones;
+// otherwise a changed penulis text is linked by name. current is nil for a
+// new book.
+func linkBookAuthors(ctx context.Context, db dbExecutor, id int, book, current *models.Book) error {
+	if authorsChanged(book, current) {
+		return setBookAuthors(ctx, db, id, book.Authors)
+	}
+	if current == nil || current.Penulis != book.Penulis {
+		return linkAuthorsByName(ctx, db, id, book.Penulis)
+	}
+	return nil
+}
+
+// linkBookRelations updates the author, category and tag links of a written
+// book; current is nil for a new book
+func linkBookRelations(ctx context.Context, db dbExecutor, id int, book, current *models.Book) error {
+	if err := linkBookAuthors(ctx, db, id, book, current); err != nil {
+		return err
+	}
+	return linkBookClassification(ctx, db, id, book, current)
+}
+
+// authorsChanged reports whether book explicitly links other authors than current
+func authorsChanged(book, current *models.Book) bool {
+	if current == nil {
+		return len(book.Authors) > 0
+	}
+	return book.Authors != nil && !sameAuthors(current.Authors, book.Authors)
 }
 
-// UpdateBook updates an existing book in the database
+// UpdateBook updates an existing book in the database. Without "authors"
+// in the body, the author links follow a changed penulis text; likewise the
+// publisher follows penerbit unless publisher_id changes. Omitted
+// category_ids and tags keep the current ones.
 // PUT /api/v1/books/:id
 func UpdateBook(c *fiber.Ctx) error {
 	id, err := strconv.Atoi(c.Params("id")) // Get ID from URL parameter
 	if err != nil {
-		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Invalid book ID")
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "book.invalid_id")
 	}
 
 	book := new(models.Book)
 	// Parse request body for updated book data
 	if err := c.BodyParser(book); err != nil {
-		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Invalid request body")
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "common.invalid_body")
 	}
 
-	// Basic validation for required fields
-	if book.Judul == "" || book.Penulis == "" || book.Penerbit == "" || book.TahunTerbit == 0 || book.CategoryID == 0 {
-		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Judul, Penulis, Penerbit, Tahun Terbit, and Category ID are required")
+	normalizeBookISBN(book)
+
+	ctx, cancel := database.WithTimeout(c.UserContext())
+	defer cancel()
+
+	// The current version tells whether the author and publisher links need to change
+	current, err := queryBookByID(database.WithPrimary(ctx), id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return utils.ErrorResponse(c, fiber.StatusNotFound, "book.not_found")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "book.get_failed", err)
+	}
+
+	// Validate fields against the struct tags on models.Book, the linked authors and the publisher
+	if errs, err := validateBook(ctx, book, current); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "book.update_failed", err)
+	} else if errs != nil {
+		return utils.ValidationErrorResponse(c, errs)
+	}
+
+	// Optimistic concurrency: with If-Match the update only applies to the version the client saw
+	if utils.HasIfMatch(c) && utils.PreconditionFailed(c, bookETag(current)) {
+		return utils.ErrorResponse(c, fiber.StatusPreconditionFailed, "common.precondition_failed")
+	}
+
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "book.update_failed", err)
+	}
+	defer tx.Rollback()
+
+	if err := assignPublisher(ctx, tx, book); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "book.update_failed", err)
+	}
+
+	query := "UPDATE books SET judul = ?, isbn = ?, penulis = ?, penerbit = ?, publisher_id = ?, tahun_terbit = ?, sinopsis = ?, image_url = ?, category_id = ?, updated_at = CURRENT_TIMESTAMP(6) WHERE book_id = ?"
+	args := []interface{}{book.Judul, book.ISBN, book.Penulis, book.Penerbit, book.PublisherID, book.TahunTerbit, book.Sinopsis, book.ImageURL, book.CategoryID, id}
+
+	// Guard against a concurrent change between the read above and this update
+	if utils.HasIfMatch(c) {
+		query += " AND updated_at = ?"
+		args = append(args, current.UpdatedAt)
 	}
 
 	// Update the book in the database
-	res, err := database.DB.Exec(
-		"UPDATE books SET judul = ?, penulis = ?, penerbit = ?, tahun_terbit = ?, sinopsis = ?, image_url = ?, category_id = ? WHERE book_id = ?",
-		book.Judul, book.Penulis, book.Penerbit, book.TahunTerbit, book.Sinopsis, book.ImageURL, book.CategoryID, id,
-	)
+	res, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		if isDuplicateKey(err) && book.ISBN != nil {
+			return respondDuplicateISBN(c, ctx, *book.ISBN)
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "book.update_failed", err)
+	}
+
+	// updated_at always changes, so no affected rows means the book is gone
+	// or, with If-Match, that someone else changed it first
+	rowsAffected, _ := res.RowsAffected()
+	if rowsAffected == 0 {
+		if utils.HasIfMatch(c) {
+			return utils.ErrorResponse(c, fiber.StatusPreconditionFailed, "common.precondition_failed")
+		}
+		return utils.ErrorResponse(c, fiber.StatusNotFound, "book.not_found")
+	}
+
+	if err := linkBookRelations(ctx, tx, id, book, current); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "book.update_failed", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "book.update_failed", err)
+	}
+
+	invalidateBookCache(ctx, id)
+
+	return respondWithBook(c, ctx, fiber.StatusOK, "book.updated", id)
+}
+
+// PatchBook partially updates a book. The body is a JSON Merge Patch
+// (application/merge-patch+json) or a JSON Patch (application/json-patch+json)
+// applied to the current book; only columns that actually change are written.
+// PATCH /api/v1/books/:id
+func PatchBook(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id")) // Get ID from URL parameter
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "book.invalid_id")
+	}
+
+	ctx, cancel := database.WithTimeout(c.UserContext())
+	defer cancel()
+
+	// Patch against the latest version on the primary
+	current, err := queryBookByID(database.WithPrimary(ctx), id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return utils.ErrorResponse(c, fiber.StatusNotFound, "book.not_found")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "book.get_failed", err)
+	}
+	if utils.PreconditionFailed(c, bookETag(current)) {
+		return utils.ErrorResponse(c, fiber.StatusPreconditionFailed, "common.precondition_failed")
+	}
+
+	original, _ := json.Marshal(current)
+	patched, perr := applyPatch(c, original)
+	if perr != nil {
+		if perr.err != nil {
+			return utils.ErrorResponse(c, perr.status, perr.message, perr.err)
+		}
+		return utils.ErrorResponse(c, perr.status, perr.message)
+	}
+
+	book := new(models.Book)
+	if err := json.Unmarshal(patched, book); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusUnprocessableEntity, "patch.invalid_result", err)
+	}
+	normalizeBookISBN(book)
+
+	// Validate the merged result, not just the patch
+	if errs, err := validateBook(ctx, book, current); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "book.update_failed", err)
+	} else if errs != nil {
+		return utils.ValidationErrorResponse(c, errs)
+	}
+
+	tx, err := database.DB.BeginTx(ctx, nil)
 	if err != nil {
-		return utils.ErrorResponse(c, fiber.StatusInternalServerError, fmt.Sprintf("Failed to update book: %v", err))
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "book.update_failed", err)
+	}
+	defer tx.Rollback()
+
+	// A changed penerbit is linked first, so its stored name is what gets compared
+	if err := assignPublisher(ctx, tx, book); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "book.update_failed", err)
+	}
+
+	// book_id and the timestamps are managed by the server
+	columns, values := changedColumns(current, book, "book_id", "created_at", "updated_at")
+	if len(columns) == 0 && !authorsChanged(book, current) && !classificationChanged(book, current) {
+		c.Set(fiber.HeaderETag, bookETag(current))
+		return utils.JSONResponse(c, fiber.StatusOK, "book.updated", current)
+	}
+
+	// A change of the author, category or tag links alone still bumps updated_at, and so the ETag
+	set := "updated_at = CURRENT_TIMESTAMP(6)"
+	if len(columns) > 0 {
+		set = setClause(columns) + ", " + set
+	}
+
+	// The updated_at guard makes read-patch-write atomic against concurrent changes
+	query := "UPDATE books SET " + set + " WHERE book_id = ? AND updated_at = ?"
+	args := append(values, id, current.UpdatedAt)
+
+	res, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		if isDuplicateKey(err) && book.ISBN != nil {
+			return respondDuplicateISBN(c, ctx, *book.ISBN)
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "book.update_failed", err)
 	}
 
-	// Check if any rows were affected (meaning book was found and updated)
 	rowsAffected, _ := res.RowsAffected()
 	if rowsAffected == 0 {
-		return utils.ErrorResponse(c, fiber.StatusNotFound, "Book not found or no changes made")
+		if utils.HasIfMatch(c) {
+			return utils.ErrorResponse(c, fiber.StatusPreconditionFailed, "common.precondition_failed")
+		}
+		return utils.ErrorResponse(c, fiber.StatusConflict, "common.concurrent_update")
 	}
 
-	book.BookID = id // Set the ID back to the struct for response
-	return utils.JSONResponse(c, fiber.StatusOK, "Book updated successfully", book)
+	if err := linkBookRelations(ctx, tx, id, book, current); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "book.update_failed", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "book.update_failed", err)
+	}
+
+	invalidateBookCache(ctx, id)
+
+	return respondWithBook(c, ctx, fiber.StatusOK, "book.updated", id)
 }
 
 // DeleteBook deletes a book from the database
@@ -140,20 +627,47 @@ func UpdateBook(c *fiber.Ctx) error {
 func DeleteBook(c *fiber.Ctx) error {
 	id, err := strconv.Atoi(c.Params("id")) // Get ID from URL parameter
 	if err != nil {
-		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Invalid book ID")
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "book.invalid_id")
+	}
+
+	ctx, cancel := database.WithTimeout(c.UserContext())
+	defer cancel()
+
+	query := "DELETE FROM books WHERE book_id = ?"
+	args := []interface{}{id}
+
+	// Optimistic concurrency: only delete the version the client saw
+	if utils.HasIfMatch(c) {
+		current, err := queryBookByID(database.WithPrimary(ctx), id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return utils.ErrorResponse(c, fiber.StatusNotFound, "book.not_found")
+			}
+			return utils.ErrorResponse(c, fiber.StatusInternalServerError, "book.get_failed", err)
+		}
+		if utils.PreconditionFailed(c, bookETag(current)) {
+			return utils.ErrorResponse(c, fiber.StatusPreconditionFailed, "common.precondition_failed")
+		}
+		query += " AND updated_at = ?"
+		args = append(args, current.UpdatedAt)
 	}
 
 	// Delete the book from the database
-	res, err := database.DB.Exec("DELETE FROM books WHERE book_id = ?", id)
+	res, err := database.DB.ExecContext(ctx, query, args...)
 	if err != nil {
-		return utils.ErrorResponse(c, fiber.StatusInternalServerError, fmt.Sprintf("Failed to delete book: %v", err))
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "book.delete_failed", err)
 	}
 
 	// Check if any rows were affected (meaning book was found and deleted)
 	rowsAffected, _ := res.RowsAffected()
 	if rowsAffected == 0 {
-		return utils.ErrorResponse(c, fiber.StatusNotFound, "Book not found")
+		if utils.HasIfMatch(c) {
+			return utils.ErrorResponse(c, fiber.StatusPreconditionFailed, "common.precondition_failed")
+		}
+		return utils.ErrorResponse(c, fiber.StatusNotFound, "book.not_found")
 	}
 
-	return utils.JSONResponse(c, fiber.StatusOK, "Book deleted successfully", nil) // Return nil data for successful deletion
+	invalidateBookCache(ctx, id)
+
+	return utils.JSONResponse(c, fiber.StatusOK, "book.deleted", nil) // Return nil data for successful deletion
 }
\ No newline at end of file
@@ -0,0 +1,493 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"pojok_baca_api/database"
+	"pojok_baca_api/metrics"
+	"pojok_baca_api/models"
+	"pojok_baca_api/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// maxImportRows bounds a single import so one request cannot hold a
+// transaction open for an unbounded number of inserts
+const maxImportRows = 5000
+
+// importTimeout bounds a whole import. It is longer than the per-query
+// timeout because every row issues several queries in one transaction.
+const importTimeout = 5 * time.Minute
+
+const (
+	importFormatCSV     = "csv"
+	importFormatJSONL   = "jsonl"
+	importFormatMARC    = "marc"
+	importFormatMARCXML = "marcxml"
+)
+
+// importColumns maps accepted column names (CSV headers or JSON keys,
+// case-insensitive) to the book field they fill
+var importColumns = map[string]string{
+	"judul":         "judul",
+	"title":         "judul",
+	"isbn":          "isbn",
+	"penulis":       "penulis",
+	"author":        "penulis",
+	"penerbit":      "penerbit",
+	"publisher":     "penerbit",
+	"tahun_terbit":  "tahun_terbit",
+	"year":          "tahun_terbit",
+	"sinopsis":      "sinopsis",
+	"synopsis":      "sinopsis",
+	"image_url":     "image_url",
+	"category_id":   "category_id",
+	"kategori":      "kategori",
+	"category":      "kategori",
+	"nama_kategori": "kategori",
+	"tags":          "tags",
+	"tag":           "tags",
+}
+
+// importRecord is one parsed input row: its row number in the file, its
+// values keyed by lowercased column name and, for MARC, the ignored fields
+type importRecord struct {
+	row      int
+	fields   map[string]string
+	unmapped []string
+}
+
+// importRow is one line of the import report
+type importRow struct {
+	Row      int                `json:"row"`
+	Status   string             `json:"status"` // valid, invalid or created
+	Errors   []utils.FieldError `json:"errors,omitempty"`
+	Book     models.Book        `json:"book"`
+	Category string             `json:"kategori,omitempty"`
+	Unmapped []string           `json:"unmapped_fields,omitempty"` // MARC fields that were ignored
+}
+
+// importReport is returned by ImportBooks, both for dry runs and real imports
+type importReport struct {
+	DryRun            bool        `json:"dry_run"`
+	Total             int         `json:"total"`
+	Valid             int         `json:"valid"`
+	Invalid           int         `json:"invalid"`
+	Created           int         `json:"created"`
+	CategoriesCreated []string    `json:"categories_created"`
+	Rows              []importRow `json:"rows"`
+}
+
+// ImportBooks creates many books at once from CSV, JSON Lines or MARC 21
+// (ISO 2709 or MARCXML; see book_marc.go for the field mapping).
+// Categories can be given by id (category_id) or by name (kategori); with
+// ?create_categories=true unknown names are created. A tags column holds
+// comma- or semicolon-separated tags. With ?dry_run=true the
+// rows are only validated and a report is returned. Otherwise every row is
+// inserted in one transaction, and nothing is written if any row is invalid.
+// POST /api/v1/books/import
+func ImportBooks(c *fiber.Ctx) error {
+	format, data, err := readImportBody(c)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "import.read_failed", err)
+	}
+	if format == "" {
+		return utils.ErrorResponse(c, fiber.StatusUnsupportedMediaType, "import.unsupported_format")
+	}
+
+	var records []importRecord
+	switch format {
+	case importFormatCSV:
+		records, err = parseImportCSV(data)
+	case importFormatJSONL:
+		records, err = parseImportJSONL(data)
+	default:
+		records, err = parseImportMARC(data, format == importFormatMARCXML)
+	}
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "import.read_failed", err)
+	}
+	if len(records) == 0 {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "import.empty")
+	}
+	if len(records) > maxImportRows {
+		return utils.ErrorResponse(c, fiber.StatusRequestEntityTooLarge, "import.too_many_rows", maxImportRows)
+	}
+
+	ctx, cancel := context.WithTimeout(c.UserContext(), importTimeout)
+	defer cancel()
+
+	// Resolve categories against the primary so names created moments ago are seen
+	categories, err := queryCategories(database.WithPrimary(ctx))
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "category.list_failed", err)
+	}
+	byName := make(map[string]int, len(categories))
+	byID := make(map[int]bool, len(categories))
+	for _, category := range categories {
+		byName[strings.ToLower(strings.TrimSpace(category.NamaKategori))] = category.CategoryID
+		byID[category.CategoryID] = true
+	}
+
+	// ISBNs already catalogued, plus those claimed by earlier rows of this import
+	isbns, err := queryISBNs(database.WithPrimary(ctx))
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "book.list_failed", err)
+	}
+
+	createCategories := c.QueryBool("create_categories")
+	report := importReport{
+		DryRun:            c.QueryBool("dry_run"),
+		Total:             len(records),
+		CategoriesCreated: []string{},
+		Rows:              make([]importRow, 0, len(records)),
+	}
+	pending := map[string]bool{} // lowercased names of categories to create
+
+	for _, record := range records {
+		row := importRow{Row: record.row, Unmapped: record.unmapped}
+		row.Book, row.Category, row.Errors = bookFromImportRecord(record.fields)
+
+		newCategory := false
+		if row.Category != "" && row.Book.CategoryID == 0 {
+			name := strings.ToLower(row.Category)
+			if id, ok := byName[name]; ok {
+				row.Book.CategoryID = id
+			} else if createCategories {
+				newCategory = true
+				if !pending[name] {
+					pending[name] = true
+					report.CategoriesCreated = append(report.CategoriesCreated, row.Category)
+				}
+			} else {
+				row.Errors = append(row.Errors, utils.FieldError{Field: "kategori", Rule: "exists"})
+			}
+		} else if row.Book.CategoryID > 0 && !byID[row.Book.CategoryID] {
+			row.Errors = append(row.Errors, utils.FieldError{Field: "category_id", Rule: "exists"})
+		}
+
+		for _, fe := range utils.ValidateStruct(&row.Book) {
+			// The id of a category that is about to be created is not known yet
+			if newCategory && fe.Field == "category_id" {
+				continue
+			}
+			// Fields that failed to parse already have a more precise error
+			if hasFieldError(row.Errors, fe.Field) {
+				continue
+			}
+			row.Errors = append(row.Errors, fe)
+		}
+
+		if row.Book.ISBN != nil && !hasFieldError(row.Errors, "isbn") {
+			if isbns[*row.Book.ISBN] {
+				row.Errors = append(row.Errors, utils.FieldError{Field: "isbn", Rule: "unique"})
+			} else {
+				isbns[*row.Book.ISBN] = true
+			}
+		}
+
+		if len(row.Errors) > 0 {
+			utils.LocalizeFieldErrors(c, row.Errors)
+			row.Status = "invalid"
+			report.Invalid++
+		} else {
+			row.Status = "valid"
+			report.Valid++
+		}
+		report.Rows = append(report.Rows, row)
+	}
+
+	if report.DryRun {
+		return utils.JSONResponse(c, fiber.StatusOK, "import.dry_run", report)
+	}
+	if report.Invalid > 0 {
+		report.CategoriesCreated = []string{}
+		return utils.JSONResponse(c, fiber.StatusUnprocessableEntity, "import.invalid_rows", report)
+	}
+
+	if err := insertImportedBooks(ctx, &report, byName); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "import.failed", err)
+	}
+
+	metrics.BooksCreated.Add(float64(report.Created))
+	invalidateBookCache(ctx, 0)
+	if len(report.CategoriesCreated) > 0 {
+		invalidateCategoryCache(ctx, 0)
+	}
+
+	return utils.JSONResponse(c, fiber.StatusCreated, "import.success", report)
+}
+
+// insertImportedBooks creates the pending categories and inserts every row of
+// the report, linked to its publisher and authors, in a single transaction,
+// filling in the new ids
+func insertImportedBooks(ctx context.Context, report *importReport, byName map[string]int) error {
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, name := range report.CategoriesCreated {
+		res, err := tx.ExecContext(ctx, "INSERT INTO categories (nama_kategori) VALUES (?)", name)
+		if err != nil {
+			return err
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		byName[strings.ToLower(name)] = int(id)
+	}
+
+	stmt, err := tx.PrepareContext(ctx, "INSERT INTO books (judul, isbn, penulis, penerbit, publisher_id, tahun_terbit, sinopsis, image_url, category_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for i := range report.Rows {
+		row := &report.Rows[i]
+		book := &row.Book
+		if book.CategoryID == 0 {
+			book.CategoryID = byName[strings.ToLower(row.Category)]
+		}
+		if err := assignPublisher(ctx, tx, book); err != nil {
+			return err
+		}
+		res, err := stmt.ExecContext(ctx, book.Judul, book.ISBN, book.Penulis, book.Penerbit, book.PublisherID, book.TahunTerbit, book.Sinopsis, book.ImageURL, book.CategoryID)
+		if err != nil {
+			return err
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		book.BookID = int(id)
+		if err := linkAuthorsByName(ctx, tx, book.BookID, book.Penulis); err != nil {
+			return err
+		}
+		if err := setBookCategories(ctx, tx, book.BookID, []int{book.CategoryID}); err != nil {
+			return err
+		}
+		if err := setBookTags(ctx, tx, book.BookID, book.Tags); err != nil {
+			return err
+		}
+		row.Status = "created"
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	report.Created = len(report.Rows)
+	return nil
+}
+
+// queryISBNs returns the set of ISBNs already in the catalog
+func queryISBNs(ctx context.Context) (map[string]bool, error) {
+	rows, err := database.Reader(ctx).QueryContext(ctx, "SELECT isbn FROM books WHERE isbn IS NOT NULL")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	isbns := map[string]bool{}
+	for rows.Next() {
+		var isbn string
+		if err := rows.Scan(&isbn); err != nil {
+			return nil, err
+		}
+		isbns[isbn] = true
+	}
+	return isbns, rows.Err()
+}
+
+// readImportBody returns the import data and its format. The format comes
+// from ?format=, the Content-Type, or the extension of an uploaded "file"
+// field; it is empty when none of them is supported.
+func readImportBody(c *fiber.Ctx) (string, []byte, error) {
+	format := importFormat(c.Query("format"))
+
+	mediaType, _, _ := mime.ParseMediaType(c.Get(fiber.HeaderContentType))
+	if mediaType == fiber.MIMEMultipartForm {
+		fh, err := c.FormFile("file")
+		if err != nil {
+			return "", nil, err
+		}
+		if format == "" {
+			format = importFormat(strings.TrimPrefix(filepath.Ext(fh.Filename), "."))
+		}
+		if format == "" {
+			format = importFormat(fh.Header.Get(fiber.HeaderContentType))
+		}
+		f, err := fh.Open()
+		if err != nil {
+			return "", nil, err
+		}
+		defer f.Close()
+		data, err := io.ReadAll(f)
+		return format, data, err
+	}
+
+	if format == "" {
+		format = importFormat(mediaType)
+	}
+	return format, c.Body(), nil
+}
+
+// importFormat maps a format name, file extension or media type to an import format
+func importFormat(s string) string {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "csv", "text/csv", "application/csv":
+		return importFormatCSV
+	case "jsonl", "ndjson", "application/x-ndjson", "application/jsonl", "application/x-jsonlines":
+		return importFormatJSONL
+	case "marc", "mrc", "application/marc":
+		return importFormatMARC
+	case "marcxml", "xml", "application/marcxml+xml", "application/xml", "text/xml":
+		return importFormatMARCXML
+	}
+	return ""
+}
+
+// parseImportCSV reads a CSV file whose first row names the columns. Row
+// numbers are the file line each record starts on.
+func parseImportCSV(data []byte) ([]importRecord, error) {
+	r := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))) // tolerate a UTF-8 BOM from spreadsheet exports
+	r.TrimLeadingSpace = true
+	r.FieldsPerRecord = -1
+
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var records []importRecord
+	for {
+		fields, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := r.FieldPos(0)
+		record := importRecord{row: line, fields: make(map[string]string, len(header))}
+		for i, name := range header {
+			if i < len(fields) {
+				record.fields[strings.ToLower(strings.TrimSpace(name))] = fields[i]
+			}
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// parseImportJSONL reads one JSON object per line; blank lines are skipped
+// but still counted so row numbers match the file
+func parseImportJSONL(data []byte) ([]importRecord, error) {
+	var records []importRecord
+	for n, line := range bytes.Split(data, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(line, &object); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n+1, err)
+		}
+		record := importRecord{row: n + 1, fields: make(map[string]string, len(object))}
+		for key, raw := range object {
+			var s string
+			if json.Unmarshal(raw, &s) != nil {
+				s = string(raw) // numbers and literals keep their JSON text
+				if s == "null" {
+					s = ""
+				}
+			}
+			record.fields[strings.ToLower(key)] = s
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// bookFromImportRecord maps an import record onto a Book, returning the
+// category name (if given) and errors for values that could not be parsed
+func bookFromImportRecord(record map[string]string) (models.Book, string, []utils.FieldError) {
+	var book models.Book
+	var category string
+	var errs []utils.FieldError
+
+	for column, value := range record {
+		value = strings.TrimSpace(value)
+		switch importColumns[column] {
+		case "judul":
+			book.Judul = value
+		case "isbn":
+			if value != "" {
+				isbn := value
+				book.ISBN = &isbn
+				normalizeBookISBN(&book)
+			}
+		case "penulis":
+			book.Penulis = value
+		case "penerbit":
+			book.Penerbit = value
+		case "sinopsis":
+			book.Sinopsis = value
+		case "image_url":
+			book.ImageURL = value
+		case "kategori":
+			category = value
+		case "tags":
+			book.Tags = splitTags(strings.ReplaceAll(value, ";", ","))
+			normalizeBookTags(&book)
+		case "tahun_terbit":
+			if value == "" {
+				continue
+			}
+			year, err := strconv.Atoi(value)
+			if err != nil {
+				errs = append(errs, utils.FieldError{Field: "tahun_terbit", Rule: "integer"})
+				continue
+			}
+			book.TahunTerbit = year
+		case "category_id":
+			if value == "" {
+				continue
+			}
+			id, err := strconv.Atoi(value)
+			if err != nil {
+				errs = append(errs, utils.FieldError{Field: "category_id", Rule: "integer"})
+				continue
+			}
+			book.CategoryID = id
+		}
+	}
+	return book, category, errs
+}
+
+// hasFieldError reports whether errs already holds an error for field
+func hasFieldError(errs []utils.FieldError, field string) bool {
+	for _, fe := range errs {
+		if fe.Field == field {
+			return true
+		}
+	}
+	return false
+}
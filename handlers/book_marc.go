@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"pojok_baca_api/marc"
+)
+
+// MARC 21 mapping used by import and export:
+//
+//	001      book_id (export only)
+//	020 $a   isbn
+//	100 $a   penulis
+//	245 $a   judul ($b subtitle is appended on import)
+//	260/264  $b penerbit, $c tahun_terbit
+//	520 $a   sinopsis
+//	650 $a   kategori (category name)
+//	856 $u   image_url
+
+// marcSummaryLimit keeps the 520 field within the 9999 bytes an ISO 2709
+// directory entry can address
+const marcSummaryLimit = 9000
+
+// mappedMARCSubfields lists, per tag, the subfields the import understands
+var mappedMARCSubfields = map[string]string{
+	"020": "a",
+	"100": "a",
+	"245": "ab",
+	"260": "bc",
+	"264": "bc",
+	"520": "a",
+	"650": "a",
+	"856": "u",
+}
+
+var marcYear = regexp.MustCompile(`[0-9]{4}`)
+
+// bookToMARC builds the MARC record of an exported book
+func bookToMARC(b *exportedBook) *marc.Record {
+	r := new(marc.Record)
+	r.AddControl("001", strconv.Itoa(b.BookID))
+	r.AddControl("005", b.UpdatedAt.UTC().Format("20060102150405.0"))
+	r.AddData("020", ' ', ' ', marc.Subfield{Code: 'a', Value: b.isbn()})
+	r.AddData("100", '1', ' ', marc.Subfield{Code: 'a', Value: b.Penulis})
+	r.AddData("245", '1', '0', marc.Subfield{Code: 'a', Value: b.Judul})
+	r.AddData("264", ' ', '1',
+		marc.Subfield{Code: 'b', Value: b.Penerbit},
+		marc.Subfield{Code: 'c', Value: strconv.Itoa(b.TahunTerbit)},
+	)
+	r.AddData("520", ' ', ' ', marc.Subfield{Code: 'a', Value: truncateUTF8(b.Sinopsis, marcSummaryLimit)})
+	r.AddData("650", ' ', '4', marc.Subfield{Code: 'a', Value: b.NamaKategori})
+	r.AddData("856", '4', '0', marc.Subfield{Code: 'u', Value: b.ImageURL})
+	return r
+}
+
+// marcToImportFields maps a MARC record to import columns. It also returns
+// the fields and subfields that were ignored, as "020" or "245$c".
+func marcToImportFields(r *marc.Record) (map[string]string, []string) {
+	fields := map[string]string{}
+	unmapped := map[string]bool{}
+
+	for _, f := range r.Fields {
+		known, ok := mappedMARCSubfields[f.Tag]
+		if !ok {
+			unmapped[f.Tag] = true
+			continue
+		}
+		for _, sf := range f.Subfields {
+			if !strings.ContainsRune(known, rune(sf.Code)) {
+				unmapped[f.Tag+"$"+string(sf.Code)] = true
+			}
+		}
+	}
+
+	set := func(column, value string) {
+		if value = trimISBD(value); value != "" && fields[column] == "" {
+			fields[column] = value
+		}
+	}
+	// 020 $a may carry a qualifier after the number: "9786020331713 (pbk.)"
+	if f, ok := r.Field("020"); ok {
+		if isbn := strings.Fields(f.Subfield('a')); len(isbn) > 0 {
+			set("isbn", isbn[0])
+		}
+	}
+	if f, ok := r.Field("100"); ok {
+		set("penulis", f.Subfield('a'))
+	}
+	if f, ok := r.Field("245"); ok {
+		title := trimISBD(f.Subfield('a'))
+		if subtitle := trimISBD(f.Subfield('b')); subtitle != "" {
+			title += ": " + subtitle
+		}
+		set("judul", title)
+	}
+	// RDA records use 264, older AACR2 records 260
+	for _, tag := range []string{"264", "260"} {
+		if f, ok := r.Field(tag); ok {
+			set("penerbit", f.Subfield('b'))
+			set("tahun_terbit", marcYear.FindString(f.Subfield('c')))
+		}
+	}
+	if f, ok := r.Field("520"); ok {
+		set("sinopsis", f.Subfield('a'))
+	}
+	if f, ok := r.Field("650"); ok {
+		set("kategori", f.Subfield('a'))
+	}
+	if f, ok := r.Field("856"); ok {
+		set("image_url", f.Subfield('u'))
+	}
+
+	list := make([]string, 0, len(unmapped))
+	for tag := range unmapped {
+		list = append(list, tag)
+	}
+	sort.Strings(list)
+	return fields, list
+}
+
+// parseImportMARC reads ISO 2709 or MARCXML records; row numbers count records
+func parseImportMARC(data []byte, isXML bool) ([]importRecord, error) {
+	var read func() (*marc.Record, error)
+	if isXML {
+		read = marc.NewXMLReader(bytes.NewReader(data)).Read
+	} else {
+		read = marc.NewReader(bytes.NewReader(data)).Read
+	}
+
+	var records []importRecord
+	for n := 1; ; n++ {
+		r, err := read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %w", n, err)
+		}
+		fields, unmapped := marcToImportFields(r)
+		records = append(records, importRecord{row: n, fields: fields, unmapped: unmapped})
+	}
+}
+
+// trimISBD removes the trailing ISBD punctuation catalogers put before the
+// next subfield ("Judul /", "Penerbit,", "Penulis.")
+func trimISBD(s string) string {
+	return strings.TrimSpace(strings.TrimRight(strings.TrimSpace(s), " /:;,."))
+}
+
+// truncateUTF8 cuts s to at most n bytes without splitting a character
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && n < len(s) && s[n]&0xC0 == 0x80 {
+		n--
+	}
+	return s[:n]
+}
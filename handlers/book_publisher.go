@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"pojok_baca_api/database"
+	"pojok_baca_api/models"
+	"pojok_baca_api/utils"
+)
+
+// resolveBookPublisher decides how a written book is linked to its
+// publisher. A publisher_id that is new (for a new book, or different from
+// current) must exist and provides penerbit. Otherwise a changed penerbit
+// text drops the link so assignPublisher links the book by name.
+func resolveBookPublisher(ctx context.Context, book, current *models.Book) ([]utils.FieldError, error) {
+	changed := book.PublisherID != nil &&
+		(current == nil || current.PublisherID == nil || *current.PublisherID != *book.PublisherID)
+	if changed {
+		err := database.Reader(ctx).QueryRowContext(ctx,
+			"SELECT nama_penerbit FROM publishers WHERE publisher_id = ?", *book.PublisherID).Scan(&book.Penerbit)
+		if err == sql.ErrNoRows {
+			return []utils.FieldError{{Field: "publisher_id", Rule: "exists"}}, nil
+		}
+		return nil, err
+	}
+	if current != nil && strings.TrimSpace(book.Penerbit) != current.Penerbit {
+		book.PublisherID = nil
+	}
+	return nil, nil
+}
+
+// assignPublisher links a book without publisher_id to the publisher named
+// by its penerbit text, creating it when it does not exist yet, and replaces
+// penerbit with the publisher's stored name
+func assignPublisher(ctx context.Context, db dbExecutor, book *models.Book) error {
+	name := strings.Join(strings.Fields(book.Penerbit), " ")
+	if book.PublisherID != nil || name == "" {
+		return nil
+	}
+	// LAST_INSERT_ID(publisher_id) makes LastInsertId return the existing row's id
+	res, err := db.ExecContext(ctx,
+		"INSERT INTO publishers (nama_penerbit) VALUES (?) ON DUPLICATE KEY UPDATE publisher_id = LAST_INSERT_ID(publisher_id)", name)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	// Names match case-insensitively, so "gramedia" links to "Gramedia"
+	if err := db.QueryRowContext(ctx, "SELECT nama_penerbit FROM publishers WHERE publisher_id = ?", id).Scan(&book.Penerbit); err != nil {
+		return err
+	}
+	publisherID := int(id)
+	book.PublisherID = &publisherID
+	return nil
+}
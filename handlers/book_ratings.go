@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"context"
+
+	"pojok_baca_api/database"
+	"pojok_baca_api/models"
+)
+
+// Rating subqueries over the visible reviews of the book aliased as b, used
+// to sort book lists by rating
+const (
+	bookAverageRating = "(SELECT AVG(r.rating) FROM book_reviews r WHERE r.book_id = b.book_id AND NOT r.hidden)"
+	bookReviewCount   = "(SELECT COUNT(*) FROM book_reviews r WHERE r.book_id = b.book_id AND NOT r.hidden)"
+)
+
+// attachRatings loads the average rating and review count of books with a
+// single query. Hidden reviews are not counted.
+func attachRatings(ctx context.Context, books []models.Book) error {
+	index, placeholders, args := bookIDArgs(books)
+	for i := range books {
+		books[i].AverageRating = nil
+		books[i].ReviewCount = 0
+	}
+	if len(books) == 0 {
+		return nil
+	}
+
+	rows, err := database.Reader(ctx).QueryContext(ctx,
+		"SELECT book_id, ROUND(AVG(rating), 2), COUNT(*) FROM book_reviews"+
+			" WHERE book_id IN ("+placeholders+") AND NOT hidden GROUP BY book_id", args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var bookID, count int
+		var average float64
+		if err := rows.Scan(&bookID, &average, &count); err != nil {
+			return err
+		}
+		if i, ok := index[bookID]; ok {
+			books[i].AverageRating = &average
+			books[i].ReviewCount = count
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"pojok_baca_api/database"
+	"pojok_baca_api/models"
+)
+
+// Number of books listed per category by ?include=newest_books and sample_covers
+const (
+	categoryNewestBooks  = 5
+	categorySampleCovers = 4
+)
+
+// categoryIncludes are the aggregate fields selected with ?include=
+type categoryIncludes struct {
+	BookCount    bool
+	NewestBooks  bool
+	SampleCovers bool
+}
+
+// any reports whether any aggregate was requested
+func (inc categoryIncludes) any() bool {
+	return inc.BookCount || inc.NewestBooks || inc.SampleCovers
+}
+
+// parseCategoryIncludes parses ?include= ("all" or a comma-separated list of
+// book_count, newest_books and sample_covers). It returns the first unknown
+// name as the second result.
+func parseCategoryIncludes(value string) (categoryIncludes, string) {
+	var inc categoryIncludes
+	for _, name := range strings.Split(value, ",") {
+		switch name = strings.TrimSpace(name); name {
+		case "":
+		case "all":
+			inc = categoryIncludes{BookCount: true, NewestBooks: true, SampleCovers: true}
+		case "book_count":
+			inc.BookCount = true
+		case "newest_books":
+			inc.NewestBooks = true
+		case "sample_covers":
+			inc.SampleCovers = true
+		default:
+			return inc, name
+		}
+	}
+	return inc, ""
+}
+
+// categoryBook is a book listed among a category's newest additions
+type categoryBook struct {
+	BookID    int       `json:"book_id"`
+	Judul     string    `json:"judul"`
+	ImageURL  string    `json:"image_url"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// categoryWithAggregates is a category with the aggregate fields selected
+// by ?include=; the others are left out of the JSON
+type categoryWithAggregates struct {
+	models.Category
+	BookCount    *int            `json:"book_count,omitempty"`
+	NewestBooks  *[]categoryBook `json:"newest_books,omitempty"`
+	SampleCovers *[]string       `json:"sample_covers,omitempty"`
+}
+
+// categoryAggregates holds the aggregates of one category
+type categoryAggregates struct {
+	bookCount int
+	newest    []categoryBook
+	covers    []string
+}
+
+// categoryAggregatesQuery computes the aggregates of every category (or of
+// the one given as the optional anchor argument) in one query. A category
+// counts the books of its subcategories too, each book once. Per category it
+// returns the newest books (rn) and the newest books with a cover
+// (cover_rn), each row carrying the category's book count.
+const categoryAggregatesQuery = `WITH RECURSIVE closure AS (
+	SELECT category_id AS ancestor_id, category_id FROM categories%s
+	UNION
+	SELECT cl.ancestor_id, c.category_id FROM closure cl JOIN categories c ON c.parent_id = cl.category_id
+), pairs AS (
+	SELECT DISTINCT cl.ancestor_id, bc.book_id FROM closure cl JOIN book_categories bc ON bc.category_id = cl.category_id
+), ranked AS (
+	SELECT p.ancestor_id, b.book_id, b.judul, b.image_url, b.created_at,
+		COUNT(*) OVER (PARTITION BY p.ancestor_id) AS book_count,
+		ROW_NUMBER() OVER (PARTITION BY p.ancestor_id ORDER BY b.created_at DESC, b.book_id DESC) AS rn,
+		ROW_NUMBER() OVER (PARTITION BY p.ancestor_id ORDER BY b.image_url = '', b.created_at DESC, b.book_id DESC) AS cover_rn
+	FROM pairs p JOIN books b ON b.book_id = p.book_id
+)
+SELECT ancestor_id, book_count, book_id, judul, image_url, created_at, rn, cover_rn
+FROM ranked WHERE rn <= ? OR (cover_rn <= ? AND image_url <> '')
+ORDER BY ancestor_id, rn`
+
+// queryCategoryAggregates computes the requested aggregates of the category
+// id, or of every category when id is 0. Categories without books are
+// absent from the result.
+func queryCategoryAggregates(ctx context.Context, id int, inc categoryIncludes) (map[int]*categoryAggregates, error) {
+	anchor := ""
+	var args []interface{}
+	if id > 0 {
+		anchor = " WHERE category_id = ?"
+		args = append(args, id)
+	}
+	// At least one row per category carries its book count
+	newest, covers := 1, 0
+	if inc.NewestBooks {
+		newest = categoryNewestBooks
+	}
+	if inc.SampleCovers {
+		covers = categorySampleCovers
+	}
+	args = append(args, newest, covers)
+
+	rows, err := database.Reader(ctx).QueryContext(ctx, fmt.Sprintf(categoryAggregatesQuery, anchor), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := map[int]*categoryAggregates{}
+	for rows.Next() {
+		var categoryID, count, rn, coverRN int
+		var book categoryBook
+		if err := rows.Scan(&categoryID, &count, &book.BookID, &book.Judul, &book.ImageURL, &book.CreatedAt, &rn, &coverRN); err != nil {
+			return nil, err
+		}
+		addCategoryAggregateRow(result, categoryID, count, rn, coverRN, book, inc)
+	}
+	return result, rows.Err()
+}
+
+// addCategoryAggregateRow adds one row of categoryAggregatesQuery to the
+// aggregates of its category. A row is selected for its rn, its cover_rn or
+// both; only the requested lists take it.
+func addCategoryAggregateRow(result map[int]*categoryAggregates, categoryID, count, rn, coverRN int, book categoryBook, inc categoryIncludes) {
+	agg, ok := result[categoryID]
+	if !ok {
+		agg = &categoryAggregates{bookCount: count}
+		result[categoryID] = agg
+	}
+	if inc.NewestBooks && rn <= categoryNewestBooks {
+		agg.newest = append(agg.newest, book)
+	}
+	if inc.SampleCovers && coverRN <= categorySampleCovers && book.ImageURL != "" {
+		agg.covers = append(agg.covers, book.ImageURL)
+	}
+}
+
+// withAggregates adds the requested aggregates to a category
+func withAggregates(category models.Category, agg *categoryAggregates, inc categoryIncludes) categoryWithAggregates {
+	if agg == nil {
+		agg = &categoryAggregates{}
+	}
+	out := categoryWithAggregates{Category: category}
+	if inc.BookCount {
+		count := agg.bookCount
+		out.BookCount = &count
+	}
+	if inc.NewestBooks {
+		newest := append([]categoryBook{}, agg.newest...)
+		out.NewestBooks = &newest
+	}
+	if inc.SampleCovers {
+		// Rows come newest first, so the covers already are in cover_rn order
+		covers := append([]string{}, agg.covers...)
+		out.SampleCovers = &covers
+	}
+	return out
+}
+
+// queryCategoriesWithAggregates loads every category with the requested aggregates
+func queryCategoriesWithAggregates(ctx context.Context, inc categoryIncludes) ([]categoryWithAggregates, error) {
+	categories, err := queryCategories(ctx)
+	if err != nil {
+		return nil, err
+	}
+	aggregates, err := queryCategoryAggregates(ctx, 0, inc)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]categoryWithAggregates, len(categories))
+	for i, category := range categories {
+		result[i] = withAggregates(category, aggregates[category.CategoryID], inc)
+	}
+	return result, nil
+}
+
+// queryCategoryWithAggregates loads a single category with the requested
+// aggregates; it returns sql.ErrNoRows when it does not exist
+func queryCategoryWithAggregates(ctx context.Context, id int, inc categoryIncludes) (*categoryWithAggregates, error) {
+	category, err := queryCategoryByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	aggregates, err := queryCategoryAggregates(ctx, id, inc)
+	if err != nil {
+		return nil, err
+	}
+	result := withAggregates(*category, aggregates[id], inc)
+	return &result, nil
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"pojok_baca_api/models"
+)
+
+func TestParseCategoryIncludes(t *testing.T) {
+	tests := []struct {
+		value       string
+		want        categoryIncludes
+		wantUnknown string
+	}{
+		{"", categoryIncludes{}, ""},
+		{"book_count", categoryIncludes{BookCount: true}, ""},
+		{" book_count , newest_books ", categoryIncludes{BookCount: true, NewestBooks: true}, ""},
+		{"sample_covers,", categoryIncludes{SampleCovers: true}, ""},
+		{"all", categoryIncludes{BookCount: true, NewestBooks: true, SampleCovers: true}, ""},
+		{"newest_books,all", categoryIncludes{BookCount: true, NewestBooks: true, SampleCovers: true}, ""},
+		{"book_count,covers", categoryIncludes{BookCount: true}, "covers"},
+		// Names are case-sensitive
+		{"BOOK_COUNT", categoryIncludes{}, "BOOK_COUNT"},
+	}
+	for _, tt := range tests {
+		got, unknown := parseCategoryIncludes(tt.value)
+		if got != tt.want || unknown != tt.wantUnknown {
+			t.Errorf("parseCategoryIncludes(%q) = %+v, %q; want %+v, %q", tt.value, got, unknown, tt.want, tt.wantUnknown)
+		}
+		if got.any() != (tt.want != categoryIncludes{}) {
+			t.Errorf("parseCategoryIncludes(%q).any() = %v", tt.value, got.any())
+		}
+	}
+}
+
+// aggregateRow is a row of categoryAggregatesQuery
+type aggregateRow struct {
+	categoryID, count, rn, coverRN int
+	book                           categoryBook
+}
+
+func TestAddCategoryAggregateRow(t *testing.T) {
+	// Rows the query returns for a category with 7 books, newest first, when
+	// 5 newest books and 4 covers are requested: books 7 and 6 have no cover
+	rows := []aggregateRow{
+		{1, 7, 1, 6, categoryBook{BookID: 7}},
+		{1, 7, 2, 7, categoryBook{BookID: 6}},
+		{1, 7, 3, 1, categoryBook{BookID: 5, ImageURL: "5.jpg"}},
+		{1, 7, 4, 2, categoryBook{BookID: 4, ImageURL: "4.jpg"}},
+		{1, 7, 5, 3, categoryBook{BookID: 3, ImageURL: "3.jpg"}},
+		{1, 7, 6, 4, categoryBook{BookID: 2, ImageURL: "2.jpg"}},
+		{2, 1, 1, 1, categoryBook{BookID: 9, ImageURL: "9.jpg"}},
+	}
+
+	tests := []struct {
+		name       string
+		inc        categoryIncludes
+		wantNewest []int
+		wantCovers []string
+	}{
+		{
+			name: "count only",
+			inc:  categoryIncludes{BookCount: true},
+		},
+		{
+			name:       "newest books skip rows selected only for their cover",
+			inc:        categoryIncludes{NewestBooks: true},
+			wantNewest: []int{7, 6, 5, 4, 3},
+		},
+		{
+			name:       "covers skip books without one",
+			inc:        categoryIncludes{SampleCovers: true},
+			wantCovers: []string{"5.jpg", "4.jpg", "3.jpg", "2.jpg"},
+		},
+		{
+			name:       "both",
+			inc:        categoryIncludes{NewestBooks: true, SampleCovers: true},
+			wantNewest: []int{7, 6, 5, 4, 3},
+			wantCovers: []string{"5.jpg", "4.jpg", "3.jpg", "2.jpg"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := map[int]*categoryAggregates{}
+			for _, row := range rows {
+				addCategoryAggregateRow(result, row.categoryID, row.count, row.rn, row.coverRN, row.book, tt.inc)
+			}
+
+			if len(result) != 2 || result[1].bookCount != 7 || result[2].bookCount != 1 {
+				t.Fatalf("book counts = %+v, want 7 and 1", result)
+			}
+			var newest []int
+			for _, book := range result[1].newest {
+				newest = append(newest, book.BookID)
+			}
+			if !reflect.DeepEqual(newest, tt.wantNewest) {
+				t.Errorf("newest books = %v, want %v", newest, tt.wantNewest)
+			}
+			if !reflect.DeepEqual(result[1].covers, tt.wantCovers) {
+				t.Errorf("covers = %v, want %v", result[1].covers, tt.wantCovers)
+			}
+		})
+	}
+}
+
+func TestWithAggregates(t *testing.T) {
+	category := models.Category{CategoryID: 1, NamaKategori: "Fiksi"}
+	agg := &categoryAggregates{
+		bookCount: 2,
+		newest:    []categoryBook{{BookID: 2}, {BookID: 1}},
+		covers:    []string{"2.jpg"},
+	}
+
+	tests := []struct {
+		name string
+		agg  *categoryAggregates
+		inc  categoryIncludes
+		// wantJSON are the aggregate fields expected in the JSON
+		wantJSON map[string]string
+	}{
+		{
+			name:     "nothing requested",
+			agg:      agg,
+			inc:      categoryIncludes{},
+			wantJSON: map[string]string{},
+		},
+		{
+			name:     "count only",
+			agg:      agg,
+			inc:      categoryIncludes{BookCount: true},
+			wantJSON: map[string]string{"book_count": "2"},
+		},
+		{
+			name: "all",
+			agg:  agg,
+			inc:  categoryIncludes{BookCount: true, NewestBooks: true, SampleCovers: true},
+			wantJSON: map[string]string{
+				"book_count":    "2",
+				"newest_books":  `[{"book_id":2,"judul":"","image_url":"","created_at":"0001-01-01T00:00:00Z"},{"book_id":1,"judul":"","image_url":"","created_at":"0001-01-01T00:00:00Z"}]`,
+				"sample_covers": `["2.jpg"]`,
+			},
+		},
+		{
+			name: "category without books",
+			agg:  nil,
+			inc:  categoryIncludes{BookCount: true, NewestBooks: true, SampleCovers: true},
+			wantJSON: map[string]string{
+				"book_count":    "0",
+				"newest_books":  "[]",
+				"sample_covers": "[]",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := withAggregates(category, tt.agg, tt.inc)
+			if out.Category != category {
+				t.Errorf("category = %+v, want %+v", out.Category, category)
+			}
+
+			payload, err := json.Marshal(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var fields map[string]json.RawMessage
+			if err := json.Unmarshal(payload, &fields); err != nil {
+				t.Fatal(err)
+			}
+			got := map[string]string{}
+			for _, name := range []string{"book_count", "newest_books", "sample_covers"} {
+				if raw, ok := fields[name]; ok {
+					got[name] = strings.TrimSpace(string(raw))
+				}
+			}
+			if !reflect.DeepEqual(got, tt.wantJSON) {
+				t.Errorf("aggregate fields = %v, want %v", got, tt.wantJSON)
+			}
+		})
+	}
+}
+
+func TestWithAggregatesCopiesLists(t *testing.T) {
+	agg := &categoryAggregates{newest: []categoryBook{{BookID: 1}}, covers: []string{"1.jpg"}}
+	out := withAggregates(models.Category{CategoryID: 1}, agg, categoryIncludes{NewestBooks: true, SampleCovers: true})
+
+	(*out.NewestBooks)[0].BookID = 99
+	(*out.SampleCovers)[0] = "changed"
+	if agg.newest[0].BookID != 1 || agg.covers[0] != "1.jpg" {
+		t.Error("changing the result changed the shared aggregates")
+	}
+}
@@ -1,64 +1,174 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
+	"encoding/json"
+	"errors"
 	"github.com/gofiber/fiber/v2"
+	"pojok_baca_api/cache"
 	"pojok_baca_api/database"
 	"pojok_baca_api/models"
 	"pojok_baca_api/utils"
 	"strconv"
+	"time"
 )
 
-// GetAllCategories gets all categories from the database
-// GET /api/v1/categories
-func GetAllCategories(c *fiber.Ctx) error {
-	rows, err := database.DB.Query("SELECT category_id, nama_kategori, image_url FROM categories")
+// categoryColumns is the column list shared by every category SELECT, in scanCategory order
+const categoryColumns = "category_id, parent_id, nama_kategori, image_url, created_at, updated_at"
+
+// scanCategory scans a row selected with categoryColumns into a Category
+func scanCategory(row rowScanner, category *models.Category) error {
+	return row.Scan(&category.CategoryID, &category.ParentID, &category.NamaKategori, &category.ImageURL, &category.CreatedAt, &category.UpdatedAt)
+}
+
+// queryCategories loads every category, never returning a nil slice
+func queryCategories(ctx context.Context) ([]models.Category, error) {
+	// Read-only query: served by a healthy read replica when configured
+	rows, err := database.Reader(ctx).QueryContext(ctx, "SELECT "+categoryColumns+" FROM categories")
 	if err != nil {
-		return utils.ErrorResponse(c, fiber.StatusInternalServerError, fmt.Sprintf("Failed to retrieve categories: %v", err))
+		return nil, err
 	}
 	defer rows.Close()
 
-	var categories []models.Category
+	categories := []models.Category{}
 	for rows.Next() {
 		var category models.Category
 		// Scan into category struct, including the image_url field
-		if err := rows.Scan(&category.CategoryID, &category.NamaKategori, &category.ImageURL); err != nil {
-			return utils.ErrorResponse(c, fiber.StatusInternalServerError, fmt.Sprintf("Failed to scan category data: %v", err))
+		if err := scanCategory(rows, &category); err != nil {
+			return nil, err
 		}
 		categories = append(categories, category)
 	}
 
-	if err = rows.Err(); err != nil {
-		return utils.ErrorResponse(c, fiber.StatusInternalServerError, fmt.Sprintf("Error during rows iteration: %v", err))
+	return categories, rows.Err()
+}
+
+// queryCategoryByID loads a single category; it returns sql.ErrNoRows when it does not exist
+func queryCategoryByID(ctx context.Context, id int) (*models.Category, error) {
+	category := new(models.Category)
+	err := scanCategory(database.Reader(ctx).QueryRowContext(ctx, "SELECT "+categoryColumns+" FROM categories WHERE category_id = ?", id), category)
+	if err != nil {
+		return nil, err
+	}
+	return category, nil
+}
+
+// categoryETag computes the ETag of a category's JSON representation, matching
+// the ETag GetCategoryByID sends for the same data
+func categoryETag(category *models.Category) string {
+	payload, _ := json.Marshal(category)
+	return utils.ETag(payload)
+}
+
+// respondWithCategory reloads a category from the primary after a write and
+// sends it with its new ETag
+func respondWithCategory(c *fiber.Ctx, ctx context.Context, status int, message string, id int) error {
+	category, err := queryCategoryByID(database.WithPrimary(ctx), id)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "category.get_failed", err)
+	}
+	c.Set(fiber.HeaderETag, categoryETag(category))
+	return utils.JSONResponse(c, status, message, category)
+}
+
+// invalidateCategoryCache drops the cached lists and tree and, if id > 0,
+// the cached category and the book lists (filtering by a category includes
+// its descendants, so moving a category changes them)
+func invalidateCategoryCache(ctx context.Context, id int) {
+	keys := []string{cache.AllCategoryLists, cache.CategoryTreeKey, cache.AllCategoryAggregates}
+	if id > 0 {
+		keys = append(keys, cache.CategoryKey(id), cache.AllBookLists)
+	}
+	cache.Default.Invalidate(ctx, keys...)
+}
+
+// GetAllCategories gets all categories from the database (or the catalog
+// cache). ?include=book_count,newest_books,sample_covers (or all) adds
+// aggregates over each category's books, subcategories included.
+// GET /api/v1/categories
+func GetAllCategories(c *fiber.Ctx) error {
+	inc, unknown := parseCategoryIncludes(c.Query("include"))
+	if unknown != "" {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "category.invalid_include", unknown)
+	}
+
+	ctx, cancel := database.WithTimeout(c.UserContext())
+	defer cancel()
+
+	key := cache.CategoryListKey(normalizedQuery(c))
+	load := func(ctx context.Context) (interface{}, error) {
+		return queryCategories(ctx)
+	}
+	// Aggregates change with every book write and are cached apart
+	if inc.any() {
+		key = cache.CategoryListAggregatesKey(normalizedQuery(c))
+		load = func(ctx context.Context) (interface{}, error) {
+			return queryCategoriesWithAggregates(ctx, inc)
+		}
+	}
+	categories, err := cache.Default.GetOrLoad(ctx, "categories", key, load)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "category.list_failed", err)
+	}
+
+	// Clients holding the same list get 304 Not Modified
+	if utils.NotModified(c, utils.ETag(categories), time.Time{}) {
+		return c.SendStatus(fiber.StatusNotModified)
 	}
 
-	if len(categories) == 0 {
-		return utils.JSONResponse(c, fiber.StatusOK, "No categories found", []models.Category{})
+	if isEmptyJSONArray(categories) {
+		return utils.JSONResponse(c, fiber.StatusOK, "category.list_empty", []models.Category{})
 	}
 
-	return utils.JSONResponse(c, fiber.StatusOK, "Categories retrieved successfully", categories)
+	return utils.JSONResponse(c, fiber.StatusOK, "category.list_success", categories)
 }
 
-// GetCategoryByID gets a single category by its ID
+// GetCategoryByID gets a single category by its ID, with the aggregates
+// selected by ?include= (see GetAllCategories)
 // GET /api/v1/categories/:id
 func GetCategoryByID(c *fiber.Ctx) error {
 	id, err := strconv.Atoi(c.Params("id"))
 	if err != nil {
-		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Invalid category ID")
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "category.invalid_id")
+	}
+	inc, unknown := parseCategoryIncludes(c.Query("include"))
+	if unknown != "" {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "category.invalid_include", unknown)
 	}
 
-	category := new(models.Category)
-	err = database.DB.QueryRow("SELECT category_id, nama_kategori, image_url FROM categories WHERE category_id = ?", id).
-		Scan(&category.CategoryID, &category.NamaKategori, &category.ImageURL)
+	ctx, cancel := database.WithTimeout(c.UserContext())
+	defer cancel()
+
+	key := cache.CategoryKey(id)
+	load := func(ctx context.Context) (interface{}, error) {
+		return queryCategoryByID(ctx, id)
+	}
+	if inc.any() {
+		key = cache.CategoryAggregatesKey(id, normalizedQuery(c))
+		load = func(ctx context.Context) (interface{}, error) {
+			return queryCategoryWithAggregates(ctx, id, inc)
+		}
+	}
+	category, err := cache.Default.GetOrLoad(ctx, "categories", key, load)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return utils.ErrorResponse(c, fiber.StatusNotFound, "Category not found")
+		if errors.Is(err, sql.ErrNoRows) {
+			return utils.ErrorResponse(c, fiber.StatusNotFound, "category.not_found")
 		}
-		return utils.ErrorResponse(c, fiber.StatusInternalServerError, fmt.Sprintf("Failed to retrieve category: %v", err))
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "category.get_failed", err)
+	}
+
+	// Honor If-None-Match / If-Modified-Since. Aggregates change with the
+	// books, not categories.updated_at, so they get no Last-Modified.
+	modified := lastModified(category)
+	if inc.any() {
+		modified = time.Time{}
+	}
+	if utils.NotModified(c, utils.ETag(category), modified) {
+		return c.SendStatus(fiber.StatusNotModified)
 	}
 
-	return utils.JSONResponse(c, fiber.StatusOK, "Category retrieved successfully", category)
+	return utils.JSONResponse(c, fiber.StatusOK, "category.get_success", category)
 }
 
 // CreateCategory adds a new category to the database
@@ -66,25 +176,40 @@ func GetCategoryByID(c *fiber.Ctx) error {
 func CreateCategory(c *fiber.Ctx) error {
 	category := new(models.Category)
 	if err := c.BodyParser(category); err != nil {
-		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Invalid request body")
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "common.invalid_body")
 	}
 
-	if category.NamaKategori == "" {
-		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Category name is required")
+	ctx, cancel := database.WithTimeout(c.UserContext())
+	defer cancel()
+
+	// A new category cannot be an ancestor yet, so its parent only has to exist
+	if errs, err := validateCategory(ctx, database.DB, 0, category); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "category.create_failed", err)
+	} else if errs != nil {
+		return utils.ValidationErrorResponse(c, errs)
 	}
 
-	result, err := database.DB.Exec(
-		"INSERT INTO categories (nama_kategori, image_url) VALUES (?, ?)",
-		category.NamaKategori, category.ImageURL,
+	result, err := database.DB.ExecContext(ctx,
+		"INSERT INTO categories (parent_id, nama_kategori, image_url) VALUES (?, ?, ?)",
+		category.ParentID, category.NamaKategori, category.ImageURL,
 	)
 	if err != nil {
-		return utils.ErrorResponse(c, fiber.StatusInternalServerError, fmt.Sprintf("Failed to create category: %v", err))
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "category.create_failed", err)
 	}
 
 	id, _ := result.LastInsertId()
-	category.CategoryID = int(id)
+	invalidateCategoryCache(ctx, 0)
 
-	return utils.JSONResponse(c, fiber.StatusCreated, "Category created successfully", category)
+	return respondWithCategory(c, ctx, fiber.StatusCreated, "category.created", int(id))
+}
+
+// categoryUpdateError responds to a failed category update. Losing a lock
+// race to a concurrent move is a conflict the client can retry.
+func categoryUpdateError(c *fiber.Ctx, err error) error {
+	if isLockConflict(err) {
+		return utils.ErrorResponse(c, fiber.StatusConflict, "common.concurrent_update")
+	}
+	return utils.ErrorResponse(c, fiber.StatusInternalServerError, "category.update_failed", err)
 }
 
 // UpdateCategory updates an existing category in the database
@@ -92,52 +217,199 @@ func CreateCategory(c *fiber.Ctx) error {
 func UpdateCategory(c *fiber.Ctx) error {
 	id, err := strconv.Atoi(c.Params("id"))
 	if err != nil {
-		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Invalid category ID")
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "category.invalid_id")
 	}
 
 	category := new(models.Category)
 	if err := c.BodyParser(category); err != nil {
-		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Invalid request body")
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "common.invalid_body")
 	}
 
-	if category.NamaKategori == "" {
-		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Category name is required")
+	ctx, cancel := database.WithTimeout(c.UserContext())
+	defer cancel()
+
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "category.update_failed", err)
 	}
+	defer tx.Rollback()
 
-	res, err := database.DB.Exec(
-		"UPDATE categories SET nama_kategori = ?, image_url = ? WHERE category_id = ?",
-		category.NamaKategori, category.ImageURL, id,
-	)
+	// The new parent must exist and must not be the category or one of its descendants
+	if errs, err := validateCategory(ctx, tx, id, category); err != nil {
+		return categoryUpdateError(c, err)
+	} else if errs != nil {
+		return utils.ValidationErrorResponse(c, errs)
+	}
+
+	query := "UPDATE categories SET parent_id = ?, nama_kategori = ?, image_url = ?, updated_at = CURRENT_TIMESTAMP(6) WHERE category_id = ?"
+	args := []interface{}{category.ParentID, category.NamaKategori, category.ImageURL, id}
+
+	// Optimistic concurrency: with If-Match the update only applies to the version the client saw
+	if utils.HasIfMatch(c) {
+		current, err := queryCategoryByID(database.WithPrimary(ctx), id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return utils.ErrorResponse(c, fiber.StatusNotFound, "category.not_found")
+			}
+			return utils.ErrorResponse(c, fiber.StatusInternalServerError, "category.get_failed", err)
+		}
+		if utils.PreconditionFailed(c, categoryETag(current)) {
+			return utils.ErrorResponse(c, fiber.StatusPreconditionFailed, "common.precondition_failed")
+		}
+		query += " AND updated_at = ?"
+		args = append(args, current.UpdatedAt)
+	}
+
+	res, err := tx.ExecContext(ctx, query, args...)
 	if err != nil {
-		return utils.ErrorResponse(c, fiber.StatusInternalServerError, fmt.Sprintf("Failed to update category: %v", err))
+		return categoryUpdateError(c, err)
 	}
 
+	// updated_at always changes, so no affected rows means the category is gone
+	// or, with If-Match, that someone else changed it first
 	rowsAffected, _ := res.RowsAffected()
 	if rowsAffected == 0 {
-		return utils.ErrorResponse(c, fiber.StatusNotFound, "Category not found or no changes made")
+		if utils.HasIfMatch(c) {
+			return utils.ErrorResponse(c, fiber.StatusPreconditionFailed, "common.precondition_failed")
+		}
+		return utils.ErrorResponse(c, fiber.StatusNotFound, "category.not_found")
 	}
+	if err := tx.Commit(); err != nil {
+		return categoryUpdateError(c, err)
+	}
+
+	invalidateCategoryCache(ctx, id)
 
-	category.CategoryID = id // Set the ID back for response
-	return utils.JSONResponse(c, fiber.StatusOK, "Category updated successfully", category)
+	return respondWithCategory(c, ctx, fiber.StatusOK, "category.updated", id)
 }
 
-// DeleteCategory deletes a category from the database
+// PatchCategory partially updates a category with a JSON Merge Patch or a
+// JSON Patch; only columns that actually change are written.
+// PATCH /api/v1/categories/:id
+func PatchCategory(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "category.invalid_id")
+	}
+
+	ctx, cancel := database.WithTimeout(c.UserContext())
+	defer cancel()
+
+	current, err := queryCategoryByID(database.WithPrimary(ctx), id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return utils.ErrorResponse(c, fiber.StatusNotFound, "category.not_found")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "category.get_failed", err)
+	}
+	if utils.PreconditionFailed(c, categoryETag(current)) {
+		return utils.ErrorResponse(c, fiber.StatusPreconditionFailed, "common.precondition_failed")
+	}
+
+	original, _ := json.Marshal(current)
+	patched, perr := applyPatch(c, original)
+	if perr != nil {
+		if perr.err != nil {
+			return utils.ErrorResponse(c, perr.status, perr.message, perr.err)
+		}
+		return utils.ErrorResponse(c, perr.status, perr.message)
+	}
+
+	category := new(models.Category)
+	if err := json.Unmarshal(patched, category); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusUnprocessableEntity, "patch.invalid_result", err)
+	}
+
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "category.update_failed", err)
+	}
+	defer tx.Rollback()
+
+	if errs, err := validateCategory(ctx, tx, id, category); err != nil {
+		return categoryUpdateError(c, err)
+	} else if errs != nil {
+		return utils.ValidationErrorResponse(c, errs)
+	}
+
+	columns, values := changedColumns(current, category, "category_id", "created_at", "updated_at")
+	if len(columns) == 0 {
+		c.Set(fiber.HeaderETag, categoryETag(current))
+		return utils.JSONResponse(c, fiber.StatusOK, "category.updated", current)
+	}
+
+	query := "UPDATE categories SET " + setClause(columns) + ", updated_at = CURRENT_TIMESTAMP(6) WHERE category_id = ? AND updated_at = ?"
+	args := append(values, id, current.UpdatedAt)
+
+	res, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return categoryUpdateError(c, err)
+	}
+
+	rowsAffected, _ := res.RowsAffected()
+	if rowsAffected == 0 {
+		if utils.HasIfMatch(c) {
+			return utils.ErrorResponse(c, fiber.StatusPreconditionFailed, "common.precondition_failed")
+		}
+		return utils.ErrorResponse(c, fiber.StatusConflict, "common.concurrent_update")
+	}
+	if err := tx.Commit(); err != nil {
+		return categoryUpdateError(c, err)
+	}
+
+	invalidateCategoryCache(ctx, id)
+
+	return respondWithCategory(c, ctx, fiber.StatusOK, "category.updated", id)
+}
+
+// DeleteCategory deletes a category that has no subcategories and no books
 // DELETE /api/v1/categories/:id
 func DeleteCategory(c *fiber.Ctx) error {
 	id, err := strconv.Atoi(c.Params("id"))
 	if err != nil {
-		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Invalid category ID")
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "category.invalid_id")
+	}
+
+	ctx, cancel := database.WithTimeout(c.UserContext())
+	defer cancel()
+
+	query := "DELETE FROM categories WHERE category_id = ?"
+	args := []interface{}{id}
+
+	// Optimistic concurrency: only delete the version the client saw
+	if utils.HasIfMatch(c) {
+		current, err := queryCategoryByID(database.WithPrimary(ctx), id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return utils.ErrorResponse(c, fiber.StatusNotFound, "category.not_found")
+			}
+			return utils.ErrorResponse(c, fiber.StatusInternalServerError, "category.get_failed", err)
+		}
+		if utils.PreconditionFailed(c, categoryETag(current)) {
+			return utils.ErrorResponse(c, fiber.StatusPreconditionFailed, "common.precondition_failed")
+		}
+		query += " AND updated_at = ?"
+		args = append(args, current.UpdatedAt)
 	}
 
-	res, err := database.DB.Exec("DELETE FROM categories WHERE category_id = ?", id)
+	res, err := database.DB.ExecContext(ctx, query, args...)
 	if err != nil {
-		return utils.ErrorResponse(c, fiber.StatusInternalServerError, fmt.Sprintf("Failed to delete category: %v", err))
+		// Subcategories or books still reference the category
+		if isForeignKeyViolation(err) {
+			return utils.ErrorResponse(c, fiber.StatusConflict, "category.in_use")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "category.delete_failed", err)
 	}
 
 	rowsAffected, _ := res.RowsAffected()
 	if rowsAffected == 0 {
-		return utils.ErrorResponse(c, fiber.StatusNotFound, "Category not found")
+		if utils.HasIfMatch(c) {
+			return utils.ErrorResponse(c, fiber.StatusPreconditionFailed, "common.precondition_failed")
+		}
+		return utils.ErrorResponse(c, fiber.StatusNotFound, "category.not_found")
 	}
 
-	return utils.JSONResponse(c, fiber.StatusOK, "Category deleted successfully", nil)
+	invalidateCategoryCache(ctx, id)
+
+	return utils.JSONResponse(c, fiber.StatusOK, "category.deleted", nil)
 }
\ No newline at end of file
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"pojok_baca_api/cache"
+	"pojok_baca_api/database"
+	"pojok_baca_api/models"
+	"pojok_baca_api/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// maxCategoryDepth bounds the walk up the ancestors of a category, so rows
+// edited by hand into a loop cannot hang a request
+const maxCategoryDepth = 64
+
+// descendantCategories is a subquery selecting a category and all of its
+// descendants; its single argument is the category id. UNION (not UNION
+// ALL) stops the recursion even if the hierarchy contains a loop.
+const descendantCategories = "WITH RECURSIVE tree AS (" +
+	"SELECT category_id FROM categories WHERE category_id = ?" +
+	" UNION SELECT c.category_id FROM categories c JOIN tree ON c.parent_id = tree.category_id" +
+	") SELECT category_id FROM tree"
+
+// validateCategory checks a category against its struct tags and its
+// parent, which must exist and, when id > 0, must not be the category
+// itself or one of its descendants. For an update, db is the update's
+// transaction: the category and the ancestors of its new parent are locked
+// until it ends, so two concurrent moves cannot form a cycle together.
+func validateCategory(ctx context.Context, db dbExecutor, id int, category *models.Category) ([]utils.FieldError, error) {
+	errs := utils.ValidateStruct(category)
+	if category.ParentID == nil {
+		return errs, nil
+	}
+
+	lock := ""
+	if id > 0 {
+		lock = " FOR UPDATE"
+		var one int
+		err := db.QueryRowContext(ctx, "SELECT 1 FROM categories WHERE category_id = ?"+lock, id).Scan(&one)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+	}
+
+	rule, err := checkCategoryParent(id, *category.ParentID, func(categoryID int) (*int, bool, error) {
+		var parent *int
+		err := db.QueryRowContext(ctx, "SELECT parent_id FROM categories WHERE category_id = ?"+lock, categoryID).Scan(&parent)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, nil
+		}
+		return parent, err == nil, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if rule != "" {
+		errs = append(errs, utils.FieldError{Field: "parent_id", Rule: rule})
+	}
+	return errs, nil
+}
+
+// checkCategoryParent walks up from parent using parentOf, which returns the
+// parent of a category and whether the category exists. It returns
+// "exists" if parent does not exist, "acyclic" if the walk reaches id, and
+// "" otherwise. The walk stops after maxCategoryDepth steps or at a missing
+// ancestor.
+func checkCategoryParent(id, parent int, parentOf func(categoryID int) (*int, bool, error)) (string, error) {
+	for depth := 0; depth < maxCategoryDepth; depth++ {
+		if parent == id {
+			return "acyclic", nil
+		}
+		next, ok, err := parentOf(parent)
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			if depth == 0 {
+				return "exists", nil
+			}
+			return "", nil
+		}
+		if next == nil {
+			return "", nil
+		}
+		parent = *next
+	}
+	return "", nil
+}
+
+// categoryNode is a category with its subcategories
+type categoryNode struct {
+	models.Category
+	Children []categoryNode `json:"children"`
+}
+
+// buildCategoryTree nests categories under their parents. Categories whose
+// parent is missing become roots; order within a level is preserved.
+func buildCategoryTree(categories []models.Category) []categoryNode {
+	known := make(map[int]bool, len(categories))
+	for _, category := range categories {
+		known[category.CategoryID] = true
+	}
+	children := map[int][]models.Category{}
+	var roots []models.Category
+	for _, category := range categories {
+		if category.ParentID == nil || !known[*category.ParentID] {
+			roots = append(roots, category)
+			continue
+		}
+		children[*category.ParentID] = append(children[*category.ParentID], category)
+	}
+
+	seen := map[int]bool{}
+	var build func([]models.Category) []categoryNode
+	build = func(level []models.Category) []categoryNode {
+		nodes := []categoryNode{}
+		for _, category := range level {
+			if seen[category.CategoryID] {
+				continue
+			}
+			seen[category.CategoryID] = true
+			nodes = append(nodes, categoryNode{Category: category, Children: build(children[category.CategoryID])})
+		}
+		return nodes
+	}
+	return build(roots)
+}
+
+// GetCategoryTree gets every category nested under its parent category
+// GET /api/v1/categories/tree
+func GetCategoryTree(c *fiber.Ctx) error {
+	ctx, cancel := database.WithTimeout(c.UserContext())
+	defer cancel()
+
+	tree, err := cache.Default.GetOrLoad(ctx, "categories", cache.CategoryTreeKey, func(ctx context.Context) (interface{}, error) {
+		categories, err := queryCategories(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return buildCategoryTree(categories), nil
+	})
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "category.list_failed", err)
+	}
+
+	if utils.NotModified(c, utils.ETag(tree), time.Time{}) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	if isEmptyJSONArray(tree) {
+		return utils.JSONResponse(c, fiber.StatusOK, "category.list_empty", []categoryNode{})
+	}
+
+	return utils.JSONResponse(c, fiber.StatusOK, "category.list_success", tree)
+}
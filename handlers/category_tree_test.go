@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"pojok_baca_api/models"
+)
+
+func TestCheckCategoryParent(t *testing.T) {
+	// parents maps each category to its parent; 0 is a root. Categories 7
+	// and 8 form a loop that was edited in by hand.
+	parents := map[int]int{1: 0, 2: 1, 3: 2, 4: 3, 5: 0, 7: 8, 8: 7, 9: 10}
+	parentOf := func(categoryID int) (*int, bool, error) {
+		parent, ok := parents[categoryID]
+		if !ok || parent == 0 {
+			return nil, ok, nil
+		}
+		return &parent, true, nil
+	}
+
+	tests := []struct {
+		name   string
+		id     int
+		parent int
+		want   string
+	}{
+		{"new category under a root", 0, 1, ""},
+		{"new category under a missing parent", 0, 99, "exists"},
+		{"move to another tree", 2, 5, ""},
+		{"move to a sibling's subtree", 4, 2, ""},
+		{"own parent", 2, 2, "acyclic"},
+		{"under its child", 2, 3, "acyclic"},
+		{"under a deeper descendant", 1, 4, "acyclic"},
+		{"under a missing parent", 2, 99, "exists"},
+		// A missing ancestor ends the walk without an error
+		{"parent with a missing ancestor", 1, 9, ""},
+		// An existing loop is walked at most maxCategoryDepth times
+		{"parent inside a loop", 1, 7, ""},
+		{"into the loop it is part of", 7, 8, "acyclic"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := checkCategoryParent(tt.id, tt.parent, parentOf)
+			if err != nil || got != tt.want {
+				t.Errorf("checkCategoryParent(%d, %d) = %q, %v; want %q", tt.id, tt.parent, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckCategoryParentStopsAtMaxDepth(t *testing.T) {
+	calls := 0
+	got, err := checkCategoryParent(1, 2, func(categoryID int) (*int, bool, error) {
+		calls++
+		next := categoryID + 1
+		return &next, true, nil
+	})
+	if err != nil || got != "" || calls != maxCategoryDepth {
+		t.Errorf("checkCategoryParent = %q, %v after %d lookups; want \"\" after %d", got, err, calls, maxCategoryDepth)
+	}
+}
+
+func TestCheckCategoryParentReturnsLookupErrors(t *testing.T) {
+	errLookup := errors.New("lookup failed")
+	_, err := checkCategoryParent(1, 2, func(int) (*int, bool, error) {
+		return nil, false, errLookup
+	})
+	if !errors.Is(err, errLookup) {
+		t.Errorf("checkCategoryParent error = %v, want %v", err, errLookup)
+	}
+}
+
+// treeShape is a categoryNode reduced to ids, for comparing trees
+type treeShape struct {
+	ID       int
+	Children []treeShape
+}
+
+func shapeOf(nodes []categoryNode) []treeShape {
+	shapes := []treeShape{}
+	for _, node := range nodes {
+		shapes = append(shapes, treeShape{node.CategoryID, shapeOf(node.Children)})
+	}
+	return shapes
+}
+
+func TestBuildCategoryTree(t *testing.T) {
+	category := func(id, parent int) models.Category {
+		c := models.Category{CategoryID: id}
+		if parent != 0 {
+			c.ParentID = &parent
+		}
+		return c
+	}
+	leaf := func(id int) treeShape { return treeShape{id, []treeShape{}} }
+
+	tests := []struct {
+		name       string
+		categories []models.Category
+		want       []treeShape
+	}{
+		{"empty", nil, []treeShape{}},
+		{
+			"flat",
+			[]models.Category{category(2, 0), category(1, 0)},
+			[]treeShape{leaf(2), leaf(1)},
+		},
+		{
+			"nested, keeping the input order within a level",
+			[]models.Category{category(1, 0), category(3, 1), category(2, 1), category(4, 3), category(5, 0)},
+			[]treeShape{
+				{1, []treeShape{{3, []treeShape{leaf(4)}}, leaf(2)}},
+				leaf(5),
+			},
+		},
+		{
+			"children listed before their parent",
+			[]models.Category{category(3, 2), category(2, 1), category(1, 0)},
+			[]treeShape{{1, []treeShape{{2, []treeShape{leaf(3)}}}}},
+		},
+		{
+			"missing parent becomes a root",
+			[]models.Category{category(1, 0), category(2, 99)},
+			[]treeShape{leaf(1), leaf(2)},
+		},
+		// A loop has no root, so its categories are left out rather than
+		// recursing forever
+		{
+			"loop",
+			[]models.Category{category(1, 0), category(2, 3), category(3, 2)},
+			[]treeShape{leaf(1)},
+		},
+		{
+			"self parent",
+			[]models.Category{category(1, 1), category(2, 0)},
+			[]treeShape{leaf(2)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := shapeOf(buildCategoryTree(tt.categories)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("buildCategoryTree = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildCategoryTreeListsChildrenAsEmptyArrays(t *testing.T) {
+	tree := buildCategoryTree([]models.Category{{CategoryID: 1}})
+	if len(tree) != 1 || tree[0].Children == nil {
+		t.Errorf("buildCategoryTree = %+v, want a leaf with non-nil children", tree)
+	}
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"pojok_baca_api/cache"
+	"pojok_baca_api/enrichment"
+	"pojok_baca_api/models"
+	"pojok_baca_api/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// lookupMetadata returns the proposal for q, from the cache when an earlier
+// lookup (e.g. the librarian's preview) already fetched it
+func lookupMetadata(ctx context.Context, enricher *enrichment.Service, q enrichment.Query) (*enrichment.Proposal, error) {
+	payload, err := cache.Default.GetOrLoad(ctx, "enrichment", cache.EnrichmentKey(q.Key()), func(ctx context.Context) (interface{}, error) {
+		return enricher.Lookup(ctx, q)
+	})
+	if err != nil {
+		return nil, err
+	}
+	proposal := new(enrichment.Proposal)
+	if err := json.Unmarshal(payload, proposal); err != nil {
+		return nil, err
+	}
+	return proposal, nil
+}
+
+// enrichmentError answers a failed lookup
+func enrichmentError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, enrichment.ErrDisabled):
+		return utils.ErrorResponse(c, fiber.StatusServiceUnavailable, "enrichment.disabled")
+	case errors.Is(err, enrichment.ErrNotFound):
+		return utils.ErrorResponse(c, fiber.StatusNotFound, "enrichment.not_found")
+	default:
+		return utils.ErrorResponse(c, fiber.StatusBadGateway, "enrichment.failed", err)
+	}
+}
+
+// acceptedFields parses ?enrich= ("all" or a comma-separated list of
+// fields). It returns the first unknown field name as the second result.
+func acceptedFields(value string) ([]string, string) {
+	if strings.TrimSpace(value) == "all" {
+		return enrichment.Fields, ""
+	}
+	var fields []string
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		known := false
+		for _, f := range enrichment.Fields {
+			known = known || f == field
+		}
+		if !known {
+			return nil, field
+		}
+		fields = append(fields, field)
+	}
+	return fields, ""
+}
+
+// applyProposal overwrites the accepted fields of book with the proposed
+// values; fields the proposal has no value for keep what the librarian typed
+func applyProposal(book *models.Book, proposal *enrichment.Proposal, accept []string) error {
+	data, err := json.Marshal(book)
+	if err != nil {
+		return err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	for _, field := range accept {
+		if s, ok := proposal.Fields[field]; ok {
+			fields[field] = s.Value
+		}
+	}
+	if data, err = json.Marshal(fields); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, book)
+}
+
+// LookupBookMetadata proposes field values and a cover image for a new book
+// from the metadata providers. The librarian picks the fields to keep and
+// passes them to CreateBook as ?enrich=penulis,penerbit,...
+// GET /api/v1/books/enrich?isbn=...|title=...
+func LookupBookMetadata(enricher *enrichment.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var q enrichment.Query
+		if raw := c.Query("isbn"); raw != "" {
+			isbn, ok := utils.NormalizeISBN(raw)
+			if !ok {
+				return utils.ErrorResponse(c, fiber.StatusBadRequest, "book.invalid_isbn")
+			}
+			q.ISBN = isbn
+		} else if q.Title = strings.TrimSpace(c.Query("title")); q.Title == "" {
+			return utils.ErrorResponse(c, fiber.StatusBadRequest, "enrichment.missing_query")
+		}
+
+		proposal, err := lookupMetadata(c.UserContext(), enricher, q)
+		if err != nil {
+			return enrichmentError(c, err)
+		}
+		return utils.JSONResponse(c, fiber.StatusOK, "enrichment.success", proposal)
+	}
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"pojok_baca_api/buildinfo"
+	"pojok_baca_api/health"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Healthz reports that the process is alive. It never touches dependencies.
+// GET /healthz
+func Healthz(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"status": "ok"})
+}
+
+// Readyz reports whether the service can handle traffic by running every
+// registered dependency check. It responds 503 when a required check fails.
+// GET /readyz
+func Readyz(checker *health.Checker) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		report := checker.Run(c.UserContext())
+
+		status := fiber.StatusOK
+		if !report.Ready {
+			status = fiber.StatusServiceUnavailable
+		}
+		return c.Status(status).JSON(report)
+	}
+}
+
+// Version reports the git commit, build time and Go version of the binary.
+// GET /version
+func Version(c *fiber.Ctx) error {
+	return c.JSON(buildinfo.Get())
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"reflect"
+	"strings"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Media types accepted by PATCH endpoints
+const (
+	mediaTypeMergePatch = "application/merge-patch+json" // RFC 7396
+	mediaTypeJSONPatch  = "application/json-patch+json"  // RFC 6902
+)
+
+// patchError carries the HTTP status and message key of a patch that could not be applied
+type patchError struct {
+	status  int
+	message string
+	err     error
+}
+
+// applyPatch applies the request body to the JSON document original, as a
+// JSON Patch or a JSON Merge Patch depending on Content-Type. Plain
+// application/json is treated as a merge patch.
+func applyPatch(c *fiber.Ctx, original []byte) ([]byte, *patchError) {
+	mediaType := strings.ToLower(strings.TrimSpace(strings.SplitN(c.Get(fiber.HeaderContentType), ";", 2)[0]))
+
+	switch mediaType {
+	case mediaTypeJSONPatch:
+		patch, err := jsonpatch.DecodePatch(c.Body())
+		if err != nil {
+			return nil, &patchError{fiber.StatusBadRequest, "patch.invalid_document", err}
+		}
+		patched, err := patch.Apply(original)
+		if err != nil {
+			// e.g. a failed "test" operation or a path that does not exist
+			return nil, &patchError{fiber.StatusConflict, "patch.apply_failed", err}
+		}
+		return patched, nil
+
+	case mediaTypeMergePatch, fiber.MIMEApplicationJSON, "":
+		patched, err := jsonpatch.MergePatch(original, c.Body())
+		if err != nil {
+			return nil, &patchError{fiber.StatusBadRequest, "patch.invalid_document", err}
+		}
+		return patched, nil
+
+	default:
+		return nil, &patchError{fiber.StatusUnsupportedMediaType, "patch.unsupported_media_type", nil}
+	}
+}
+
+// changedColumns compares two structs of the same type field by field and
+// returns the `db` column names and new values of every field that differs.
+// Columns listed in readOnly are never reported.
+func changedColumns(before, after interface{}, readOnly ...string) ([]string, []interface{}) {
+	skip := make(map[string]bool, len(readOnly))
+	for _, column := range readOnly {
+		skip[column] = true
+	}
+
+	bv := reflect.Indirect(reflect.ValueOf(before))
+	av := reflect.Indirect(reflect.ValueOf(after))
+	t := bv.Type()
+
+	var columns []string
+	var values []interface{}
+	for i := 0; i < t.NumField(); i++ {
+		column := t.Field(i).Tag.Get("db")
+		if column == "" || column == "-" || skip[column] {
+			continue
+		}
+		if !reflect.DeepEqual(bv.Field(i).Interface(), av.Field(i).Interface()) {
+			columns = append(columns, column)
+			values = append(values, av.Field(i).Interface())
+		}
+	}
+	return columns, values
+}
+
+// setClause builds "col1 = ?, col2 = ?" for an UPDATE statement
+func setClause(columns []string) string {
+	parts := make([]string, len(columns))
+	for i, column := range columns {
+		parts[i] = column + " = ?"
+	}
+	return strings.Join(parts, ", ")
+}
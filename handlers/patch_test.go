@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"pojok_baca_api/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestApplyPatch(t *testing.T) {
+	const original = `{"judul":"Laskar Pelangi","penulis":"Andrea Hirata","tahun_terbit":2005}`
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        string // expected document, compared as JSON
+		wantStatus  int
+		wantMessage string
+	}{
+		{
+			name:        "merge patch",
+			contentType: "application/merge-patch+json",
+			body:        `{"judul":"Sang Pemimpi","penulis":null}`,
+			want:        `{"judul":"Sang Pemimpi","tahun_terbit":2005}`,
+		},
+		{
+			name:        "plain JSON is a merge patch",
+			contentType: "application/json; charset=utf-8",
+			body:        `{"tahun_terbit":2006}`,
+			want:        `{"judul":"Laskar Pelangi","penulis":"Andrea Hirata","tahun_terbit":2006}`,
+		},
+		{
+			name: "no content type is a merge patch",
+			body: `{"tahun_terbit":2006}`,
+			want: `{"judul":"Laskar Pelangi","penulis":"Andrea Hirata","tahun_terbit":2006}`,
+		},
+		{
+			name:        "JSON patch",
+			contentType: "Application/JSON-Patch+JSON",
+			body:        `[{"op":"test","path":"/judul","value":"Laskar Pelangi"},{"op":"replace","path":"/tahun_terbit","value":2008},{"op":"remove","path":"/penulis"}]`,
+			want:        `{"judul":"Laskar Pelangi","tahun_terbit":2008}`,
+		},
+		{
+			name:        "failed test operation",
+			contentType: "application/json-patch+json",
+			body:        `[{"op":"test","path":"/judul","value":"Sang Pemimpi"}]`,
+			wantStatus:  fiber.StatusConflict,
+			wantMessage: "patch.apply_failed",
+		},
+		{
+			name:        "path that does not exist",
+			contentType: "application/json-patch+json",
+			body:        `[{"op":"replace","path":"/sinopsis","value":"x"}]`,
+			wantStatus:  fiber.StatusConflict,
+			wantMessage: "patch.apply_failed",
+		},
+		{
+			name:        "malformed JSON patch",
+			contentType: "application/json-patch+json",
+			body:        `{"op":"replace"}`,
+			wantStatus:  fiber.StatusBadRequest,
+			wantMessage: "patch.invalid_document",
+		},
+		{
+			name:        "malformed merge patch",
+			contentType: "application/merge-patch+json",
+			body:        `{"judul":`,
+			wantStatus:  fiber.StatusBadRequest,
+			wantMessage: "patch.invalid_document",
+		},
+		{
+			name:        "unsupported media type",
+			contentType: "text/plain",
+			body:        `judul=x`,
+			wantStatus:  fiber.StatusUnsupportedMediaType,
+			wantMessage: "patch.unsupported_media_type",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var patched []byte
+			var perr *patchError
+			app := fiber.New()
+			app.Patch("/", func(c *fiber.Ctx) error {
+				patched, perr = applyPatch(c, []byte(original))
+				return nil
+			})
+			req := httptest.NewRequest("PATCH", "/", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			if _, err := app.Test(req); err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.wantStatus != 0 {
+				if perr == nil || perr.status != tt.wantStatus || perr.message != tt.wantMessage {
+					t.Fatalf("applyPatch error = %+v, want %d %s", perr, tt.wantStatus, tt.wantMessage)
+				}
+				return
+			}
+			if perr != nil {
+				t.Fatalf("applyPatch error = %+v", perr)
+			}
+			var got, want interface{}
+			if err := json.Unmarshal(patched, &got); err != nil {
+				t.Fatal(err)
+			}
+			json.Unmarshal([]byte(tt.want), &want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("patched = %s, want %s", patched, tt.want)
+			}
+		})
+	}
+}
+
+func TestChangedColumns(t *testing.T) {
+	parent := 3
+	otherParent := 4
+	before := models.Category{CategoryID: 1, ParentID: &parent, NamaKategori: "Fiksi", ImageURL: "a.jpg"}
+
+	tests := []struct {
+		name       string
+		after      models.Category
+		readOnly   []string
+		wantCols   []string
+		wantValues []interface{}
+	}{
+		{
+			name:  "nothing changed",
+			after: before,
+		},
+		{
+			name:       "changed fields in struct order",
+			after:      models.Category{CategoryID: 1, ParentID: &parent, NamaKategori: "Nonfiksi", ImageURL: "b.jpg"},
+			wantCols:   []string{"nama_kategori", "image_url"},
+			wantValues: []interface{}{"Nonfiksi", "b.jpg"},
+		},
+		{
+			name:       "pointers are compared by value",
+			after:      models.Category{CategoryID: 1, ParentID: &otherParent, NamaKategori: "Fiksi", ImageURL: "a.jpg"},
+			wantCols:   []string{"parent_id"},
+			wantValues: []interface{}{&otherParent},
+		},
+		{
+			name:       "cleared pointer",
+			after:      models.Category{CategoryID: 1, NamaKategori: "Fiksi", ImageURL: "a.jpg"},
+			wantCols:   []string{"parent_id"},
+			wantValues: []interface{}{(*int)(nil)},
+		},
+		{
+			name:     "read-only columns are never reported",
+			after:    models.Category{CategoryID: 9, ParentID: &parent, NamaKategori: "Fiksi", ImageURL: "a.jpg"},
+			readOnly: []string{"category_id"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			after := tt.after
+			columns, values := changedColumns(&before, &after, tt.readOnly...)
+			if !reflect.DeepEqual(columns, tt.wantCols) || !reflect.DeepEqual(values, tt.wantValues) {
+				t.Errorf("changedColumns = %v, %v; want %v, %v", columns, values, tt.wantCols, tt.wantValues)
+			}
+		})
+	}
+}
+
+func TestSetClause(t *testing.T) {
+	tests := []struct {
+		columns []string
+		want    string
+	}{
+		{nil, ""},
+		{[]string{"judul"}, "judul = ?"},
+		{[]string{"judul", "isbn", "tahun_terbit"}, "judul = ?, isbn = ?, tahun_terbit = ?"},
+	}
+	for _, tt := range tests {
+		if got := setClause(tt.columns); got != tt.want {
+			t.Errorf("setClause(%q) = %q, want %q", tt.columns, got, tt.want)
+		}
+	}
+}
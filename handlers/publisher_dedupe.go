@@ -0,0 +1,363 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"pojok_baca_api/cache"
+	"pojok_baca_api/database"
+	"pojok_baca_api/models"
+	"pojok_baca_api/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// defaultDuplicateThreshold is the largest normalized edit distance at which
+// two publisher names are still suggested as duplicates
+const defaultDuplicateThreshold = 0.2
+
+// publisherNoiseWords are dropped before names are compared: legal forms and
+// generic words that publishers add to or leave out of their name
+var publisherNoiseWords = map[string]bool{
+	"pt": true, "cv": true, "tbk": true, "penerbit": true, "percetakan": true,
+	"inc": true, "ltd": true, "co": true, "publisher": true, "publishers": true,
+	"publishing": true, "press": true, "the": true,
+}
+
+// publisherNameKey reduces a publisher name to the words that identify it:
+// lower case, punctuation removed and noise words dropped, so
+// "PT. Gramedia Pustaka Utama" becomes "gramedia pustaka utama"
+func publisherNameKey(name string) []string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	key := words[:0]
+	for _, word := range words {
+		if !publisherNoiseWords[word] {
+			key = append(key, word)
+		}
+	}
+	return key
+}
+
+// similarPublisherNames reports whether two name keys likely name the same
+// publisher: their normalized edit distance is at most threshold
+func similarPublisherNames(a, b []string, threshold float64) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return false
+	}
+	return utils.NormalizedEditDistance(strings.Join(a, " "), strings.Join(b, " ")) <= threshold
+}
+
+// publisherNamePrefix reports whether one name key is the other's leading
+// words ("gramedia" and "gramedia pustaka utama"). A threshold of 0 asks
+// for exact matches only, so it disables the rule.
+func publisherNamePrefix(a, b []string, threshold float64) bool {
+	if threshold == 0 || len(a) == 0 || len(b) == 0 || len(a) == len(b) {
+		return false
+	}
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// publisherCandidate is a publisher in a duplicate cluster, with the number
+// of books that would move if it were merged away
+type publisherCandidate struct {
+	models.Publisher
+	BookCount int `json:"book_count"`
+}
+
+// publisherCluster is a group of publishers that likely are the same. The
+// suggested target is the one with the most books.
+type publisherCluster struct {
+	SuggestedTargetID int                  `json:"suggested_target_id"`
+	Publishers        []publisherCandidate `json:"publishers"`
+}
+
+// queryPublisherCandidates loads every publisher with its book count
+func queryPublisherCandidates(ctx context.Context) ([]publisherCandidate, error) {
+	rows, err := database.Reader(ctx).QueryContext(ctx,
+		"SELECT p.publisher_id, p.nama_penerbit, p.created_at, p.updated_at, COUNT(b.book_id)"+
+			" FROM publishers p LEFT JOIN books b ON b.publisher_id = p.publisher_id"+
+			" GROUP BY p.publisher_id ORDER BY p.publisher_id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var candidates []publisherCandidate
+	for rows.Next() {
+		var p publisherCandidate
+		if err := rows.Scan(&p.PublisherID, &p.NamaPenerbit, &p.CreatedAt, &p.UpdatedAt, &p.BookCount); err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, p)
+	}
+	return candidates, rows.Err()
+}
+
+// clusterPublishers groups publishers whose names are similar, directly or
+// through other publishers in the group, and adds publishers whose name
+// is a prefix match of a whole group. Clusters of one are left out.
+func clusterPublishers(candidates []publisherCandidate, threshold float64) []publisherCluster {
+	keys := make([][]string, len(candidates))
+	for i, p := range candidates {
+		keys[i] = publisherNameKey(p.NamaPenerbit)
+	}
+
+	// Union-find over every similar pair
+	parent := make([]int, len(candidates))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for i := range candidates {
+		for j := i + 1; j < len(candidates); j++ {
+			if similarPublisherNames(keys[i], keys[j], threshold) {
+				parent[find(j)] = find(i)
+			}
+		}
+	}
+	members := map[int][]int{}
+	for i := range candidates {
+		root := find(i)
+		members[root] = append(members[root], i)
+	}
+
+	// Prefix matches are not transitive: "media" prefixes both "media kita"
+	// and "media nusantara", which are different publishers. A publisher
+	// without similar names only joins a group whose every member it
+	// prefixes or is prefixed by: the largest such group, and of equally
+	// large ones the one with the lowest root, so the result does not
+	// depend on map order. Shorter names go first: they are the ones that
+	// prefix others, and should not be claimed by a longer singleton.
+	order := make([]int, len(candidates))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return len(keys[order[a]]) < len(keys[order[b]])
+	})
+	for _, i := range order {
+		own := find(i)
+		if len(members[own]) > 1 {
+			continue
+		}
+		best := -1
+		for _, root := range sortedRoots(members) {
+			group := members[root]
+			if root == own || best >= 0 && len(group) <= len(members[best]) {
+				continue
+			}
+			all := true
+			for _, j := range group {
+				all = all && publisherNamePrefix(keys[i], keys[j], threshold)
+			}
+			if all {
+				best = root
+			}
+		}
+		if best >= 0 {
+			delete(members, own)
+			parent[own] = best
+			members[best] = append(members[best], i)
+		}
+	}
+
+	clusters := []publisherCluster{}
+	for _, root := range sortedRoots(members) {
+		if len(members[root]) < 2 {
+			continue
+		}
+		group := make([]publisherCandidate, len(members[root]))
+		for k, i := range members[root] {
+			group[k] = candidates[i]
+		}
+		// Most books first; ties go to the oldest publisher
+		sort.Slice(group, func(i, j int) bool {
+			if group[i].BookCount != group[j].BookCount {
+				return group[i].BookCount > group[j].BookCount
+			}
+			return group[i].PublisherID < group[j].PublisherID
+		})
+		clusters = append(clusters, publisherCluster{SuggestedTargetID: group[0].PublisherID, Publishers: group})
+	}
+	sort.SliceStable(clusters, func(i, j int) bool {
+		return clusters[i].Publishers[0].NamaPenerbit < clusters[j].Publishers[0].NamaPenerbit
+	})
+	return clusters
+}
+
+// sortedRoots returns the roots of the union-find groups in ascending order
+func sortedRoots(members map[int][]int) []int {
+	roots := make([]int, 0, len(members))
+	for root := range members {
+		roots = append(roots, root)
+	}
+	sort.Ints(roots)
+	return roots
+}
+
+// FindDuplicatePublishers suggests groups of publishers that likely are the
+// same, e.g. "Gramedia", "gramedia" and "PT Gramedia Pustaka Utama". Nothing
+// is changed; merge a group with MergePublishers.
+// GET /api/v1/publishers/duplicates?threshold=0.2
+func FindDuplicatePublishers(c *fiber.Ctx) error {
+	threshold := defaultDuplicateThreshold
+	if raw := c.Query("threshold"); raw != "" {
+		t, err := strconv.ParseFloat(raw, 64)
+		if err != nil || t < 0 || t > 1 {
+			return utils.ErrorResponse(c, fiber.StatusBadRequest, "publisher.invalid_threshold")
+		}
+		threshold = t
+	}
+
+	ctx, cancel := database.WithTimeout(c.UserContext())
+	defer cancel()
+
+	candidates, err := queryPublisherCandidates(ctx)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "publisher.list_failed", err)
+	}
+
+	return utils.JSONResponse(c, fiber.StatusOK, "publisher.duplicates_success", clusterPublishers(candidates, threshold))
+}
+
+// publisherMergeResult reports a merge
+type publisherMergeResult struct {
+	Publisher  *models.Publisher `json:"publisher"`
+	MergedIDs  []int             `json:"merged_ids"`
+	BooksMoved int64             `json:"books_moved"`
+}
+
+// errPublisherNotFound is returned by mergePublishers when the target or a
+// source does not exist
+var errPublisherNotFound = errors.New("publisher not found")
+
+// mergePublishers moves every book of the sources to the target, under the
+// target's name, and deletes the sources, all in one transaction
+func mergePublishers(ctx context.Context, target int, sources []int) (int64, error) {
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Lock the publishers involved so none is renamed or deleted meanwhile
+	var name string
+	err = tx.QueryRowContext(ctx, "SELECT nama_penerbit FROM publishers WHERE publisher_id = ? FOR UPDATE", target).Scan(&name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, errPublisherNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(sources)), ", ")
+	args := make([]interface{}, len(sources))
+	for i, id := range sources {
+		args[i] = id
+	}
+	rows, err := tx.QueryContext(ctx, "SELECT publisher_id FROM publishers WHERE publisher_id IN ("+placeholders+") FOR UPDATE", args...)
+	if err != nil {
+		return 0, err
+	}
+	found := 0
+	for rows.Next() {
+		found++
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if found != len(sources) {
+		return 0, errPublisherNotFound
+	}
+
+	res, err := tx.ExecContext(ctx,
+		"UPDATE books SET publisher_id = ?, penerbit = ?, updated_at = CURRENT_TIMESTAMP(6) WHERE publisher_id IN ("+placeholders+")",
+		append([]interface{}{target, name}, args...)...)
+	if err != nil {
+		return 0, err
+	}
+	moved, _ := res.RowsAffected()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM publishers WHERE publisher_id IN ("+placeholders+")", args...); err != nil {
+		return 0, err
+	}
+	return moved, tx.Commit()
+}
+
+// MergePublishers merges duplicate publishers into one: the books of every
+// source publisher move to the target and the sources are deleted.
+// POST /api/v1/publishers/merge {"target_id": 1, "source_ids": [2, 3]}
+func MergePublishers(c *fiber.Ctx) error {
+	req := new(models.PublisherMerge)
+	if err := c.BodyParser(req); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "common.invalid_body")
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		return utils.ValidationErrorResponse(c, errs)
+	}
+
+	// Each source once, and never the target itself
+	seen := map[int]bool{}
+	var sources []int
+	for _, id := range req.SourceIDs {
+		if id == req.TargetID {
+			return utils.ErrorResponse(c, fiber.StatusBadRequest, "publisher.merge_into_itself")
+		}
+		if !seen[id] {
+			seen[id] = true
+			sources = append(sources, id)
+		}
+	}
+
+	ctx, cancel := database.WithTimeout(c.UserContext())
+	defer cancel()
+
+	moved, err := mergePublishers(ctx, req.TargetID, sources)
+	if err != nil {
+		if errors.Is(err, errPublisherNotFound) {
+			return utils.ErrorResponse(c, fiber.StatusNotFound, "publisher.not_found")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "publisher.merge_failed", err)
+	}
+	log.Printf("Penerbit %v digabung ke penerbit %d (%d buku dipindahkan)", sources, req.TargetID, moved)
+
+	keys := []string{cache.AllPublisherLists, cache.PublisherKey(req.TargetID), cache.AllBooks, cache.AllAuthorBooks}
+	for _, id := range sources {
+		keys = append(keys, cache.PublisherKey(id))
+	}
+	cache.Default.Invalidate(ctx, keys...)
+
+	publisher, err := queryPublisherByID(database.WithPrimary(ctx), req.TargetID)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "publisher.get_failed", err)
+	}
+	c.Set(fiber.HeaderETag, publisherETag(publisher))
+	return utils.JSONResponse(c, fiber.StatusOK, "publisher.merged", publisherMergeResult{
+		Publisher:  publisher,
+		MergedIDs:  sources,
+		BooksMoved: moved,
+	})
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+
+	"pojok_baca_api/models"
+)
+
+// publishers builds candidates with ids 1, 2, ... and no books
+func publishers(names ...string) []publisherCandidate {
+	candidates := make([]publisherCandidate, len(names))
+	for i, name := range names {
+		candidates[i] = publisherCandidate{Publisher: models.Publisher{PublisherID: i + 1, NamaPenerbit: name}}
+	}
+	return candidates
+}
+
+// clusterIDs lists the publisher ids of each cluster, in cluster order
+func clusterIDs(clusters []publisherCluster) [][]int {
+	ids := [][]int{}
+	for _, cluster := range clusters {
+		var group []int
+		for _, p := range cluster.Publishers {
+			group = append(group, p.PublisherID)
+		}
+		ids = append(ids, group)
+	}
+	return ids
+}
+
+func TestPublisherNameKey(t *testing.T) {
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"PT. Gramedia Pustaka Utama", []string{"gramedia", "pustaka", "utama"}},
+		{"Penerbit Erlangga", []string{"erlangga"}},
+		{"CV Andi Offset", []string{"andi", "offset"}},
+		{"The MIT Press", []string{"mit"}},
+		{"PT Tbk", []string{}},
+	}
+	for _, tt := range tests {
+		if got := publisherNameKey(tt.name); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("publisherNameKey(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPublisherNamePrefix(t *testing.T) {
+	tests := []struct {
+		a, b      string
+		threshold float64
+		want      bool
+	}{
+		{"Gramedia", "Gramedia Pustaka Utama", 0.2, true},
+		{"Gramedia Pustaka Utama", "Gramedia", 0.2, true},
+		{"Pustaka", "Gramedia Pustaka Utama", 0.2, false},
+		// Equal keys are an edit distance match, not a prefix match
+		{"Gramedia", "PT Gramedia", 0.2, false},
+		// A threshold of 0 asks for exact matches only
+		{"Gramedia", "Gramedia Pustaka Utama", 0, false},
+		{"PT", "PT Gramedia", 0.2, false},
+	}
+	for _, tt := range tests {
+		got := publisherNamePrefix(publisherNameKey(tt.a), publisherNameKey(tt.b), tt.threshold)
+		if got != tt.want {
+			t.Errorf("publisherNamePrefix(%q, %q, %v) = %v, want %v", tt.a, tt.b, tt.threshold, got, tt.want)
+		}
+	}
+}
+
+func TestClusterPublishers(t *testing.T) {
+	tests := []struct {
+		name       string
+		candidates []publisherCandidate
+		threshold  float64
+		want       [][]int
+	}{
+		{
+			name:       "no publishers",
+			candidates: nil,
+			threshold:  0.2,
+			want:       [][]int{},
+		},
+		{
+			name:       "edit distance, noise words and case",
+			candidates: publishers("Gramedia", "Erlangga", "PT. GRAMEDIA", "Grmedia"),
+			threshold:  0.2,
+			want:       [][]int{{1, 3, 4}},
+		},
+		{
+			name:       "edit distance matches are transitive",
+			candidates: publishers("Kanisius", "Kanisiux", "Kanisiyx"),
+			threshold:  0.125,
+			want:       [][]int{{1, 2, 3}},
+		},
+		{
+			name:       "threshold boundary is inclusive",
+			candidates: publishers("Kanisius", "Kanisiux"),
+			threshold:  0.125,
+			want:       [][]int{{1, 2}},
+		},
+		{
+			name:       "just below the threshold boundary",
+			candidates: publishers("Kanisius", "Kanisiux"),
+			threshold:  0.12,
+			want:       [][]int{},
+		},
+		{
+			name:       "threshold 0 keeps exact matches only",
+			candidates: publishers("Gramedia", "Gramedia Pustaka Utama", "PT Gramedia"),
+			threshold:  0,
+			want:       [][]int{{1, 3}},
+		},
+		{
+			name:       "prefix match joins a group",
+			candidates: publishers("Gramedia Pustaka Utama", "Erlangga", "Gramedia"),
+			threshold:  0.2,
+			want:       [][]int{{1, 3}},
+		},
+		{
+			name:       "prefix match joins the largest group",
+			candidates: publishers("Mizan Media", "Mizan", "Mizan Pustaka", "Mizan Pustakaa"),
+			threshold:  0.2,
+			want:       [][]int{{2, 3, 4}},
+		},
+		{
+			name:       "prefix matches do not chain different publishers",
+			candidates: publishers("Media Kita", "Media Nusantara", "Media"),
+			threshold:  0.2,
+			// Tied singletons: the lowest root wins
+			want: [][]int{{1, 3}},
+		},
+		{
+			name:       "a prefix must match every member of the group",
+			candidates: publishers("Gramedia Pustaka", "Gramedia Pustakaa", "Gramedia"),
+			threshold:  0.2,
+			want:       [][]int{{1, 2, 3}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Map order must not change the result
+			for run := 0; run < 20; run++ {
+				if got := clusterIDs(clusterPublishers(tt.candidates, tt.threshold)); !reflect.DeepEqual(got, tt.want) {
+					t.Fatalf("run %d: clusters = %v, want %v", run, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestClusterPublishersSuggestsPublisherWithMostBooks(t *testing.T) {
+	candidates := publishers("Gramedia", "Gramedia", "Gramedia", "Erlangga", "erlangga")
+	candidates[1].BookCount = 5
+	candidates[2].BookCount = 5
+	candidates[0].BookCount = 1
+
+	clusters := clusterPublishers(candidates, 0.2)
+	if got, want := clusterIDs(clusters), [][]int{{4, 5}, {2, 3, 1}}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("clusters = %v, want %v", got, want)
+	}
+	// Ties in book count go to the oldest publisher
+	if clusters[1].SuggestedTargetID != 2 {
+		t.Errorf("suggested target = %d, want 2", clusters[1].SuggestedTargetID)
+	}
+	if clusters[0].SuggestedTargetID != 4 {
+		t.Errorf("suggested target = %d, want 4", clusters[0].SuggestedTargetID)
+	}
+}
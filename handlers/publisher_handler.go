@@ -0,0 +1,401 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"pojok_baca_api/cache"
+	"pojok_baca_api/database"
+	"pojok_baca_api/models"
+	"pojok_baca_api/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// publisherColumns is the column list shared by every publisher SELECT, in scanPublisher order
+const publisherColumns = "publisher_id, nama_penerbit, created_at, updated_at"
+
+// scanPublisher scans a row selected with publisherColumns into a Publisher
+func scanPublisher(row rowScanner, publisher *models.Publisher) error {
+	return row.Scan(&publisher.PublisherID, &publisher.NamaPenerbit, &publisher.CreatedAt, &publisher.UpdatedAt)
+}
+
+// queryPublishers loads every publisher whose name contains search (all
+// when empty), never returning a nil slice
+func queryPublishers(ctx context.Context, search string) ([]models.Publisher, error) {
+	query := "SELECT " + publisherColumns + " FROM publishers"
+	var args []interface{}
+	if search != "" {
+		query += " WHERE nama_penerbit LIKE ?"
+		args = append(args, "%"+escapeLike(search)+"%")
+	}
+	// Read-only query: served by a healthy read replica when configured
+	rows, err := database.Reader(ctx).QueryContext(ctx, query+" ORDER BY nama_penerbit", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	publishers := []models.Publisher{}
+	for rows.Next() {
+		var publisher models.Publisher
+		if err := scanPublisher(rows, &publisher); err != nil {
+			return nil, err
+		}
+		publishers = append(publishers, publisher)
+	}
+	return publishers, rows.Err()
+}
+
+// queryPublisherByID loads a single publisher; it returns sql.ErrNoRows when it does not exist
+func queryPublisherByID(ctx context.Context, id int) (*models.Publisher, error) {
+	publisher := new(models.Publisher)
+	err := scanPublisher(database.Reader(ctx).QueryRowContext(ctx, "SELECT "+publisherColumns+" FROM publishers WHERE publisher_id = ?", id), publisher)
+	if err != nil {
+		return nil, err
+	}
+	return publisher, nil
+}
+
+// publisherETag computes the ETag of a publisher's JSON representation,
+// matching the ETag GetPublisherByID sends for the same data
+func publisherETag(publisher *models.Publisher) string {
+	payload, _ := json.Marshal(publisher)
+	return utils.ETag(payload)
+}
+
+// respondWithPublisher reloads a publisher from the primary after a write
+// and sends it with its new ETag
+func respondWithPublisher(c *fiber.Ctx, ctx context.Context, status int, message string, id int) error {
+	publisher, err := queryPublisherByID(database.WithPrimary(ctx), id)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "publisher.get_failed", err)
+	}
+	c.Set(fiber.HeaderETag, publisherETag(publisher))
+	return utils.JSONResponse(c, status, message, publisher)
+}
+
+// renamePublisherBooks gives the books of a renamed publisher its new name
+func renamePublisherBooks(ctx context.Context, db dbExecutor, id int, name string) error {
+	_, err := db.ExecContext(ctx,
+		"UPDATE books SET penerbit = ?, updated_at = CURRENT_TIMESTAMP(6) WHERE publisher_id = ?", name, id)
+	return err
+}
+
+// invalidatePublisherCache drops the cached publisher lists and, if id > 0,
+// the cached publisher and every cached book (books carry the publisher's name)
+func invalidatePublisherCache(ctx context.Context, id int) {
+	keys := []string{cache.AllPublisherLists}
+	if id > 0 {
+		keys = append(keys, cache.PublisherKey(id), cache.AllBooks, cache.AllAuthorBooks)
+	}
+	cache.Default.Invalidate(ctx, keys...)
+}
+
+// GetAllPublishers gets all publishers, optionally filtered by ?q= on the name
+// GET /api/v1/publishers
+func GetAllPublishers(c *fiber.Ctx) error {
+	ctx, cancel := database.WithTimeout(c.UserContext())
+	defer cancel()
+
+	search := strings.TrimSpace(c.Query("q"))
+	publishers, err := cache.Default.GetOrLoad(ctx, "publishers", cache.PublisherListKey(normalizedQuery(c)), func(ctx context.Context) (interface{}, error) {
+		return queryPublishers(ctx, search)
+	})
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "publisher.list_failed", err)
+	}
+
+	// Clients holding the same list get 304 Not Modified
+	if utils.NotModified(c, utils.ETag(publishers), time.Time{}) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	if isEmptyJSONArray(publishers) {
+		return utils.JSONResponse(c, fiber.StatusOK, "publisher.list_empty", []models.Publisher{})
+	}
+
+	return utils.JSONResponse(c, fiber.StatusOK, "publisher.list_success", publishers)
+}
+
+// GetPublisherByID gets a single publisher by its ID
+// GET /api/v1/publishers/:id
+func GetPublisherByID(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "publisher.invalid_id")
+	}
+
+	ctx, cancel := database.WithTimeout(c.UserContext())
+	defer cancel()
+
+	publisher, err := cache.Default.GetOrLoad(ctx, "publishers", cache.PublisherKey(id), func(ctx context.Context) (interface{}, error) {
+		return queryPublisherByID(ctx, id)
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return utils.ErrorResponse(c, fiber.StatusNotFound, "publisher.not_found")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "publisher.get_failed", err)
+	}
+
+	// Honor If-None-Match / If-Modified-Since
+	if utils.NotModified(c, utils.ETag(publisher), lastModified(publisher)) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	return utils.JSONResponse(c, fiber.StatusOK, "publisher.get_success", publisher)
+}
+
+// CreatePublisher adds a new publisher
+// POST /api/v1/publishers
+func CreatePublisher(c *fiber.Ctx) error {
+	publisher := new(models.Publisher)
+	if err := c.BodyParser(publisher); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "common.invalid_body")
+	}
+	publisher.NamaPenerbit = strings.Join(strings.Fields(publisher.NamaPenerbit), " ")
+
+	if errs := utils.ValidateStruct(publisher); errs != nil {
+		return utils.ValidationErrorResponse(c, errs)
+	}
+
+	ctx, cancel := database.WithTimeout(c.UserContext())
+	defer cancel()
+
+	result, err := database.DB.ExecContext(ctx, "INSERT INTO publishers (nama_penerbit) VALUES (?)", publisher.NamaPenerbit)
+	if err != nil {
+		if isDuplicateKey(err) {
+			return utils.ErrorResponse(c, fiber.StatusConflict, "publisher.duplicate_name")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "publisher.create_failed", err)
+	}
+
+	id, _ := result.LastInsertId()
+	invalidatePublisherCache(ctx, 0)
+
+	return respondWithPublisher(c, ctx, fiber.StatusCreated, "publisher.created", int(id))
+}
+
+// UpdatePublisher renames a publisher; its books take the new name
+// PUT /api/v1/publishers/:id
+func UpdatePublisher(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "publisher.invalid_id")
+	}
+
+	publisher := new(models.Publisher)
+	if err := c.BodyParser(publisher); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "common.invalid_body")
+	}
+	publisher.NamaPenerbit = strings.Join(strings.Fields(publisher.NamaPenerbit), " ")
+
+	if errs := utils.ValidateStruct(publisher); errs != nil {
+		return utils.ValidationErrorResponse(c, errs)
+	}
+
+	ctx, cancel := database.WithTimeout(c.UserContext())
+	defer cancel()
+
+	current, err := queryPublisherByID(database.WithPrimary(ctx), id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return utils.ErrorResponse(c, fiber.StatusNotFound, "publisher.not_found")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "publisher.get_failed", err)
+	}
+
+	// Optimistic concurrency: with If-Match the update only applies to the version the client saw
+	if utils.HasIfMatch(c) && utils.PreconditionFailed(c, publisherETag(current)) {
+		return utils.ErrorResponse(c, fiber.StatusPreconditionFailed, "common.precondition_failed")
+	}
+
+	query := "UPDATE publishers SET nama_penerbit = ?, updated_at = CURRENT_TIMESTAMP(6) WHERE publisher_id = ?"
+	args := []interface{}{publisher.NamaPenerbit, id}
+	if utils.HasIfMatch(c) {
+		query += " AND updated_at = ?"
+		args = append(args, current.UpdatedAt)
+	}
+
+	// The publisher and the penerbit text of its books change together
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "publisher.update_failed", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		if isDuplicateKey(err) {
+			return utils.ErrorResponse(c, fiber.StatusConflict, "publisher.duplicate_name")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "publisher.update_failed", err)
+	}
+
+	// updated_at always changes, so no affected rows means the publisher is
+	// gone or, with If-Match, that someone else changed it first
+	rowsAffected, _ := res.RowsAffected()
+	if rowsAffected == 0 {
+		if utils.HasIfMatch(c) {
+			return utils.ErrorResponse(c, fiber.StatusPreconditionFailed, "common.precondition_failed")
+		}
+		return utils.ErrorResponse(c, fiber.StatusNotFound, "publisher.not_found")
+	}
+
+	if publisher.NamaPenerbit != current.NamaPenerbit {
+		if err := renamePublisherBooks(ctx, tx, id, publisher.NamaPenerbit); err != nil {
+			return utils.ErrorResponse(c, fiber.StatusInternalServerError, "publisher.update_failed", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "publisher.update_failed", err)
+	}
+
+	invalidatePublisherCache(ctx, id)
+
+	return respondWithPublisher(c, ctx, fiber.StatusOK, "publisher.updated", id)
+}
+
+// PatchPublisher partially updates a publisher with a JSON Merge Patch or a
+// JSON Patch; only columns that actually change are written.
+// PATCH /api/v1/publishers/:id
+func PatchPublisher(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "publisher.invalid_id")
+	}
+
+	ctx, cancel := database.WithTimeout(c.UserContext())
+	defer cancel()
+
+	current, err := queryPublisherByID(database.WithPrimary(ctx), id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return utils.ErrorResponse(c, fiber.StatusNotFound, "publisher.not_found")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "publisher.get_failed", err)
+	}
+	if utils.PreconditionFailed(c, publisherETag(current)) {
+		return utils.ErrorResponse(c, fiber.StatusPreconditionFailed, "common.precondition_failed")
+	}
+
+	original, _ := json.Marshal(current)
+	patched, perr := applyPatch(c, original)
+	if perr != nil {
+		if perr.err != nil {
+			return utils.ErrorResponse(c, perr.status, perr.message, perr.err)
+		}
+		return utils.ErrorResponse(c, perr.status, perr.message)
+	}
+
+	publisher := new(models.Publisher)
+	if err := json.Unmarshal(patched, publisher); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusUnprocessableEntity, "patch.invalid_result", err)
+	}
+	publisher.NamaPenerbit = strings.Join(strings.Fields(publisher.NamaPenerbit), " ")
+
+	if errs := utils.ValidateStruct(publisher); errs != nil {
+		return utils.ValidationErrorResponse(c, errs)
+	}
+
+	columns, values := changedColumns(current, publisher, "publisher_id", "created_at", "updated_at")
+	if len(columns) == 0 {
+		c.Set(fiber.HeaderETag, publisherETag(current))
+		return utils.JSONResponse(c, fiber.StatusOK, "publisher.updated", current)
+	}
+
+	query := "UPDATE publishers SET " + setClause(columns) + ", updated_at = CURRENT_TIMESTAMP(6) WHERE publisher_id = ? AND updated_at = ?"
+	args := append(values, id, current.UpdatedAt)
+
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "publisher.update_failed", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		if isDuplicateKey(err) {
+			return utils.ErrorResponse(c, fiber.StatusConflict, "publisher.duplicate_name")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "publisher.update_failed", err)
+	}
+
+	rowsAffected, _ := res.RowsAffected()
+	if rowsAffected == 0 {
+		if utils.HasIfMatch(c) {
+			return utils.ErrorResponse(c, fiber.StatusPreconditionFailed, "common.precondition_failed")
+		}
+		return utils.ErrorResponse(c, fiber.StatusConflict, "common.concurrent_update")
+	}
+
+	if err := renamePublisherBooks(ctx, tx, id, publisher.NamaPenerbit); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "publisher.update_failed", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "publisher.update_failed", err)
+	}
+
+	invalidatePublisherCache(ctx, id)
+
+	return respondWithPublisher(c, ctx, fiber.StatusOK, "publisher.updated", id)
+}
+
+// DeletePublisher deletes a publisher that no book refers to anymore; merge
+// it into another publisher instead to keep its books
+// DELETE /api/v1/publishers/:id
+func DeletePublisher(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "publisher.invalid_id")
+	}
+
+	ctx, cancel := database.WithTimeout(c.UserContext())
+	defer cancel()
+
+	query := "DELETE FROM publishers WHERE publisher_id = ?"
+	args := []interface{}{id}
+
+	// Optimistic concurrency: only delete the version the client saw
+	if utils.HasIfMatch(c) {
+		current, err := queryPublisherByID(database.WithPrimary(ctx), id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return utils.ErrorResponse(c, fiber.StatusNotFound, "publisher.not_found")
+			}
+			return utils.ErrorResponse(c, fiber.StatusInternalServerError, "publisher.get_failed", err)
+		}
+		if utils.PreconditionFailed(c, publisherETag(current)) {
+			return utils.ErrorResponse(c, fiber.StatusPreconditionFailed, "common.precondition_failed")
+		}
+		query += " AND updated_at = ?"
+		args = append(args, current.UpdatedAt)
+	}
+
+	res, err := database.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		// books still reference the publisher
+		if isForeignKeyViolation(err) {
+			return utils.ErrorResponse(c, fiber.StatusConflict, "publisher.in_use")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "publisher.delete_failed", err)
+	}
+
+	rowsAffected, _ := res.RowsAffected()
+	if rowsAffected == 0 {
+		if utils.HasIfMatch(c) {
+			return utils.ErrorResponse(c, fiber.StatusPreconditionFailed, "common.precondition_failed")
+		}
+		return utils.ErrorResponse(c, fiber.StatusNotFound, "publisher.not_found")
+	}
+
+	invalidatePublisherCache(ctx, id)
+
+	return utils.JSONResponse(c, fiber.StatusOK, "publisher.deleted", nil)
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/gofiber/fiber/v2"
+)
+
+// normalizedQuery returns the request's query string with parameters sorted,
+// so "?a=1&b=2" and "?b=2&a=1" share a cache entry
+func normalizedQuery(c *fiber.Ctx) string {
+	var params []string
+	c.Request().URI().QueryArgs().VisitAll(func(key, value []byte) {
+		params = append(params, string(key)+"="+string(value))
+	})
+	sort.Strings(params)
+	return strings.Join(params, "&")
+}
+
+// isDuplicateKey reports whether err is a MySQL/MariaDB unique key violation
+func isDuplicateKey(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1062
+}
+
+// isForeignKeyViolation reports whether err is a MySQL/MariaDB error for a
+// row that is still referenced by a foreign key
+func isForeignKeyViolation(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1451
+}
+
+// isLockConflict reports whether err is a MySQL/MariaDB deadlock or lock
+// wait timeout, i.e. a concurrent transaction won the race for a row
+func isLockConflict(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && (mysqlErr.Number == 1213 || mysqlErr.Number == 1205)
+}
+
+// isEmptyJSONArray reports whether a JSON payload is an empty array
+func isEmptyJSONArray(payload []byte) bool {
+	return bytes.Equal(bytes.TrimSpace(payload), []byte("[]"))
+}
+
+// lastModified extracts updated_at from a cached JSON resource for the
+// Last-Modified header; it returns the zero time when absent
+func lastModified(payload []byte) time.Time {
+	var resource struct {
+		UpdatedAt time.Time `json:"updated_at"`
+	}
+	if err := json.Unmarshal(payload, &resource); err != nil {
+		return time.Time{}
+	}
+	return resource.UpdatedAt
+}
@@ -0,0 +1,522 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"pojok_baca_api/cache"
+	"pojok_baca_api/database"
+	"pojok_baca_api/models"
+	"pojok_baca_api/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Recommendation list size: ?limit= defaults to defaultRecommendationLimit
+// and is capped at maxRecommendationLimit
+const (
+	defaultRecommendationLimit = 10
+	maxRecommendationLimit     = 50
+)
+
+// Personal recommendations start from the books a user interacted with,
+// weighted by how strongly: views count up to maxViewWeight, a book on a
+// shelf counts shelfWeight and a review counts its rating minus
+// reviewWeightOffset, so a one-star review steers away from similar books.
+const (
+	maxViewWeight      = 5
+	shelfWeight        = 2
+	reviewWeightOffset = 2
+	// maxSeeds bounds the books recommendations are computed from
+	maxSeeds = 200
+	// favoriteCategories is how many of the user's categories the cold-start
+	// fallback draws popular books from
+	favoriteCategories = 3
+	// popularBooks is how many of the most popular books overall are
+	// cached, enough to fill a list after leaving out every seed
+	popularBooks = maxSeeds + maxRecommendationLimit
+)
+
+// Why a book is recommended
+const (
+	reasonSimilar = "similar"
+	reasonPopular = "popular"
+)
+
+// bookPopularity scores the book aliased as b by how many users viewed it,
+// shelved it and reviewed it
+const bookPopularity = "((SELECT COUNT(*) FROM user_book_views v WHERE v.book_id = b.book_id)" +
+	" + (SELECT COUNT(*) FROM shelf_books sb WHERE sb.book_id = b.book_id) + " + bookReviewCount + ")"
+
+// popularBook is a cached entry of the most popular books overall
+type popularBook struct {
+	BookID int     `json:"book_id"`
+	Score  float64 `json:"score"`
+}
+
+// recommendedBook is a suggested book. Score is the similarity for similar
+// books and the popularity for popular ones.
+type recommendedBook struct {
+	models.Book
+	Score  float64 `json:"score"`
+	Reason string  `json:"reason"`
+}
+
+// recommendationLimit parses ?limit=; ok is false for an invalid value
+func recommendationLimit(c *fiber.Ctx) (int, bool) {
+	value := c.Query("limit")
+	if value == "" {
+		return defaultRecommendationLimit, true
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 {
+		return 0, false
+	}
+	return min(limit, maxRecommendationLimit), true
+}
+
+// scanRecommendedBooks scans rows selected with prefixedBookColumns and a
+// score, attaching the relations of the books
+func scanRecommendedBooks(ctx context.Context, rows *sql.Rows, reason string) ([]recommendedBook, error) {
+	defer rows.Close()
+
+	var books []models.Book
+	var scores []float64
+	for rows.Next() {
+		var book models.Book
+		var score float64
+		if err := rows.Scan(append(bookDest(&book), &score)...); err != nil {
+			return nil, err
+		}
+		books = append(books, book)
+		scores = append(scores, score)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := attachRelations(ctx, books); err != nil {
+		return nil, err
+	}
+
+	result := make([]recommendedBook, len(books))
+	for i := range books {
+		result[i] = recommendedBook{Book: books[i], Score: scores[i], Reason: reason}
+	}
+	return result, nil
+}
+
+// querySimilarBooks loads the stored most similar books of a book
+func querySimilarBooks(ctx context.Context, id, limit int) ([]recommendedBook, error) {
+	rows, err := database.Reader(ctx).QueryContext(ctx,
+		"SELECT "+prefixedBookColumns+", s.score FROM book_similarities s JOIN books b ON b.book_id = s.similar_book_id"+
+			" WHERE s.book_id = ? ORDER BY s.score DESC, b.book_id LIMIT ?", id, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanRecommendedBooks(ctx, rows, reasonSimilar)
+}
+
+// queryPopularBooks loads the most popular books in any of categoryIDs,
+// leaving out the excluded ones
+func queryPopularBooks(ctx context.Context, categoryIDs, exclude []int, limit int) ([]recommendedBook, error) {
+	where := " WHERE 1 = 1"
+	var args []interface{}
+	if len(categoryIDs) > 0 {
+		placeholders, categoryArgs := intArgs(categoryIDs)
+		where += " AND EXISTS (SELECT 1 FROM book_categories bc WHERE bc.book_id = b.book_id AND bc.category_id IN (" + placeholders + "))"
+		args = append(args, categoryArgs...)
+	}
+	if len(exclude) > 0 {
+		placeholders, excludeArgs := intArgs(exclude)
+		where += " AND b.book_id NOT IN (" + placeholders + ")"
+		args = append(args, excludeArgs...)
+	}
+	args = append(args, limit)
+
+	rows, err := database.Reader(ctx).QueryContext(ctx,
+		"SELECT "+prefixedBookColumns+", "+bookPopularity+" AS popularity FROM books b"+where+
+			" ORDER BY popularity DESC, b.book_id DESC LIMIT ?", args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanRecommendedBooks(ctx, rows, reasonPopular)
+}
+
+// queryBookCategoryIDs loads the categories of a book
+func queryBookCategoryIDs(ctx context.Context, id int) ([]int, error) {
+	rows, err := database.Reader(ctx).QueryContext(ctx, "SELECT category_id FROM book_categories WHERE book_id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var categoryID int
+		if err := rows.Scan(&categoryID); err != nil {
+			return nil, err
+		}
+		ids = append(ids, categoryID)
+	}
+	return ids, rows.Err()
+}
+
+// fillWithPopular tops books up to limit with popular books in categoryIDs
+// (all books when empty) that are neither listed nor excluded
+func fillWithPopular(ctx context.Context, books []recommendedBook, categoryIDs, exclude []int, limit int) ([]recommendedBook, error) {
+	if len(books) >= limit {
+		return books, nil
+	}
+	// Never append to the caller's slice
+	exclude = exclude[:len(exclude):len(exclude)]
+	for _, book := range books {
+		exclude = append(exclude, book.BookID)
+	}
+	if len(categoryIDs) == 0 {
+		popular, err := queryCachedPopularBooks(ctx, exclude, limit-len(books))
+		if err != nil {
+			return nil, err
+		}
+		return append(books, popular...), nil
+	}
+	popular, err := queryPopularBooks(ctx, categoryIDs, exclude, limit-len(books))
+	if err != nil {
+		return nil, err
+	}
+	return append(books, popular...), nil
+}
+
+// queryCachedPopularBooks loads the most popular books overall, leaving out
+// the excluded ones. Scoring every book is expensive, so the ranking is
+// cached until the recommendation job or a catalog change drops it.
+func queryCachedPopularBooks(ctx context.Context, exclude []int, limit int) ([]recommendedBook, error) {
+	raw, err := cache.Default.GetOrLoad(ctx, "books", cache.PopularBooksKey, func(ctx context.Context) (interface{}, error) {
+		rows, err := database.Reader(ctx).QueryContext(ctx,
+			"SELECT b.book_id, "+bookPopularity+" AS popularity FROM books b"+
+				" ORDER BY popularity DESC, b.book_id DESC LIMIT ?", popularBooks)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		ranking := []popularBook{}
+		for rows.Next() {
+			var book popularBook
+			if err := rows.Scan(&book.BookID, &book.Score); err != nil {
+				return nil, err
+			}
+			ranking = append(ranking, book)
+		}
+		return ranking, rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	var ranking []popularBook
+	if err := json.Unmarshal(raw, &ranking); err != nil {
+		return nil, err
+	}
+
+	excluded := make(map[int]bool, len(exclude))
+	for _, id := range exclude {
+		excluded[id] = true
+	}
+	var ids []int
+	scores := map[int]float64{}
+	for _, book := range ranking {
+		if len(ids) == limit {
+			break
+		}
+		if !excluded[book.BookID] {
+			ids = append(ids, book.BookID)
+			scores[book.BookID] = book.Score
+		}
+	}
+	return queryRecommendedBooks(ctx, ids, scores, reasonPopular)
+}
+
+// GetSimilarBooks lists the books most similar to a book, as precomputed by
+// the recommendation job. Until the job has found enough, the list is topped
+// up with popular books in the same categories. ?limit= (default 10, at
+// most 50) bounds the list.
+// GET /api/v1/books/:id/similar
+func GetSimilarBooks(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "book.invalid_id")
+	}
+	limit, ok := recommendationLimit(c)
+	if !ok {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "recommendation.invalid_limit", maxRecommendationLimit)
+	}
+
+	ctx, cancel := database.WithTimeout(c.UserContext())
+	defer cancel()
+
+	books, err := cache.Default.GetOrLoad(ctx, "books", cache.SimilarBooksKey(id, normalizedQuery(c)), func(ctx context.Context) (interface{}, error) {
+		// An unknown book is a 404, not an empty list
+		exists, err := bookExists(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, sql.ErrNoRows
+		}
+
+		books, err := querySimilarBooks(ctx, id, limit)
+		if err != nil {
+			return nil, err
+		}
+		categoryIDs, err := queryBookCategoryIDs(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		// A book without categories has no meaningful popular neighbours
+		if len(categoryIDs) > 0 {
+			books, err = fillWithPopular(ctx, books, categoryIDs, []int{id}, limit)
+		}
+		if books == nil && err == nil {
+			books = []recommendedBook{}
+		}
+		return books, err
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return utils.ErrorResponse(c, fiber.StatusNotFound, "book.not_found")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "recommendation.list_failed", err)
+	}
+
+	if utils.NotModified(c, utils.ETag(books), time.Time{}) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	if isEmptyJSONArray(books) {
+		return utils.JSONResponse(c, fiber.StatusOK, "recommendation.list_empty", []recommendedBook{})
+	}
+
+	return utils.JSONResponse(c, fiber.StatusOK, "recommendation.list_success", books)
+}
+
+// queryRecommendationSeeds loads the books a user viewed, shelved or
+// reviewed, weighted by how strongly they interacted with each
+func queryRecommendationSeeds(ctx context.Context, userID int) (map[int]float64, error) {
+	rows, err := database.Reader(ctx).QueryContext(ctx,
+		"SELECT book_id, SUM(weight) AS weight FROM ("+
+			"SELECT book_id, LEAST(view_count, ?) AS weight FROM user_book_views WHERE user_id = ?"+
+			" UNION ALL SELECT sb.book_id, ? FROM shelf_books sb JOIN shelves s ON s.shelf_id = sb.shelf_id WHERE s.user_id = ?"+
+			" UNION ALL SELECT book_id, rating - ? FROM book_reviews WHERE user_id = ?"+
+			") seeds GROUP BY book_id ORDER BY weight DESC, book_id LIMIT ?",
+		maxViewWeight, userID, shelfWeight, userID, reviewWeightOffset, userID, maxSeeds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	seeds := map[int]float64{}
+	for rows.Next() {
+		var bookID int
+		var weight float64
+		if err := rows.Scan(&bookID, &weight); err != nil {
+			return nil, err
+		}
+		seeds[bookID] = weight
+	}
+	return seeds, rows.Err()
+}
+
+// scoreCandidates sums the similarities of the books similar to the seeds,
+// weighted by the seeds, and returns the best limit books the user has not
+// interacted with yet
+func scoreCandidates(ctx context.Context, seeds map[int]float64, limit int) ([]int, map[int]float64, error) {
+	ids := make([]int, 0, len(seeds))
+	for id := range seeds {
+		ids = append(ids, id)
+	}
+	placeholders, args := intArgs(ids)
+	rows, err := database.Reader(ctx).QueryContext(ctx,
+		"SELECT book_id, similar_book_id, score FROM book_similarities WHERE book_id IN ("+placeholders+")", args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	scores := map[int]float64{}
+	for rows.Next() {
+		var bookID, similarID int
+		var score float64
+		if err := rows.Scan(&bookID, &similarID, &score); err != nil {
+			return nil, nil, err
+		}
+		if _, seen := seeds[similarID]; !seen {
+			scores[similarID] += seeds[bookID] * score
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	var candidates []int
+	for id, score := range scores {
+		if score > 0 {
+			candidates = append(candidates, id)
+		}
+	}
+	sort.Slice(candidates, func(a, b int) bool {
+		if scores[candidates[a]] != scores[candidates[b]] {
+			return scores[candidates[a]] > scores[candidates[b]]
+		}
+		return candidates[a] < candidates[b]
+	})
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	return candidates, scores, nil
+}
+
+// queryRecommendedBooks loads the given books in order with their scores
+func queryRecommendedBooks(ctx context.Context, ids []int, scores map[int]float64, reason string) ([]recommendedBook, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	placeholders, args := intArgs(ids)
+	rows, err := database.Reader(ctx).QueryContext(ctx,
+		"SELECT "+prefixedBookColumns+", 0 FROM books b WHERE b.book_id IN ("+placeholders+")", args...)
+	if err != nil {
+		return nil, err
+	}
+	books, err := scanRecommendedBooks(ctx, rows, reason)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[int]recommendedBook, len(books))
+	for _, book := range books {
+		book.Score = math.Round(scores[book.BookID]*10000) / 10000
+		byID[book.BookID] = book
+	}
+	// Books deleted since the similarities were computed are skipped
+	result := make([]recommendedBook, 0, len(ids))
+	for _, id := range ids {
+		if book, ok := byID[id]; ok {
+			result = append(result, book)
+		}
+	}
+	return result, nil
+}
+
+// queryFavoriteCategories returns the categories of the books the user
+// liked most, favorite first
+func queryFavoriteCategories(ctx context.Context, seeds map[int]float64) ([]int, error) {
+	var ids []int
+	for id, weight := range seeds {
+		if weight > 0 {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	placeholders, args := intArgs(ids)
+	rows, err := database.Reader(ctx).QueryContext(ctx,
+		"SELECT book_id, category_id FROM book_categories WHERE book_id IN ("+placeholders+")", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	weights := map[int]float64{}
+	for rows.Next() {
+		var bookID, categoryID int
+		if err := rows.Scan(&bookID, &categoryID); err != nil {
+			return nil, err
+		}
+		weights[categoryID] += seeds[bookID]
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	categories := make([]int, 0, len(weights))
+	for id := range weights {
+		categories = append(categories, id)
+	}
+	sort.Slice(categories, func(a, b int) bool {
+		if weights[categories[a]] != weights[categories[b]] {
+			return weights[categories[a]] > weights[categories[b]]
+		}
+		return categories[a] < categories[b]
+	})
+	if len(categories) > favoriteCategories {
+		categories = categories[:favoriteCategories]
+	}
+	return categories, nil
+}
+
+// GetMyRecommendations suggests books to the authenticated user from the
+// books they viewed, shelved and reviewed. Users with little history get
+// popular books in their favorite categories, and then popular books
+// overall. ?limit= (default 10, at most 50) bounds the list.
+// GET /api/v1/me/recommendations
+func GetMyRecommendations(c *fiber.Ctx) error {
+	limit, ok := recommendationLimit(c)
+	if !ok {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "recommendation.invalid_limit", maxRecommendationLimit)
+	}
+
+	ctx, cancel := database.WithTimeout(c.UserContext())
+	defer cancel()
+
+	seeds, err := queryRecommendationSeeds(ctx, currentUser(c).UserID)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "recommendation.list_failed", err)
+	}
+
+	var books []recommendedBook
+	if len(seeds) > 0 {
+		candidates, scores, err := scoreCandidates(ctx, seeds, limit)
+		if err != nil {
+			return utils.ErrorResponse(c, fiber.StatusInternalServerError, "recommendation.list_failed", err)
+		}
+		if books, err = queryRecommendedBooks(ctx, candidates, scores, reasonSimilar); err != nil {
+			return utils.ErrorResponse(c, fiber.StatusInternalServerError, "recommendation.list_failed", err)
+		}
+	}
+
+	// Cold start: popular books in the favorite categories, then overall
+	seen := make([]int, 0, len(seeds))
+	for id := range seeds {
+		seen = append(seen, id)
+	}
+	if len(books) < limit {
+		categories, err := queryFavoriteCategories(ctx, seeds)
+		if err != nil {
+			return utils.ErrorResponse(c, fiber.StatusInternalServerError, "recommendation.list_failed", err)
+		}
+		if len(categories) > 0 {
+			if books, err = fillWithPopular(ctx, books, categories, seen, limit); err != nil {
+				return utils.ErrorResponse(c, fiber.StatusInternalServerError, "recommendation.list_failed", err)
+			}
+		}
+	}
+	if books, err = fillWithPopular(ctx, books, nil, seen, limit); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "recommendation.list_failed", err)
+	}
+
+	payload, _ := json.Marshal(books)
+	if utils.NotModified(c, utils.ETag(payload), time.Time{}) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	if len(books) == 0 {
+		return utils.JSONResponse(c, fiber.StatusOK, "recommendation.list_empty", []recommendedBook{})
+	}
+
+	return utils.JSONResponse(c, fiber.StatusOK, "recommendation.list_success", books)
+}
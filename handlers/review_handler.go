@@ -0,0 +1,400 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"pojok_baca_api/cache"
+	"pojok_baca_api/database"
+	"pojok_baca_api/models"
+	"pojok_baca_api/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// reviewColumns is the column list shared by every review SELECT, in
+// scanReview order; reviewFrom joins the reviewer's name
+const (
+	reviewColumns = "r.review_id, r.book_id, r.user_id, u.nama_lengkap, r.rating, r.ulasan, r.hidden, r.flagged, r.created_at, r.updated_at"
+	reviewFrom    = " FROM book_reviews r JOIN users u ON u.user_id = r.user_id"
+)
+
+// scanReview scans a row selected with reviewColumns into a Review
+func scanReview(row rowScanner, review *models.Review) error {
+	return row.Scan(&review.ReviewID, &review.BookID, &review.UserID, &review.NamaLengkap, &review.Rating, &review.Ulasan,
+		&review.Hidden, &review.Flagged, &review.CreatedAt, &review.UpdatedAt)
+}
+
+// queryReviews loads the reviews matching where (a WHERE clause over the
+// reviews aliased as r, or ""), newest first, never returning a nil slice
+func queryReviews(ctx context.Context, where string, args ...interface{}) ([]models.Review, error) {
+	rows, err := database.Reader(ctx).QueryContext(ctx,
+		"SELECT "+reviewColumns+reviewFrom+where+" ORDER BY r.created_at DESC, r.review_id DESC", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reviews := []models.Review{}
+	for rows.Next() {
+		var review models.Review
+		if err := scanReview(rows, &review); err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, review)
+	}
+	return reviews, rows.Err()
+}
+
+// queryReviewByID loads a single review; it returns sql.ErrNoRows when it does not exist
+func queryReviewByID(ctx context.Context, id int) (*models.Review, error) {
+	review := new(models.Review)
+	err := scanReview(database.Reader(ctx).QueryRowContext(ctx, "SELECT "+reviewColumns+reviewFrom+" WHERE r.review_id = ?", id), review)
+	if err != nil {
+		return nil, err
+	}
+	return review, nil
+}
+
+// bookExists reports whether a book exists
+func bookExists(ctx context.Context, id int) (bool, error) {
+	var one int
+	err := database.Reader(ctx).QueryRowContext(ctx, "SELECT 1 FROM books WHERE book_id = ?", id).Scan(&one)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// reviewETag computes the ETag of a review's JSON representation
+func reviewETag(review *models.Review) string {
+	payload, _ := json.Marshal(review)
+	return utils.ETag(payload)
+}
+
+// respondWithReview reloads a review from the primary after a write and
+// sends it with its new ETag
+func respondWithReview(c *fiber.Ctx, ctx context.Context, status int, message string, id int) error {
+	review, err := queryReviewByID(database.WithPrimary(ctx), id)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "review.get_failed", err)
+	}
+	c.Set(fiber.HeaderETag, reviewETag(review))
+	return utils.JSONResponse(c, status, message, review)
+}
+
+// loadOwnReview loads the review :id for a change by the authenticated
+// user, checking If-Match. It answers 404 when the review does not exist
+// and 403 when it belongs to someone else, unless the user is a librarian
+// and allowLibrarian is set. When the review is nil the response has been
+// sent and the caller returns the error as is.
+func loadOwnReview(c *fiber.Ctx, ctx context.Context, allowLibrarian bool) (*models.Review, error) {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, utils.ErrorResponse(c, fiber.StatusBadRequest, "review.invalid_id")
+	}
+
+	review, err := queryReviewByID(database.WithPrimary(ctx), id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, utils.ErrorResponse(c, fiber.StatusNotFound, "review.not_found")
+		}
+		return nil, utils.ErrorResponse(c, fiber.StatusInternalServerError, "review.get_failed", err)
+	}
+
+	user := currentUser(c)
+	if review.UserID != user.UserID && !(allowLibrarian && user.Role == models.RoleLibrarian) {
+		return nil, utils.ErrorResponse(c, fiber.StatusForbidden, "review.not_owner")
+	}
+	if utils.PreconditionFailed(c, reviewETag(review)) {
+		return nil, utils.ErrorResponse(c, fiber.StatusPreconditionFailed, "common.precondition_failed")
+	}
+	return review, nil
+}
+
+// invalidateReviewCache drops the cached reviews of a book and every cached
+// copy of the book, whose rating and review count change with its reviews
+func invalidateReviewCache(ctx context.Context, bookID int) {
+	cache.Default.Invalidate(ctx, cache.BookReviewsKey(bookID), cache.BookKey(bookID), cache.AllBookLists, cache.AllBookISBNs, cache.AllAuthorBooks, cache.AllSimilarBooks)
+}
+
+// GetBookReviews lists the visible reviews of a book, newest first
+// GET /api/v1/books/:id/reviews
+func GetBookReviews(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "book.invalid_id")
+	}
+
+	ctx, cancel := database.WithTimeout(c.UserContext())
+	defer cancel()
+
+	reviews, err := cache.Default.GetOrLoad(ctx, "reviews", cache.BookReviewsKey(id), func(ctx context.Context) (interface{}, error) {
+		// An unknown book is a 404, not an empty list
+		exists, err := bookExists(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, sql.ErrNoRows
+		}
+		return queryReviews(ctx, " WHERE r.book_id = ? AND NOT r.hidden", id)
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return utils.ErrorResponse(c, fiber.StatusNotFound, "book.not_found")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "review.list_failed", err)
+	}
+
+	if utils.NotModified(c, utils.ETag(reviews), time.Time{}) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	if isEmptyJSONArray(reviews) {
+		return utils.JSONResponse(c, fiber.StatusOK, "review.list_empty", []models.Review{})
+	}
+
+	return utils.JSONResponse(c, fiber.StatusOK, "review.list_success", reviews)
+}
+
+// GetReviews lists every review, hidden ones included, for moderation.
+// Optional filters: ?book_id=, ?hidden=true|false, ?flagged=true|false.
+// GET /api/v1/reviews (librarians only)
+func GetReviews(c *fiber.Ctx) error {
+	var conds []string
+	var args []interface{}
+	if value := c.Query("book_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil || id <= 0 {
+			return utils.ErrorResponse(c, fiber.StatusBadRequest, "review.invalid_filter", "book_id")
+		}
+		conds = append(conds, "r.book_id = ?")
+		args = append(args, id)
+	}
+	for _, name := range []string{"hidden", "flagged"} {
+		value := c.Query(name)
+		if value == "" {
+			continue
+		}
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return utils.ErrorResponse(c, fiber.StatusBadRequest, "review.invalid_filter", name)
+		}
+		conds = append(conds, "r."+name+" = ?")
+		args = append(args, b)
+	}
+	where := ""
+	if len(conds) > 0 {
+		where = " WHERE " + strings.Join(conds, " AND ")
+	}
+
+	ctx, cancel := database.WithTimeout(c.UserContext())
+	defer cancel()
+
+	// Moderators act on what they see, so the queue is never served stale
+	reviews, err := queryReviews(database.WithPrimary(ctx), where, args...)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "review.list_failed", err)
+	}
+
+	if len(reviews) == 0 {
+		return utils.JSONResponse(c, fiber.StatusOK, "review.list_empty", reviews)
+	}
+
+	return utils.JSONResponse(c, fiber.StatusOK, "review.list_success", reviews)
+}
+
+// CreateReview rates and optionally reviews a book as the authenticated
+// user; each user reviews a book once and edits that review afterwards
+// POST /api/v1/books/:id/reviews {"rating": 4, "ulasan": "..."}
+func CreateReview(c *fiber.Ctx) error {
+	bookID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "book.invalid_id")
+	}
+
+	review := new(models.Review)
+	if err := c.BodyParser(review); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "common.invalid_body")
+	}
+	review.Ulasan = strings.TrimSpace(review.Ulasan)
+
+	if errs := utils.ValidateStruct(review); errs != nil {
+		return utils.ValidationErrorResponse(c, errs)
+	}
+
+	ctx, cancel := database.WithTimeout(c.UserContext())
+	defer cancel()
+
+	exists, err := bookExists(database.WithPrimary(ctx), bookID)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "book.get_failed", err)
+	}
+	if !exists {
+		return utils.ErrorResponse(c, fiber.StatusNotFound, "book.not_found")
+	}
+
+	result, err := database.DB.ExecContext(ctx,
+		"INSERT INTO book_reviews (book_id, user_id, rating, ulasan) VALUES (?, ?, ?, ?)",
+		bookID, currentUser(c).UserID, review.Rating, review.Ulasan,
+	)
+	if err != nil {
+		if isDuplicateKey(err) {
+			return utils.ErrorResponse(c, fiber.StatusConflict, "review.already_reviewed")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "review.create_failed", err)
+	}
+
+	id, _ := result.LastInsertId()
+	invalidateReviewCache(ctx, bookID)
+
+	return respondWithReview(c, ctx, fiber.StatusCreated, "review.created", int(id))
+}
+
+// UpdateReview changes the rating and text of the authenticated user's own review
+// PUT /api/v1/reviews/:id
+func UpdateReview(c *fiber.Ctx) error {
+	review := new(models.Review)
+	if err := c.BodyParser(review); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "common.invalid_body")
+	}
+	review.Ulasan = strings.TrimSpace(review.Ulasan)
+
+	if errs := utils.ValidateStruct(review); errs != nil {
+		return utils.ValidationErrorResponse(c, errs)
+	}
+
+	ctx, cancel := database.WithTimeout(c.UserContext())
+	defer cancel()
+
+	current, err := loadOwnReview(c, ctx, false)
+	if current == nil {
+		return err
+	}
+
+	res, err := database.DB.ExecContext(ctx,
+		"UPDATE book_reviews SET rating = ?, ulasan = ?, updated_at = CURRENT_TIMESTAMP(6) WHERE review_id = ? AND updated_at = ?",
+		review.Rating, review.Ulasan, current.ReviewID, current.UpdatedAt,
+	)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "review.update_failed", err)
+	}
+
+	rowsAffected, _ := res.RowsAffected()
+	if rowsAffected == 0 {
+		if utils.HasIfMatch(c) {
+			return utils.ErrorResponse(c, fiber.StatusPreconditionFailed, "common.precondition_failed")
+		}
+		return utils.ErrorResponse(c, fiber.StatusConflict, "common.concurrent_update")
+	}
+
+	invalidateReviewCache(ctx, current.BookID)
+
+	return respondWithReview(c, ctx, fiber.StatusOK, "review.updated", current.ReviewID)
+}
+
+// DeleteReview deletes the authenticated user's own review; librarians may
+// delete any review
+// DELETE /api/v1/reviews/:id
+func DeleteReview(c *fiber.Ctx) error {
+	ctx, cancel := database.WithTimeout(c.UserContext())
+	defer cancel()
+
+	current, err := loadOwnReview(c, ctx, true)
+	if current == nil {
+		return err
+	}
+
+	res, err := database.DB.ExecContext(ctx, "DELETE FROM book_reviews WHERE review_id = ? AND updated_at = ?", current.ReviewID, current.UpdatedAt)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "review.delete_failed", err)
+	}
+
+	rowsAffected, _ := res.RowsAffected()
+	if rowsAffected == 0 {
+		if utils.HasIfMatch(c) {
+			return utils.ErrorResponse(c, fiber.StatusPreconditionFailed, "common.precondition_failed")
+		}
+		return utils.ErrorResponse(c, fiber.StatusConflict, "common.concurrent_update")
+	}
+
+	if user := currentUser(c); user.UserID != current.UserID {
+		log.Printf("Ulasan %d milik pengguna %d dihapus oleh pustakawan %d", current.ReviewID, current.UserID, user.UserID)
+	}
+	invalidateReviewCache(ctx, current.BookID)
+
+	return utils.JSONResponse(c, fiber.StatusOK, "review.deleted", nil)
+}
+
+// ModerateReview hides or flags a review. Hidden reviews are left out of
+// the book's review list and rating; flagged ones stay visible but are
+// marked for a closer look in the moderation queue.
+// PUT /api/v1/reviews/:id/moderation {"hidden": true, "flagged": false} (librarians only)
+func ModerateReview(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "review.invalid_id")
+	}
+
+	req := new(models.ReviewModeration)
+	if err := c.BodyParser(req); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "common.invalid_body")
+	}
+	if req.Hidden == nil && req.Flagged == nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "review.moderation_empty")
+	}
+
+	ctx, cancel := database.WithTimeout(c.UserContext())
+	defer cancel()
+
+	current, err := queryReviewByID(database.WithPrimary(ctx), id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return utils.ErrorResponse(c, fiber.StatusNotFound, "review.not_found")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "review.get_failed", err)
+	}
+	if utils.PreconditionFailed(c, reviewETag(current)) {
+		return utils.ErrorResponse(c, fiber.StatusPreconditionFailed, "common.precondition_failed")
+	}
+
+	hidden, flagged := current.Hidden, current.Flagged
+	if req.Hidden != nil {
+		hidden = *req.Hidden
+	}
+	if req.Flagged != nil {
+		flagged = *req.Flagged
+	}
+	if hidden == current.Hidden && flagged == current.Flagged {
+		c.Set(fiber.HeaderETag, reviewETag(current))
+		return utils.JSONResponse(c, fiber.StatusOK, "review.moderated", current)
+	}
+
+	res, err := database.DB.ExecContext(ctx,
+		"UPDATE book_reviews SET hidden = ?, flagged = ?, updated_at = CURRENT_TIMESTAMP(6) WHERE review_id = ? AND updated_at = ?",
+		hidden, flagged, id, current.UpdatedAt,
+	)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "review.update_failed", err)
+	}
+
+	rowsAffected, _ := res.RowsAffected()
+	if rowsAffected == 0 {
+		if utils.HasIfMatch(c) {
+			return utils.ErrorResponse(c, fiber.StatusPreconditionFailed, "common.precondition_failed")
+		}
+		return utils.ErrorResponse(c, fiber.StatusConflict, "common.concurrent_update")
+	}
+
+	log.Printf("Ulasan %d dimoderasi oleh pustakawan %d (hidden=%v, flagged=%v)", id, currentUser(c).UserID, hidden, flagged)
+	invalidateReviewCache(ctx, current.BookID)
+
+	return respondWithReview(c, ctx, fiber.StatusOK, "review.moderated", id)
+}
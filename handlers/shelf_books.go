@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+
+	"pojok_baca_api/database"
+	"pojok_baca_api/models"
+	"pojok_baca_api/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// readingProgress is a user's progress on a book; dates are YYYY-MM-DD
+type readingProgress struct {
+	progress   int
+	startedOn  *string
+	finishedOn *string
+}
+
+// mergeProgress applies a shelf entry to the current progress. Moving a
+// book to the reading shelf starts it today and moving it to the finished
+// shelf finishes it today at 100%, unless the entry says otherwise.
+func mergeProgress(current readingProgress, entry *models.ShelfEntry, jenis string, today string) (readingProgress, []utils.FieldError) {
+	p := current
+	if entry.Progress != nil {
+		p.progress = *entry.Progress
+	}
+	for _, f := range []struct {
+		value *string
+		dest  **string
+	}{{entry.StartedOn, &p.startedOn}, {entry.FinishedOn, &p.finishedOn}} {
+		if f.value == nil {
+			continue
+		}
+		// An empty date clears it
+		*f.dest = nil
+		if *f.value != "" {
+			date := *f.value
+			*f.dest = &date
+		}
+	}
+
+	switch jenis {
+	case models.ShelfReading:
+		if p.startedOn == nil {
+			p.startedOn = &today
+		}
+	case models.ShelfFinished:
+		if entry.Progress == nil {
+			p.progress = 100
+		}
+		if p.finishedOn == nil {
+			p.finishedOn = &today
+		}
+	}
+
+	// YYYY-MM-DD dates compare as strings
+	if p.startedOn != nil && p.finishedOn != nil && *p.finishedOn < *p.startedOn {
+		return p, []utils.FieldError{{Field: "finished_on", Rule: "notbeforestart"}}
+	}
+	return p, nil
+}
+
+// saveProgress merges a shelf entry into a user's reading progress on a
+// book, locking the progress row for the transaction
+func saveProgress(ctx context.Context, tx *sql.Tx, userID, bookID int, entry *models.ShelfEntry, jenis string) ([]utils.FieldError, error) {
+	var current readingProgress
+	var startedOn, finishedOn sql.NullTime
+	err := tx.QueryRowContext(ctx,
+		"SELECT progress, started_on, finished_on FROM reading_progress WHERE user_id = ? AND book_id = ? FOR UPDATE", userID, bookID,
+	).Scan(&current.progress, &startedOn, &finishedOn)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	current.startedOn = dateString(startedOn)
+	current.finishedOn = dateString(finishedOn)
+
+	p, errs := mergeProgress(current, entry, jenis, time.Now().Format(time.DateOnly))
+	if errs != nil {
+		return errs, nil
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO reading_progress (user_id, book_id, progress, started_on, finished_on) VALUES (?, ?, ?, ?, ?)"+
+			" ON DUPLICATE KEY UPDATE progress = VALUES(progress), started_on = VALUES(started_on), finished_on = VALUES(finished_on),"+
+			" updated_at = CURRENT_TIMESTAMP(6)",
+		userID, bookID, p.progress, p.startedOn, p.finishedOn)
+	return nil, err
+}
+
+// PutShelfBook puts a book on a shelf of the authenticated user and updates
+// the reading progress from the optional body. A book is on one built-in
+// shelf at a time, so putting it on one takes it off the others.
+// PUT /api/v1/me/shelves/:shelf/books/:book_id {"progress": 40, "started_on": "2025-01-31"}
+func PutShelfBook(c *fiber.Ctx) error {
+	bookID, err := strconv.Atoi(c.Params("book_id"))
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "book.invalid_id")
+	}
+
+	entry := new(models.ShelfEntry)
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(entry); err != nil {
+			return utils.ErrorResponse(c, fiber.StatusBadRequest, "common.invalid_body")
+		}
+	}
+	if errs := utils.ValidateStruct(entry); errs != nil {
+		return utils.ValidationErrorResponse(c, errs)
+	}
+
+	ctx, cancel := database.WithTimeout(c.UserContext())
+	defer cancel()
+
+	shelf, err := loadShelf(c, ctx)
+	if shelf == nil {
+		return err
+	}
+	exists, err := bookExists(database.WithPrimary(ctx), bookID)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "book.get_failed", err)
+	}
+	if !exists {
+		return utils.ErrorResponse(c, fiber.StatusNotFound, "book.not_found")
+	}
+
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "shelf.update_failed", err)
+	}
+	defer tx.Rollback()
+
+	userID := currentUser(c).UserID
+	if _, err := tx.ExecContext(ctx, "INSERT IGNORE INTO shelf_books (shelf_id, book_id) VALUES (?, ?)", shelf.ShelfID, bookID); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "shelf.update_failed", err)
+	}
+	touched := "shelf_id = ?"
+	touchArgs := []interface{}{shelf.ShelfID}
+	if shelf.Jenis != models.ShelfCustom {
+		_, err := tx.ExecContext(ctx,
+			"DELETE sb FROM shelf_books sb JOIN shelves s ON s.shelf_id = sb.shelf_id"+
+				" WHERE s.user_id = ? AND s.jenis NOT IN ('custom', ?) AND sb.book_id = ?", userID, shelf.Jenis, bookID)
+		if err != nil {
+			return utils.ErrorResponse(c, fiber.StatusInternalServerError, "shelf.update_failed", err)
+		}
+		touched = "user_id = ? AND jenis <> 'custom'"
+		touchArgs = []interface{}{userID}
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE shelves SET updated_at = CURRENT_TIMESTAMP(6) WHERE "+touched, touchArgs...); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "shelf.update_failed", err)
+	}
+
+	// Progress is only recorded once there is something to record
+	if entry.Progress != nil || entry.StartedOn != nil || entry.FinishedOn != nil ||
+		shelf.Jenis == models.ShelfReading || shelf.Jenis == models.ShelfFinished {
+		errs, err := saveProgress(ctx, tx, userID, bookID, entry, shelf.Jenis)
+		if err != nil {
+			return utils.ErrorResponse(c, fiber.StatusInternalServerError, "shelf.update_failed", err)
+		}
+		if errs != nil {
+			return utils.ValidationErrorResponse(c, errs)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "shelf.update_failed", err)
+	}
+
+	return respondWithShelf(c, ctx, fiber.StatusOK, "shelf.book_added", shelf.ShelfID)
+}
+
+// RemoveShelfBook takes a book off a shelf of the authenticated user; its
+// reading progress is kept
+// DELETE /api/v1/me/shelves/:shelf/books/:book_id
+func RemoveShelfBook(c *fiber.Ctx) error {
+	bookID, err := strconv.Atoi(c.Params("book_id"))
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "book.invalid_id")
+	}
+
+	ctx, cancel := database.WithTimeout(c.UserContext())
+	defer cancel()
+
+	shelf, err := loadShelf(c, ctx)
+	if shelf == nil {
+		return err
+	}
+
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "shelf.update_failed", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM shelf_books WHERE shelf_id = ? AND book_id = ?", shelf.ShelfID, bookID)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "shelf.update_failed", err)
+	}
+	rowsAffected, _ := res.RowsAffected()
+	if rowsAffected == 0 {
+		return utils.ErrorResponse(c, fiber.StatusNotFound, "shelf.book_not_found")
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE shelves SET updated_at = CURRENT_TIMESTAMP(6) WHERE shelf_id = ?", shelf.ShelfID); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "shelf.update_failed", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "shelf.update_failed", err)
+	}
+
+	return respondWithShelf(c, ctx, fiber.StatusOK, "shelf.book_removed", shelf.ShelfID)
+}
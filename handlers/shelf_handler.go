@@ -0,0 +1,466 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"pojok_baca_api/database"
+	"pojok_baca_api/models"
+	"pojok_baca_api/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// builtinShelves are the shelves every user has, in display order
+var builtinShelves = []struct{ jenis, nama string }{
+	{models.ShelfWantToRead, "Ingin Dibaca"},
+	{models.ShelfReading, "Sedang Dibaca"},
+	{models.ShelfFinished, "Selesai Dibaca"},
+}
+
+// shelfColumns is the column list shared by every shelf SELECT over the
+// shelves aliased as s, in scanShelf order
+const shelfColumns = "s.shelf_id, s.user_id, s.jenis, s.nama_rak, s.is_public, s.slug, s.created_at, s.updated_at," +
+	" (SELECT COUNT(*) FROM shelf_books sb WHERE sb.shelf_id = s.shelf_id)"
+
+// scanShelf scans a row selected with shelfColumns into a Shelf
+func scanShelf(row rowScanner, shelf *models.Shelf) error {
+	return row.Scan(&shelf.ShelfID, &shelf.UserID, &shelf.Jenis, &shelf.NamaRak, &shelf.Public, &shelf.Slug,
+		&shelf.CreatedAt, &shelf.UpdatedAt, &shelf.BookCount)
+}
+
+// queryShelves loads every shelf of a user, built-in shelves first
+func queryShelves(ctx context.Context, userID int) ([]models.Shelf, error) {
+	rows, err := database.Reader(ctx).QueryContext(ctx,
+		"SELECT "+shelfColumns+" FROM shelves s WHERE s.user_id = ?"+
+			" ORDER BY FIELD(s.jenis, 'want_to_read', 'reading', 'finished', 'custom'), s.nama_rak", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shelves := []models.Shelf{}
+	for rows.Next() {
+		var shelf models.Shelf
+		if err := scanShelf(rows, &shelf); err != nil {
+			return nil, err
+		}
+		shelves = append(shelves, shelf)
+	}
+	return shelves, rows.Err()
+}
+
+// queryShelf loads a shelf of a user by its id or, for a built-in shelf,
+// its kind ("reading"); it returns sql.ErrNoRows when there is none
+func queryShelf(ctx context.Context, userID int, key string) (*models.Shelf, error) {
+	cond := "s.shelf_id = ?"
+	var arg interface{} = key
+	if isBuiltinShelf(key) {
+		cond = "s.jenis = ?"
+	} else if id, err := strconv.Atoi(key); err == nil {
+		arg = id
+	} else {
+		return nil, sql.ErrNoRows
+	}
+
+	shelf := new(models.Shelf)
+	err := scanShelf(database.Reader(ctx).QueryRowContext(ctx,
+		"SELECT "+shelfColumns+" FROM shelves s WHERE s.user_id = ? AND "+cond, userID, arg), shelf)
+	if err != nil {
+		return nil, err
+	}
+	return shelf, nil
+}
+
+// queryShelfBooks loads the books on a shelf, most recently added first,
+// with the reading progress of userID
+func queryShelfBooks(ctx context.Context, shelfID, userID int) ([]models.ShelfBook, error) {
+	rows, err := database.Reader(ctx).QueryContext(ctx,
+		"SELECT "+prefixedBookColumns+", sb.added_at, rp.progress, rp.started_on, rp.finished_on"+
+			" FROM shelf_books sb JOIN books b ON b.book_id = sb.book_id"+
+			" LEFT JOIN reading_progress rp ON rp.user_id = ? AND rp.book_id = sb.book_id"+
+			" WHERE sb.shelf_id = ? ORDER BY sb.added_at DESC, b.book_id", userID, shelfID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []models.ShelfBook{}
+	for rows.Next() {
+		var entry models.ShelfBook
+		var progress sql.NullInt64
+		var startedOn, finishedOn sql.NullTime
+		if err := rows.Scan(append(bookDest(&entry.Book), &entry.AddedAt, &progress, &startedOn, &finishedOn)...); err != nil {
+			return nil, err
+		}
+		entry.Progress = int(progress.Int64)
+		entry.StartedOn = dateString(startedOn)
+		entry.FinishedOn = dateString(finishedOn)
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	books := make([]models.Book, len(entries))
+	for i := range entries {
+		books[i] = entries[i].Book
+	}
+	if err := attachRelations(ctx, books); err != nil {
+		return nil, err
+	}
+	for i := range entries {
+		entries[i].Book = books[i]
+	}
+	return entries, nil
+}
+
+// dateString formats a DATE column as YYYY-MM-DD, or nil when it is NULL
+func dateString(t sql.NullTime) *string {
+	if !t.Valid {
+		return nil
+	}
+	s := t.Time.Format(time.DateOnly)
+	return &s
+}
+
+// isBuiltinShelf reports whether jenis is one of the built-in shelf kinds
+func isBuiltinShelf(jenis string) bool {
+	for _, b := range builtinShelves {
+		if b.jenis == jenis {
+			return true
+		}
+	}
+	return false
+}
+
+// newShelfSlug derives a public slug from a shelf name, with a random
+// suffix so it cannot be guessed: "Bacaan Liburan" becomes
+// "bacaan-liburan-3f9a0c1d2e4b"
+func newShelfSlug(name string) (string, error) {
+	raw := make([]byte, 6)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if b.Len() >= 40 {
+			break
+		}
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			b.WriteRune(r)
+		} else if b.Len() > 0 && !strings.HasSuffix(b.String(), "-") {
+			b.WriteByte('-')
+		}
+	}
+	base := strings.Trim(b.String(), "-")
+	if base == "" {
+		return hex.EncodeToString(raw), nil
+	}
+	return base + "-" + hex.EncodeToString(raw), nil
+}
+
+// shelfSlug returns the slug a shelf has after its sharing is set to
+// public: kept while it stays public, new when it becomes public and nil
+// when it stops being public, so a link once shared can be revoked
+func shelfSlug(current *models.Shelf, name string, public bool) (*string, error) {
+	if !public {
+		return nil, nil
+	}
+	if current != nil && current.Slug != nil {
+		return current.Slug, nil
+	}
+	slug, err := newShelfSlug(name)
+	if err != nil {
+		return nil, err
+	}
+	return &slug, nil
+}
+
+// shelfETag computes the ETag of a shelf's JSON representation
+func shelfETag(shelf interface{}) string {
+	payload, _ := json.Marshal(shelf)
+	return utils.ETag(payload)
+}
+
+// respondWithShelf reloads a shelf with its books from the primary after a
+// write and sends it with its new ETag
+func respondWithShelf(c *fiber.Ctx, ctx context.Context, status int, message string, id int) error {
+	ctx = database.WithPrimary(ctx)
+	userID := currentUser(c).UserID
+	shelf, err := queryShelf(ctx, userID, strconv.Itoa(id))
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "shelf.get_failed", err)
+	}
+	books, err := queryShelfBooks(ctx, id, userID)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "shelf.get_failed", err)
+	}
+	result := models.ShelfWithBooks{Shelf: *shelf, Books: books}
+	c.Set(fiber.HeaderETag, shelfETag(result))
+	return utils.JSONResponse(c, status, message, result)
+}
+
+// loadShelf loads the shelf :shelf (an id or a built-in kind) of the
+// authenticated user from the primary. When the shelf is nil the response
+// (404 for an unknown shelf or one of another user) has been sent and the
+// caller returns the error as is.
+func loadShelf(c *fiber.Ctx, ctx context.Context) (*models.Shelf, error) {
+	shelf, err := queryShelf(database.WithPrimary(ctx), currentUser(c).UserID, c.Params("shelf"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, utils.ErrorResponse(c, fiber.StatusNotFound, "shelf.not_found")
+		}
+		return nil, utils.ErrorResponse(c, fiber.StatusInternalServerError, "shelf.get_failed", err)
+	}
+	return shelf, nil
+}
+
+// EnsureDefaultShelves creates the built-in shelves of the authenticated
+// user on first use. It must run after RequireAuth.
+func EnsureDefaultShelves(c *fiber.Ctx) error {
+	ctx, cancel := database.WithTimeout(c.UserContext())
+	defer cancel()
+
+	userID := currentUser(c).UserID
+	var count int
+	err := database.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM shelves WHERE user_id = ? AND jenis <> 'custom'", userID).Scan(&count)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "common.database_error", err)
+	}
+	if count < len(builtinShelves) {
+		var rows []string
+		var args []interface{}
+		for _, b := range builtinShelves {
+			rows = append(rows, "(?, ?, ?)")
+			args = append(args, userID, b.jenis, b.nama)
+		}
+		// The unique (user_id, nama_rak) key skips the shelves that exist
+		_, err := database.DB.ExecContext(ctx, "INSERT IGNORE INTO shelves (user_id, jenis, nama_rak) VALUES "+strings.Join(rows, ", "), args...)
+		if err != nil {
+			return utils.ErrorResponse(c, fiber.StatusInternalServerError, "common.database_error", err)
+		}
+	}
+	return c.Next()
+}
+
+// GetMyShelves lists the shelves of the authenticated user with their book counts
+// GET /api/v1/me/shelves
+func GetMyShelves(c *fiber.Ctx) error {
+	ctx, cancel := database.WithTimeout(c.UserContext())
+	defer cancel()
+
+	shelves, err := queryShelves(ctx, currentUser(c).UserID)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "shelf.list_failed", err)
+	}
+
+	if utils.NotModified(c, shelfETag(shelves), time.Time{}) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	return utils.JSONResponse(c, fiber.StatusOK, "shelf.list_success", shelves)
+}
+
+// GetMyShelf gets a shelf of the authenticated user with its books and the
+// reading progress of each. :shelf is the shelf id or a built-in kind
+// (want_to_read, reading, finished).
+// GET /api/v1/me/shelves/:shelf
+func GetMyShelf(c *fiber.Ctx) error {
+	ctx, cancel := database.WithTimeout(c.UserContext())
+	defer cancel()
+
+	userID := currentUser(c).UserID
+	shelf, err := queryShelf(ctx, userID, c.Params("shelf"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return utils.ErrorResponse(c, fiber.StatusNotFound, "shelf.not_found")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "shelf.get_failed", err)
+	}
+	books, err := queryShelfBooks(ctx, shelf.ShelfID, userID)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "shelf.get_failed", err)
+	}
+
+	result := models.ShelfWithBooks{Shelf: *shelf, Books: books}
+	// No Last-Modified: progress, book and rating changes do not touch
+	// shelves.updated_at, so only the ETag covers the whole payload
+	if utils.NotModified(c, shelfETag(result), time.Time{}) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	return utils.JSONResponse(c, fiber.StatusOK, "shelf.get_success", result)
+}
+
+// CreateShelf creates a custom shelf for the authenticated user. With
+// "public": true it gets a slug under which anyone can view it.
+// POST /api/v1/me/shelves {"nama_rak": "Bacaan Liburan", "public": false}
+func CreateShelf(c *fiber.Ctx) error {
+	shelf := new(models.Shelf)
+	if err := c.BodyParser(shelf); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "common.invalid_body")
+	}
+	shelf.NamaRak = strings.Join(strings.Fields(shelf.NamaRak), " ")
+
+	if errs := utils.ValidateStruct(shelf); errs != nil {
+		return utils.ValidationErrorResponse(c, errs)
+	}
+
+	slug, err := shelfSlug(nil, shelf.NamaRak, shelf.Public)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "shelf.create_failed", err)
+	}
+
+	ctx, cancel := database.WithTimeout(c.UserContext())
+	defer cancel()
+
+	result, err := database.DB.ExecContext(ctx,
+		"INSERT INTO shelves (user_id, jenis, nama_rak, is_public, slug) VALUES (?, ?, ?, ?, ?)",
+		currentUser(c).UserID, models.ShelfCustom, shelf.NamaRak, shelf.Public, slug,
+	)
+	if err != nil {
+		if isDuplicateKey(err) {
+			return utils.ErrorResponse(c, fiber.StatusConflict, "shelf.duplicate_name")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "shelf.create_failed", err)
+	}
+
+	id, _ := result.LastInsertId()
+	return respondWithShelf(c, ctx, fiber.StatusCreated, "shelf.created", int(id))
+}
+
+// UpdateShelf renames a custom shelf and shares or unshares any shelf.
+// Built-in shelves keep their name; nama_rak may be omitted for them.
+// PUT /api/v1/me/shelves/:shelf {"nama_rak": "...", "public": true}
+func UpdateShelf(c *fiber.Ctx) error {
+	shelf := new(models.Shelf)
+	if err := c.BodyParser(shelf); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "common.invalid_body")
+	}
+	shelf.NamaRak = strings.Join(strings.Fields(shelf.NamaRak), " ")
+
+	ctx, cancel := database.WithTimeout(c.UserContext())
+	defer cancel()
+
+	current, err := loadShelf(c, ctx)
+	if current == nil {
+		return err
+	}
+	if current.Jenis != models.ShelfCustom {
+		if shelf.NamaRak != "" && shelf.NamaRak != current.NamaRak {
+			return utils.ErrorResponse(c, fiber.StatusBadRequest, "shelf.builtin_fixed")
+		}
+		shelf.NamaRak = current.NamaRak
+	}
+
+	if errs := utils.ValidateStruct(shelf); errs != nil {
+		return utils.ValidationErrorResponse(c, errs)
+	}
+	// The ETag clients hold covers the shelf with its books, as sent by
+	// GetMyShelf and every shelf write
+	if utils.HasIfMatch(c) {
+		books, err := queryShelfBooks(database.WithPrimary(ctx), current.ShelfID, currentUser(c).UserID)
+		if err != nil {
+			return utils.ErrorResponse(c, fiber.StatusInternalServerError, "shelf.get_failed", err)
+		}
+		if utils.PreconditionFailed(c, shelfETag(models.ShelfWithBooks{Shelf: *current, Books: books})) {
+			return utils.ErrorResponse(c, fiber.StatusPreconditionFailed, "common.precondition_failed")
+		}
+	}
+
+	slug, err := shelfSlug(current, shelf.NamaRak, shelf.Public)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "shelf.update_failed", err)
+	}
+
+	res, err := database.DB.ExecContext(ctx,
+		"UPDATE shelves SET nama_rak = ?, is_public = ?, slug = ?, updated_at = CURRENT_TIMESTAMP(6) WHERE shelf_id = ? AND updated_at = ?",
+		shelf.NamaRak, shelf.Public, slug, current.ShelfID, current.UpdatedAt,
+	)
+	if err != nil {
+		if isDuplicateKey(err) {
+			return utils.ErrorResponse(c, fiber.StatusConflict, "shelf.duplicate_name")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "shelf.update_failed", err)
+	}
+
+	rowsAffected, _ := res.RowsAffected()
+	if rowsAffected == 0 {
+		if utils.HasIfMatch(c) {
+			return utils.ErrorResponse(c, fiber.StatusPreconditionFailed, "common.precondition_failed")
+		}
+		return utils.ErrorResponse(c, fiber.StatusConflict, "common.concurrent_update")
+	}
+
+	return respondWithShelf(c, ctx, fiber.StatusOK, "shelf.updated", current.ShelfID)
+}
+
+// DeleteShelf deletes a custom shelf of the authenticated user; the books
+// themselves and their reading progress stay
+// DELETE /api/v1/me/shelves/:shelf
+func DeleteShelf(c *fiber.Ctx) error {
+	ctx, cancel := database.WithTimeout(c.UserContext())
+	defer cancel()
+
+	current, err := loadShelf(c, ctx)
+	if current == nil {
+		return err
+	}
+	if current.Jenis != models.ShelfCustom {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "shelf.builtin_fixed")
+	}
+
+	if _, err := database.DB.ExecContext(ctx, "DELETE FROM shelves WHERE shelf_id = ?", current.ShelfID); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "shelf.delete_failed", err)
+	}
+
+	return utils.JSONResponse(c, fiber.StatusOK, "shelf.deleted", nil)
+}
+
+// GetSharedShelf gets a public shelf by its slug, without the owner's
+// reading progress. No login is needed.
+// GET /api/v1/shelves/:slug
+func GetSharedShelf(c *fiber.Ctx) error {
+	ctx, cancel := database.WithTimeout(c.UserContext())
+	defer cancel()
+
+	var shelfID int
+	shared := models.SharedShelf{}
+	err := database.Reader(ctx).QueryRowContext(ctx,
+		"SELECT s.shelf_id, s.nama_rak, s.jenis, s.slug, u.nama_lengkap, s.updated_at FROM shelves s JOIN users u ON u.user_id = s.user_id"+
+			" WHERE s.slug = ? AND s.is_public", c.Params("slug"),
+	).Scan(&shelfID, &shared.NamaRak, &shared.Jenis, &shared.Slug, &shared.Pemilik, &shared.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return utils.ErrorResponse(c, fiber.StatusNotFound, "shelf.not_found")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "shelf.get_failed", err)
+	}
+
+	// User 0 matches no reading progress
+	entries, err := queryShelfBooks(ctx, shelfID, 0)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "shelf.get_failed", err)
+	}
+	shared.Books = make([]models.Book, len(entries))
+	for i := range entries {
+		shared.Books[i] = entries[i].Book
+	}
+
+	// ETag only, as for GetMyShelf
+	if utils.NotModified(c, shelfETag(shared), time.Time{}) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	return utils.JSONResponse(c, fiber.StatusOK, "shelf.get_success", shared)
+}
@@ -0,0 +1,328 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"pojok_baca_api/cache"
+	"pojok_baca_api/database"
+	"pojok_baca_api/models"
+	"pojok_baca_api/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Tag cloud size: ?limit= defaults to defaultTagLimit and is capped at maxTagLimit
+const (
+	defaultTagLimit = 100
+	maxTagLimit     = 1000
+)
+
+// tagColumns is the column list shared by every tag SELECT, in scanTag order
+const tagColumns = "tag_id, nama_tag, created_at, updated_at"
+
+// scanTag scans a row selected with tagColumns into a Tag
+func scanTag(row rowScanner, tag *models.Tag) error {
+	return row.Scan(&tag.TagID, &tag.NamaTag, &tag.CreatedAt, &tag.UpdatedAt)
+}
+
+// queryTagByID loads a single tag; it returns sql.ErrNoRows when it does not exist
+func queryTagByID(ctx context.Context, id int) (*models.Tag, error) {
+	tag := new(models.Tag)
+	err := scanTag(database.Reader(ctx).QueryRowContext(ctx, "SELECT "+tagColumns+" FROM tags WHERE tag_id = ?", id), tag)
+	if err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+// queryTagCloud loads the tags carried by at least minCount books, most used
+// first, never returning a nil slice
+func queryTagCloud(ctx context.Context, search string, minCount, limit int) ([]models.TagCount, error) {
+	query := "SELECT t.tag_id, t.nama_tag, t.created_at, t.updated_at, COUNT(bt.book_id) AS book_count" +
+		" FROM tags t LEFT JOIN book_tags bt ON bt.tag_id = t.tag_id"
+	var args []interface{}
+	if search != "" {
+		query += " WHERE t.nama_tag LIKE ?"
+		args = append(args, "%"+escapeLike(search)+"%")
+	}
+	query += " GROUP BY t.tag_id HAVING book_count >= ? ORDER BY book_count DESC, t.nama_tag LIMIT ?"
+	args = append(args, minCount, limit)
+
+	rows, err := database.Reader(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []models.TagCount{}
+	for rows.Next() {
+		var tag models.TagCount
+		if err := rows.Scan(&tag.TagID, &tag.NamaTag, &tag.CreatedAt, &tag.UpdatedAt, &tag.BookCount); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// tagETag computes the ETag of a tag's JSON representation
+func tagETag(tag *models.Tag) string {
+	payload, _ := json.Marshal(tag)
+	return utils.ETag(payload)
+}
+
+// touchTaggedBooks bumps updated_at of every book carrying one of the tags,
+// whose representation changes with the tag
+func touchTaggedBooks(ctx context.Context, db dbExecutor, ids []int) error {
+	placeholders, args := intArgs(ids)
+	_, err := db.ExecContext(ctx,
+		"UPDATE books SET updated_at = CURRENT_TIMESTAMP(6) WHERE book_id IN (SELECT book_id FROM book_tags WHERE tag_id IN ("+placeholders+"))", args...)
+	return err
+}
+
+// invalidateTagCache drops the tag clouds and every cached book (books carry their tags)
+func invalidateTagCache(ctx context.Context) {
+	cache.Default.Invalidate(ctx, cache.AllTagLists, cache.AllBooks, cache.AllAuthorBooks)
+}
+
+// GetTagCloud lists tags with the number of books carrying each, most used
+// first. ?q= filters on the name, ?min_count= (default 1) hides rare tags and
+// ?limit= (default 100, at most 1000) bounds the list.
+// GET /api/v1/tags
+func GetTagCloud(c *fiber.Ctx) error {
+	minCount, limit := 1, defaultTagLimit
+	for name, dest := range map[string]*int{"min_count": &minCount, "limit": &limit} {
+		value := c.Query(name)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return utils.ErrorResponse(c, fiber.StatusBadRequest, "tag.invalid_param", name)
+		}
+		*dest = n
+	}
+	if limit == 0 || limit > maxTagLimit {
+		limit = maxTagLimit
+	}
+
+	ctx, cancel := database.WithTimeout(c.UserContext())
+	defer cancel()
+
+	search := normalizeTag(c.Query("q"))
+	tags, err := cache.Default.GetOrLoad(ctx, "tags", cache.TagListKey(normalizedQuery(c)), func(ctx context.Context) (interface{}, error) {
+		return queryTagCloud(ctx, search, minCount, limit)
+	})
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "tag.list_failed", err)
+	}
+
+	// Clients holding the same list get 304 Not Modified
+	if utils.NotModified(c, utils.ETag(tags), time.Time{}) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	if isEmptyJSONArray(tags) {
+		return utils.JSONResponse(c, fiber.StatusOK, "tag.list_empty", []models.TagCount{})
+	}
+
+	return utils.JSONResponse(c, fiber.StatusOK, "tag.list_success", tags)
+}
+
+// RenameTag renames a tag on every book carrying it. Renaming to the name of
+// another tag is refused; merge the tags instead.
+// PUT /api/v1/tags/:id
+func RenameTag(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "tag.invalid_id")
+	}
+
+	tag := new(models.Tag)
+	if err := c.BodyParser(tag); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "common.invalid_body")
+	}
+	tag.NamaTag = normalizeTag(tag.NamaTag)
+
+	if errs := utils.ValidateStruct(tag); errs != nil {
+		return utils.ValidationErrorResponse(c, errs)
+	}
+
+	ctx, cancel := database.WithTimeout(c.UserContext())
+	defer cancel()
+
+	query := "UPDATE tags SET nama_tag = ?, updated_at = CURRENT_TIMESTAMP(6) WHERE tag_id = ?"
+	args := []interface{}{tag.NamaTag, id}
+
+	// Optimistic concurrency: with If-Match the rename only applies to the version the client saw
+	if utils.HasIfMatch(c) {
+		current, err := queryTagByID(database.WithPrimary(ctx), id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return utils.ErrorResponse(c, fiber.StatusNotFound, "tag.not_found")
+			}
+			return utils.ErrorResponse(c, fiber.StatusInternalServerError, "tag.update_failed", err)
+		}
+		if utils.PreconditionFailed(c, tagETag(current)) {
+			return utils.ErrorResponse(c, fiber.StatusPreconditionFailed, "common.precondition_failed")
+		}
+		query += " AND updated_at = ?"
+		args = append(args, current.UpdatedAt)
+	}
+
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "tag.update_failed", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		if isDuplicateKey(err) {
+			return utils.ErrorResponse(c, fiber.StatusConflict, "tag.duplicate_name")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "tag.update_failed", err)
+	}
+
+	// updated_at always changes, so no affected rows means the tag is gone
+	// or, with If-Match, that someone else changed it first
+	rowsAffected, _ := res.RowsAffected()
+	if rowsAffected == 0 {
+		if utils.HasIfMatch(c) {
+			return utils.ErrorResponse(c, fiber.StatusPreconditionFailed, "common.precondition_failed")
+		}
+		return utils.ErrorResponse(c, fiber.StatusNotFound, "tag.not_found")
+	}
+
+	if err := touchTaggedBooks(ctx, tx, []int{id}); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "tag.update_failed", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "tag.update_failed", err)
+	}
+
+	invalidateTagCache(ctx)
+
+	renamed, err := queryTagByID(database.WithPrimary(ctx), id)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "tag.update_failed", err)
+	}
+	c.Set(fiber.HeaderETag, tagETag(renamed))
+	return utils.JSONResponse(c, fiber.StatusOK, "tag.updated", renamed)
+}
+
+// tagMergeResult reports a merge
+type tagMergeResult struct {
+	Tag           *models.Tag `json:"tag"`
+	MergedIDs     []int       `json:"merged_ids"`
+	BooksAffected int64       `json:"books_affected"`
+}
+
+// errTagNotFound is returned by mergeTags when the target or a source does not exist
+var errTagNotFound = errors.New("tag not found")
+
+// mergeTags gives the target tag to every book carrying one of the sources
+// and deletes the sources, all in one transaction
+func mergeTags(ctx context.Context, target int, sources []int) (int64, error) {
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Lock the tags involved so none is renamed or deleted meanwhile
+	placeholders, args := intArgs(append([]int{target}, sources...))
+	rows, err := tx.QueryContext(ctx, "SELECT tag_id FROM tags WHERE tag_id IN ("+placeholders+") FOR UPDATE", args...)
+	if err != nil {
+		return 0, err
+	}
+	found := 0
+	for rows.Next() {
+		found++
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if found != len(sources)+1 {
+		return 0, errTagNotFound
+	}
+
+	if err := touchTaggedBooks(ctx, tx, sources); err != nil {
+		return 0, err
+	}
+	sourcePlaceholders, sourceArgs := intArgs(sources)
+	var affected int64
+	err = tx.QueryRowContext(ctx, "SELECT COUNT(DISTINCT book_id) FROM book_tags WHERE tag_id IN ("+sourcePlaceholders+")", sourceArgs...).Scan(&affected)
+	if err != nil {
+		return 0, err
+	}
+	// Books that already carry the target keep a single link
+	if _, err := tx.ExecContext(ctx,
+		"INSERT IGNORE INTO book_tags (book_id, tag_id) SELECT book_id, ? FROM book_tags WHERE tag_id IN ("+sourcePlaceholders+")",
+		append([]interface{}{target}, sourceArgs...)...); err != nil {
+		return 0, err
+	}
+	// Deleting the sources removes their book links (ON DELETE CASCADE)
+	if _, err := tx.ExecContext(ctx, "DELETE FROM tags WHERE tag_id IN ("+sourcePlaceholders+")", sourceArgs...); err != nil {
+		return 0, err
+	}
+	return affected, tx.Commit()
+}
+
+// MergeTags merges tags that mean the same ("scifi", "sci-fi") into one: every
+// book carrying a source tag gets the target tag and the sources are deleted.
+// POST /api/v1/tags/merge {"target_id": 1, "source_ids": [2, 3]}
+func MergeTags(c *fiber.Ctx) error {
+	req := new(models.TagMerge)
+	if err := c.BodyParser(req); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "common.invalid_body")
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		return utils.ValidationErrorResponse(c, errs)
+	}
+
+	// Each source once, and never the target itself
+	seen := map[int]bool{}
+	var sources []int
+	for _, id := range req.SourceIDs {
+		if id == req.TargetID {
+			return utils.ErrorResponse(c, fiber.StatusBadRequest, "tag.merge_into_itself")
+		}
+		if !seen[id] {
+			seen[id] = true
+			sources = append(sources, id)
+		}
+	}
+
+	ctx, cancel := database.WithTimeout(c.UserContext())
+	defer cancel()
+
+	affected, err := mergeTags(ctx, req.TargetID, sources)
+	if err != nil {
+		if errors.Is(err, errTagNotFound) {
+			return utils.ErrorResponse(c, fiber.StatusNotFound, "tag.not_found")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "tag.merge_failed", err)
+	}
+	log.Printf("Tag %v digabung ke tag %d (%d buku)", sources, req.TargetID, affected)
+
+	invalidateTagCache(ctx)
+
+	tag, err := queryTagByID(database.WithPrimary(ctx), req.TargetID)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "tag.merge_failed", err)
+	}
+	c.Set(fiber.HeaderETag, tagETag(tag))
+	return utils.JSONResponse(c, fiber.StatusOK, "tag.merged", tagMergeResult{
+		Tag:           tag,
+		MergedIDs:     sources,
+		BooksAffected: affected,
+	})
+}
@@ -0,0 +1,111 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// health.go
+// This file runs readiness checks against the service's dependencies
+// (database, mailer, storage, migrations). Each check runs concurrently
+// with its own timeout and reports its own result.
+
+// DefaultTimeout is used for checks registered without a timeout.
+const DefaultTimeout = 2 * time.Second
+
+// Check is a single dependency probe.
+type Check struct {
+	Name    string
+	Timeout time.Duration
+	// Optional checks are reported but do not make the service unready.
+	Optional bool
+	Fn       func(ctx context.Context) error
+}
+
+// Result is the outcome of one check.
+type Result struct {
+	Status     string `json:"status"` // "up" or "down"
+	DurationMS int64  `json:"duration_ms"`
+	Optional   bool   `json:"optional,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Report is the outcome of all checks.
+type Report struct {
+	Ready  bool              `json:"ready"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// Checker holds the registered readiness checks.
+type Checker struct {
+	mu     sync.RWMutex
+	checks []Check
+}
+
+// NewChecker creates an empty Checker.
+func NewChecker() *Checker {
+	return &Checker{}
+}
+
+// Register adds a readiness check.
+func (c *Checker) Register(check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, check)
+}
+
+// Run executes every check concurrently and returns the combined report.
+func (c *Checker) Run(ctx context.Context) Report {
+	c.mu.RLock()
+	checks := append([]Check(nil), c.checks...)
+	c.mu.RUnlock()
+
+	report := Report{Ready: true, Checks: make(map[string]Result, len(checks))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, check := range checks {
+		wg.Add(1)
+		go func(check Check) {
+			defer wg.Done()
+			result := runCheck(ctx, check)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[check.Name] = result
+			if result.Status != "up" && !check.Optional {
+				report.Ready = false
+			}
+		}(check)
+	}
+	wg.Wait()
+	return report
+}
+
+func runCheck(ctx context.Context, check Check) Result {
+	timeout := check.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	errCh := make(chan error, 1)
+	go func() { errCh <- check.Fn(ctx) }()
+
+	var err error
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := Result{Status: "up", DurationMS: time.Since(start).Milliseconds(), Optional: check.Optional}
+	if err != nil {
+		result.Status = "down"
+		result.Error = err.Error()
+	}
+	return result
+}
@@ -0,0 +1,129 @@
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// i18n.go
+// This file holds the message catalogs used to build API responses and
+// negotiates the response language from the Accept-Language header.
+
+const (
+	// Indonesian is the default language of the API.
+	Indonesian = "id"
+	// English is the secondary supported language.
+	English = "en"
+
+	// DefaultLocale is used when the client does not ask for a supported language.
+	DefaultLocale = Indonesian
+)
+
+// localeKey is the key used to cache the negotiated locale in c.Locals.
+const localeKey = "i18n_locale"
+
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+// catalogs maps a locale to its message catalog (message key -> template).
+var catalogs = loadCatalogs()
+
+func loadCatalogs() map[string]map[string]string {
+	result := make(map[string]map[string]string)
+	for _, locale := range []string{Indonesian, English} {
+		raw, err := localeFiles.ReadFile("locales/" + locale + ".json")
+		if err != nil {
+			log.Fatalf("Gagal membaca katalog pesan %s: %v", locale, err)
+		}
+		catalog := make(map[string]string)
+		if err := json.Unmarshal(raw, &catalog); err != nil {
+			log.Fatalf("Gagal mem-parsing katalog pesan %s: %v", locale, err)
+		}
+		result[locale] = catalog
+	}
+	return result
+}
+
+// T resolves a message key in the given locale and formats it with args.
+// Missing keys fall back to the default locale, and unknown keys are
+// returned as-is so plain strings keep working.
+func T(locale, key string, args ...interface{}) string {
+	template, ok := catalogs[locale][key]
+	if !ok {
+		template, ok = catalogs[DefaultLocale][key]
+	}
+	if !ok {
+		template = key
+	}
+	if len(args) == 0 {
+		return template
+	}
+	return fmt.Sprintf(template, args...)
+}
+
+// Locale returns the negotiated locale for the request, caching the result
+// on the context so Accept-Language is parsed only once per request.
+func Locale(c *fiber.Ctx) string {
+	if locale, ok := c.Locals(localeKey).(string); ok {
+		return locale
+	}
+	locale := Negotiate(c.Get(fiber.HeaderAcceptLanguage))
+	c.Locals(localeKey, locale)
+	return locale
+}
+
+// Negotiate picks the best supported locale from an Accept-Language header
+// value such as "en-US,en;q=0.9,id;q=0.8". It returns DefaultLocale when no
+// supported language is acceptable.
+func Negotiate(header string) string {
+	type candidate struct {
+		locale string
+		q      float64
+	}
+
+	var candidates []candidate
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		tag, q := part, 1.0
+		if idx := strings.Index(part, ";"); idx >= 0 {
+			tag = strings.TrimSpace(part[:idx])
+			for _, param := range strings.Split(part[idx+1:], ";") {
+				param = strings.TrimSpace(param)
+				if strings.HasPrefix(param, "q=") {
+					if parsed, err := strconv.ParseFloat(param[2:], 64); err == nil {
+						q = parsed
+					}
+				}
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+
+		// Only the primary subtag matters: "en-US" and "en-GB" both map to "en".
+		// Cloned because the header can be backed by fasthttp's reused request buffer
+		primary := strings.Clone(strings.ToLower(strings.SplitN(tag, "-", 2)[0]))
+		if _, ok := catalogs[primary]; ok {
+			candidates = append(candidates, candidate{locale: primary, q: q})
+		}
+	}
+
+	if len(candidates) == 0 {
+		return DefaultLocale
+	}
+	sort.SliceStable(candidates, func(a, b int) bool {
+		return candidates[a].q > candidates[b].q
+	})
+	return candidates[0].locale
+}
@@ -0,0 +1,145 @@
+package i18n
+
+import (
+	"io"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", DefaultLocale},
+		{"en", English},
+		{"id", Indonesian},
+		// Only the primary subtag matters, in any case
+		{"en-US", English},
+		{"EN-gb", English},
+		{"id-ID,id;q=0.9", Indonesian},
+		// Highest quality wins; equal qualities keep the header order
+		{"id;q=0.5,en;q=0.8", English},
+		{"en-US,en;q=0.9,id;q=0.8", English},
+		{"en;q=0.7,id;q=0.7", English},
+		{"id, en", Indonesian},
+		// Unsupported languages are skipped
+		{"fr-FR,de;q=0.9", DefaultLocale},
+		{"fr,en;q=0.1", English},
+		// q=0 means "not acceptable"
+		{"en;q=0", DefaultLocale},
+		{"en;q=0,id;q=0", DefaultLocale},
+		// Malformed quality values count as 1
+		{"id;q=0.5,en;q=abc", English},
+		{" , ,en", English},
+		{"*", DefaultLocale},
+	}
+	for _, tt := range tests {
+		if got := Negotiate(tt.header); got != tt.want {
+			t.Errorf("Negotiate(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestT(t *testing.T) {
+	tests := []struct {
+		locale string
+		key    string
+		args   []interface{}
+		want   string
+	}{
+		{English, "validation.required", []interface{}{"email"}, "email is required"},
+		{Indonesian, "validation.failed", nil, catalogs[Indonesian]["validation.failed"]},
+		// Unsupported locales fall back to the default catalog
+		{"fr", "validation.failed", nil, catalogs[DefaultLocale]["validation.failed"]},
+		// Unknown keys are returned as-is, formatted when there are args
+		{English, "plain message", nil, "plain message"},
+		{English, "count: %d", []interface{}{3}, "count: 3"},
+	}
+	for _, tt := range tests {
+		if got := T(tt.locale, tt.key, tt.args...); got != tt.want {
+			t.Errorf("T(%q, %q, %v) = %q, want %q", tt.locale, tt.key, tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestTFallsBackToDefaultLocaleForMissingKeys(t *testing.T) {
+	catalogs[DefaultLocale]["test.only_default"] = "hanya bahasa Indonesia"
+	defer delete(catalogs[DefaultLocale], "test.only_default")
+
+	if got := T(English, "test.only_default"); got != "hanya bahasa Indonesia" {
+		t.Errorf("T = %q, want the default locale's message", got)
+	}
+}
+
+// formatVerbs matches the fmt verbs of a message template
+var formatVerbs = regexp.MustCompile(`%[-+# 0]*[0-9]*[a-zA-Z%]`)
+
+func TestCatalogsMatch(t *testing.T) {
+	base := catalogs[DefaultLocale]
+	for locale, catalog := range catalogs {
+		for key, template := range base {
+			other, ok := catalog[key]
+			if !ok {
+				t.Errorf("%s: missing %q", locale, key)
+				continue
+			}
+			// Both translations must take the same arguments
+			want, got := formatVerbs.FindAllString(template, -1), formatVerbs.FindAllString(other, -1)
+			sort.Strings(want)
+			sort.Strings(got)
+			if len(want) != len(got) {
+				t.Errorf("%s: %q takes %v, %s takes %v", locale, key, got, DefaultLocale, want)
+				continue
+			}
+			for i := range want {
+				if want[i] != got[i] {
+					t.Errorf("%s: %q takes %v, %s takes %v", locale, key, got, DefaultLocale, want)
+					break
+				}
+			}
+		}
+		for key := range catalog {
+			if _, ok := base[key]; !ok {
+				t.Errorf("%s: %q is not in the %s catalog", locale, key, DefaultLocale)
+			}
+		}
+	}
+}
+
+func TestLocale(t *testing.T) {
+	app := fiber.New()
+	app.Get("/", func(c *fiber.Ctx) error {
+		first := Locale(c)
+		// Cached: later changes to the header do not matter
+		c.Request().Header.Set(fiber.HeaderAcceptLanguage, "fr")
+		return c.SendString(first + " " + Locale(c))
+	})
+
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", "id id"},
+		{"en-US", "en en"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		if tt.header != "" {
+			req.Header.Set(fiber.HeaderAcceptLanguage, tt.header)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if got := string(body); got != tt.want {
+			t.Errorf("Accept-Language %q: locales = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+)
+
+// lifecycle.go
+// This file provides a small registry of start/stop hooks so every
+// subsystem (database, HTTP server, background workers, ...) is started in
+// registration order and stopped in reverse order on shutdown.
+
+// Hook describes how to start and stop one subsystem.
+// Either function may be nil.
+type Hook struct {
+	Name  string
+	Start func(ctx context.Context) error
+	Stop  func(ctx context.Context) error
+}
+
+// Manager runs registered hooks.
+type Manager struct {
+	mu      sync.Mutex
+	hooks   []Hook
+	started int // number of hooks whose Start succeeded
+}
+
+// New creates an empty lifecycle manager.
+func New() *Manager {
+	return &Manager{}
+}
+
+// Register appends a hook. Hooks must be registered before Start is called.
+func (m *Manager) Register(h Hook) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hooks = append(m.hooks, h)
+}
+
+// Start runs every Start hook in registration order. If one fails, the
+// hooks already started are stopped in reverse order and the error returned.
+func (m *Manager) Start(ctx context.Context) error {
+	m.mu.Lock()
+	hooks := append([]Hook(nil), m.hooks...)
+	m.mu.Unlock()
+
+	for i, h := range hooks {
+		if h.Start != nil {
+			log.Printf("Memulai %s...", h.Name)
+			if err := h.Start(ctx); err != nil {
+				startErr := fmt.Errorf("gagal memulai %s: %w", h.Name, err)
+				m.setStarted(i)
+				return errors.Join(startErr, m.Stop(ctx))
+			}
+		}
+		m.setStarted(i + 1)
+	}
+	return nil
+}
+
+// Stop runs the Stop hooks of every started subsystem in reverse order.
+// All hooks are attempted even if some fail; their errors are joined.
+func (m *Manager) Stop(ctx context.Context) error {
+	m.mu.Lock()
+	hooks := append([]Hook(nil), m.hooks[:m.started]...)
+	m.started = 0
+	m.mu.Unlock()
+
+	var errs []error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		h := hooks[i]
+		if h.Stop == nil {
+			continue
+		}
+		log.Printf("Menghentikan %s...", h.Name)
+		if err := h.Stop(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("gagal menghentikan %s: %w", h.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m *Manager) setStarted(n int) {
+	m.mu.Lock()
+	m.started = n
+	m.mu.Unlock()
+}
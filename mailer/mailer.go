@@ -0,0 +1,87 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/smtp"
+	"strings"
+
+	"pojok_baca_api/config"
+	"pojok_baca_api/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrNotConfigured is returned by Send when no SMTP server is configured.
+var ErrNotConfigured = errors.New("SMTP belum dikonfigurasi")
+
+// Mailer sends plain-text emails through the configured SMTP server.
+type Mailer struct {
+	cfg config.SMTPConfig
+}
+
+// New creates a Mailer from the SMTP configuration.
+func New(cfg config.SMTPConfig) *Mailer {
+	return &Mailer{cfg: cfg}
+}
+
+// Send delivers a plain-text UTF-8 email to the given recipients.
+// The delivery is recorded as a client span on the trace carried by ctx.
+func (m *Mailer) Send(ctx context.Context, to []string, subject, body string) (err error) {
+	_, span := tracing.Tracer("mailer").Start(ctx, "smtp.send",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.ServerAddress(m.cfg.Host),
+			attribute.Int("smtp.recipients", len(to)),
+		),
+	)
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	if !m.cfg.Enabled() {
+		return ErrNotConfigured
+	}
+
+	msg := []byte(
+		"To: " + strings.Join(to, ", ") + "\r\n" +
+			"From: " + m.cfg.From + "\r\n" +
+			"Subject: " + subject + "\r\n" +
+			"Content-Type: text/plain; charset=UTF-8\r\n" +
+			"\r\n" +
+			body,
+	)
+
+	var auth smtp.Auth
+	if m.cfg.Username != "" {
+		auth = smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, m.cfg.Host)
+	}
+
+	return smtp.SendMail(m.cfg.Host+":"+m.cfg.Port, auth, m.cfg.From, to, msg)
+}
+
+// Ping checks that the SMTP server accepts TCP connections.
+func (m *Mailer) Ping(ctx context.Context) error {
+	if !m.cfg.Enabled() {
+		return ErrNotConfigured
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(m.cfg.Host, m.cfg.Port))
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// Enabled reports whether an SMTP server is configured.
+func (m *Mailer) Enabled() bool {
+	return m.cfg.Enabled()
+}
@@ -1,34 +1,276 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"pojok_baca_api/cache"
+	"pojok_baca_api/config"
 	"pojok_baca_api/database"
+	"pojok_baca_api/enrichment"
+	"pojok_baca_api/health"
+	"pojok_baca_api/lifecycle"
+	"pojok_baca_api/mailer"
+	"pojok_baca_api/metrics"
+	"pojok_baca_api/recommend"
 	"pojok_baca_api/routes"
+	"pojok_baca_api/tracing"
+	"syscall"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/joho/godotenv"
 )
 
 func main() {
-    // Load environment variables
-    if err := godotenv.Load(); err != nil {
-        log.Fatalf("Error loading .env file: %v", err)
+    // Load and validate configuration (env, optional .env, optional CONFIG_FILE)
+    cfg, err := config.Load()
+    if err != nil {
+        log.Fatalf("Error loading configuration: %v", err)
     }
 
-    // Connect to database
-    database.ConnectDB()
+    // Cancelled on SIGINT/SIGTERM so the app can shut down gracefully
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+    defer stop()
+
+    // Subsystems are started in registration order and stopped in reverse
+    lc := lifecycle.New()
+
+    // Tracing; registered first so it is stopped last and flushes every span
+    var shutdownTracing func(context.Context) error
+    lc.Register(lifecycle.Hook{
+        Name: "tracing",
+        Start: func(ctx context.Context) error {
+            var err error
+            shutdownTracing, err = tracing.Setup(ctx, cfg.Tracing)
+            return err
+        },
+        Stop: func(ctx context.Context) error {
+            return shutdownTracing(ctx)
+        },
+    })
+
+    // Database connection
+    lc.Register(lifecycle.Hook{
+        Name: "database",
+        Start: func(ctx context.Context) error {
+            if err := database.ConnectDB(ctx, cfg.Database); err != nil {
+                return err
+            }
+            metrics.RegisterDB(database.DB, "primary")
+
+            // Optional read replicas for catalog reads
+            if err := database.ConnectReplicas(ctx, cfg.Database); err != nil {
+                return err
+            }
+            for _, r := range database.Replicas {
+                metrics.RegisterDB(r.DB, r.Name)
+            }
+
+            if cfg.Database.AutoMigrate {
+                return database.Migrate(ctx, database.DB)
+            }
+            return nil
+        },
+        Stop: func(ctx context.Context) error {
+            database.CloseDB()
+            return nil
+        },
+    })
+
+    // Background health checks that fail catalog reads over to the primary
+    replicaCtx, stopReplicaChecks := context.WithCancel(context.Background())
+    replicaChecksDone := make(chan struct{})
+    lc.Register(lifecycle.Hook{
+        Name: "replica health checks",
+        Start: func(ctx context.Context) error {
+            go func() {
+                defer close(replicaChecksDone)
+                database.RunReplicaHealthChecks(replicaCtx, cfg.Database.ReplicaHealthInterval)
+            }()
+            return nil
+        },
+        Stop: func(ctx context.Context) error {
+            stopReplicaChecks()
+            select {
+            case <-replicaChecksDone:
+                return nil
+            case <-ctx.Done():
+                return ctx.Err()
+            }
+        },
+    })
+
+    // Catalog response cache (in-process LRU or Redis)
+    // Loads right after an invalidation may read from a lagging replica
+    var cacheSettle time.Duration
+    if len(cfg.Database.ReplicaHosts) > 0 {
+        cacheSettle = cfg.Database.ReadYourWritesWindow
+    }
+    catalogCache := cache.Setup(cfg.Cache, cacheSettle)
+    lc.Register(lifecycle.Hook{
+        Name: "cache",
+        Stop: func(ctx context.Context) error {
+            return catalogCache.Close()
+        },
+    })
+
+    // Background job that precomputes similar books for recommendations
+    recommendCtx, stopRecommendations := context.WithCancel(context.Background())
+    recommendationsDone := make(chan struct{})
+    lc.Register(lifecycle.Hook{
+        Name: "recommendations",
+        Start: func(ctx context.Context) error {
+            go func() {
+                defer close(recommendationsDone)
+                recommend.Run(recommendCtx, cfg.Recommendations)
+            }()
+            return nil
+        },
+        Stop: func(ctx context.Context) error {
+            stopRecommendations()
+            select {
+            case <-recommendationsDone:
+                return nil
+            case <-ctx.Done():
+                return ctx.Err()
+            }
+        },
+    })
+
+    // Writes the book views that feed recommendations; stopped after the
+    // HTTP server and before the database, so queued views are not lost
+    viewsCtx, stopViews := context.WithCancel(context.Background())
+    viewsDone := make(chan struct{})
+    lc.Register(lifecycle.Hook{
+        Name: "book views",
+        Start: func(ctx context.Context) error {
+            go func() {
+                defer close(viewsDone)
+                recommend.RecordViews(viewsCtx, database.DB)
+            }()
+            return nil
+        },
+        Stop: func(ctx context.Context) error {
+            stopViews()
+            select {
+            case <-viewsDone:
+                return nil
+            case <-ctx.Done():
+                return ctx.Err()
+            }
+        },
+    })
+
+    // Initialize mailer used for password reset emails
+    mail := mailer.New(cfg.SMTP)
+
+    // Metadata providers that pre-fill new books from an ISBN or title
+    enricher := enrichment.New(cfg.Enrichment)
+
+    // Readiness checks for /readyz
+    checker := health.NewChecker()
+    checker.Register(health.Check{
+        Name: "database",
+        Fn: func(ctx context.Context) error {
+            return database.DB.PingContext(ctx)
+        },
+    })
+    checker.Register(health.Check{
+        Name: "migrations",
+        Fn: func(ctx context.Context) error {
+            status, err := database.GetMigrationStatus(ctx, database.DB)
+            if err != nil {
+                return err
+            }
+            if len(status.Pending) > 0 {
+                return fmt.Errorf("pending migrations: %v", status.Pending)
+            }
+            return nil
+        },
+    })
+    checker.Register(health.Check{
+        Name: "storage",
+        Fn: func(ctx context.Context) error {
+            _, err := os.ReadDir(cfg.App.PublicDir)
+            return err
+        },
+    })
+    for i := range cfg.Database.ReplicaHosts {
+        // Replicas are optional: reads fail over to the primary when they are down
+        name := fmt.Sprintf("replica-%d", i+1)
+        checker.Register(health.Check{
+            Name:     "database_" + name,
+            Optional: true,
+            Fn: func(ctx context.Context) error {
+                for _, r := range database.Replicas {
+                    if r.Name == name {
+                        return r.DB.PingContext(ctx)
+                    }
+                }
+                return fmt.Errorf("%s is not connected", name)
+            },
+        })
+    }
+    if cfg.Cache.Backend == "redis" {
+        // A cache outage only costs latency: reads fall back to the database
+        checker.Register(health.Check{
+            Name:     "cache",
+            Optional: true,
+            Fn:       catalogCache.Store().Ping,
+        })
+    }
+    if mail.Enabled() {
+        // Password reset still answers without mail, so the mailer is not required for readiness
+        checker.Register(health.Check{
+            Name:     "mailer",
+            Optional: true,
+            Fn:       mail.Ping,
+        })
+    }
 
     // Initialize Fiber app
     app := fiber.New()
 
     // Register routes
-    routes.SetupRoutes(app)
+    routes.SetupRoutes(app, cfg, mail, enricher, checker)
+
+    // HTTP server; registered last so it stops accepting requests first
+    serverErr := make(chan error, 1)
+    lc.Register(lifecycle.Hook{
+        Name: "http server",
+        Start: func(ctx context.Context) error {
+            go func() {
+                if err := app.Listen(":" + cfg.App.Port); err != nil {
+                    serverErr <- err
+                }
+            }()
+            return nil
+        },
+        Stop: func(ctx context.Context) error {
+            return app.ShutdownWithTimeout(cfg.App.ShutdownTimeout)
+        },
+    })
+
+    if err := lc.Start(ctx); err != nil {
+        log.Fatalf("Error starting application: %v", err)
+    }
+
+    // Wait for a shutdown signal or a fatal server error
+    select {
+    case <-ctx.Done():
+        log.Println("Shutdown signal received, draining in-flight requests...")
+    case err := <-serverErr:
+        log.Printf("HTTP server stopped unexpectedly: %v", err)
+    }
 
-    // Start server
-    port := os.Getenv("APP_PORT")
-    if port == "" {
-        port = "3000" // Default port if not specified
+    // Give every subsystem a bounded amount of time to stop
+    shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.App.ShutdownTimeout+5*time.Second)
+    defer cancel()
+    if err := lc.Stop(shutdownCtx); err != nil {
+        log.Printf("Error during shutdown: %v", err)
+        os.Exit(1)
     }
-    log.Fatal(app.Listen(":" + port))
-}
\ No newline at end of file
+    log.Println("Shutdown complete")
+}
@@ -0,0 +1,153 @@
+package marc
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// ISO 2709 structural characters.
+const (
+	subfieldDelimiter = 0x1F
+	fieldTerminator   = 0x1E
+	recordTerminator  = 0x1D
+)
+
+// ContentType is the media type of ISO 2709 MARC files.
+const ContentType = "application/marc"
+
+// ErrTooLong is returned when a field or record exceeds what the ISO 2709
+// directory can address (9999 bytes per field, 99999 per record).
+var ErrTooLong = errors.New("marc: record too long for ISO 2709")
+
+// Marshal encodes a record in ISO 2709.
+func Marshal(r *Record) ([]byte, error) {
+	var directory, data bytes.Buffer
+	for _, f := range r.Fields {
+		if len(f.Tag) != 3 {
+			return nil, fmt.Errorf("marc: invalid tag %q", f.Tag)
+		}
+		start := data.Len()
+		if f.IsControl() {
+			data.WriteString(f.Value)
+		} else {
+			data.WriteByte(indicator(f.Ind1))
+			data.WriteByte(indicator(f.Ind2))
+			for _, sf := range f.Subfields {
+				data.WriteByte(subfieldDelimiter)
+				data.WriteByte(sf.Code)
+				data.WriteString(sf.Value)
+			}
+		}
+		data.WriteByte(fieldTerminator)
+		length := data.Len() - start
+		if length > 9999 || start > 99999 {
+			return nil, ErrTooLong
+		}
+		fmt.Fprintf(&directory, "%s%04d%05d", f.Tag, length, start)
+	}
+	directory.WriteByte(fieldTerminator)
+	data.WriteByte(recordTerminator)
+
+	base := 24 + directory.Len()
+	total := base + data.Len()
+	if total > 99999 {
+		return nil, ErrTooLong
+	}
+
+	leader := []byte(r.leader())
+	copy(leader[0:5], fmt.Sprintf("%05d", total))
+	leader[9] = 'a' // UCS/Unicode character coding
+	copy(leader[12:17], fmt.Sprintf("%05d", base))
+
+	out := make([]byte, 0, total)
+	out = append(out, leader...)
+	out = append(out, directory.Bytes()...)
+	return append(out, data.Bytes()...), nil
+}
+
+// Reader decodes a stream of ISO 2709 records.
+type Reader struct {
+	r *bufio.Reader
+}
+
+// NewReader returns a Reader reading from r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+// Read returns the next record, or io.EOF when there are no more.
+func (d *Reader) Read() (*Record, error) {
+	// Some exports put line breaks between records
+	for {
+		b, err := d.r.Peek(1)
+		if err != nil {
+			return nil, err
+		}
+		if b[0] != '\n' && b[0] != '\r' && b[0] != ' ' {
+			break
+		}
+		d.r.ReadByte()
+	}
+
+	head, err := d.r.Peek(5)
+	if err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	total, err := strconv.Atoi(string(head))
+	if err != nil || total < 25 {
+		return nil, fmt.Errorf("marc: invalid record length %q", head)
+	}
+	raw := make([]byte, total)
+	if _, err := io.ReadFull(d.r, raw); err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return Unmarshal(raw)
+}
+
+// Unmarshal decodes a single ISO 2709 record.
+func Unmarshal(raw []byte) (*Record, error) {
+	if len(raw) < 25 {
+		return nil, errors.New("marc: record shorter than its leader")
+	}
+	base, err := strconv.Atoi(string(raw[12:17]))
+	if err != nil || base < 25 || base > len(raw) {
+		return nil, fmt.Errorf("marc: invalid base address %q", raw[12:17])
+	}
+
+	r := &Record{Leader: string(raw[:24])}
+	directory := raw[24 : base-1]
+	data := raw[base:]
+	if len(directory)%12 != 0 {
+		return nil, errors.New("marc: malformed directory")
+	}
+	for i := 0; i < len(directory); i += 12 {
+		entry := directory[i : i+12]
+		length, err1 := strconv.Atoi(string(entry[3:7]))
+		start, err2 := strconv.Atoi(string(entry[7:12]))
+		if err1 != nil || err2 != nil || start+length > len(data) || length < 1 {
+			return nil, fmt.Errorf("marc: malformed directory entry %q", entry)
+		}
+		f := Field{Tag: string(entry[:3])}
+		body := bytes.TrimSuffix(data[start:start+length], []byte{fieldTerminator})
+		if f.IsControl() {
+			f.Value = string(body)
+		} else {
+			if len(body) < 2 {
+				return nil, fmt.Errorf("marc: field %s has no indicators", f.Tag)
+			}
+			f.Ind1, f.Ind2 = body[0], body[1]
+			for _, part := range bytes.Split(body[2:], []byte{subfieldDelimiter}) {
+				if len(part) == 0 {
+					continue
+				}
+				f.Subfields = append(f.Subfields, Subfield{Code: part[0], Value: string(part[1:])})
+			}
+		}
+		r.Fields = append(r.Fields, f)
+	}
+	return r, nil
+}
@@ -0,0 +1,145 @@
+package marc
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// sampleRecord has a control field, repeated data fields and multi-byte
+// UTF-8 text, so lengths and offsets are counted in bytes
+func sampleRecord() *Record {
+	r := &Record{}
+	r.AddControl("001", "42")
+	r.AddData("020", ' ', ' ', Subfield{'a', "9789792248616"})
+	r.AddData("100", '1', ' ', Subfield{'a', "Hirata, Andrea"})
+	r.AddData("245", '1', '0', Subfield{'a', "Laskar Pelangi"}, Subfield{'c', "Andréa Hirata"})
+	r.AddData("650", ' ', '4', Subfield{'a', "Fiksi"})
+	r.AddData("650", ' ', '4', Subfield{'a', "Pendidikan"})
+	return r
+}
+
+func TestMarshalLayout(t *testing.T) {
+	raw, err := Marshal(sampleRecord())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, _ := strconv.Atoi(string(raw[0:5])); got != len(raw) {
+		t.Errorf("leader record length = %d, want %d", got, len(raw))
+	}
+	if raw[9] != 'a' {
+		t.Errorf("leader/09 = %q, want 'a' (UCS)", raw[9])
+	}
+	if raw[len(raw)-1] != recordTerminator {
+		t.Error("record does not end with the record terminator")
+	}
+
+	// The directory has one 12-byte entry per field and ends right before
+	// the base address
+	base, _ := strconv.Atoi(string(raw[12:17]))
+	fields := len(sampleRecord().Fields)
+	if want := 24 + 12*fields + 1; base != want {
+		t.Fatalf("base address = %d, want %d", base, want)
+	}
+	if raw[base-1] != fieldTerminator {
+		t.Error("directory does not end with a field terminator")
+	}
+
+	next := 0
+	for i := 0; i < fields; i++ {
+		entry := string(raw[24+12*i : 36+12*i])
+		length, _ := strconv.Atoi(entry[3:7])
+		start, _ := strconv.Atoi(entry[7:12])
+		if start != next {
+			t.Errorf("field %s starts at %d, want %d", entry[:3], start, next)
+		}
+		if raw[base+start+length-1] != fieldTerminator {
+			t.Errorf("field %s does not end with a field terminator at its directory length", entry[:3])
+		}
+		next = start + length
+	}
+	if base+next+1 != len(raw) {
+		t.Errorf("fields end at %d, record terminator expected at %d", base+next, len(raw)-1)
+	}
+}
+
+func TestMarshalUnmarshalRoundTrip(t *testing.T) {
+	in := sampleRecord()
+	raw, err := Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := Unmarshal(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out.Fields, in.Fields) {
+		t.Errorf("fields after round trip:\n got %+v\nwant %+v", out.Fields, in.Fields)
+	}
+	if out.Leader != string(raw[:24]) {
+		t.Errorf("leader = %q, want %q", out.Leader, raw[:24])
+	}
+
+	// A second pass is byte for byte identical
+	again, err := Marshal(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(again, raw) {
+		t.Error("re-marshalled record differs")
+	}
+}
+
+func TestReaderReadsSeparatedRecords(t *testing.T) {
+	first, _ := Marshal(sampleRecord())
+	second := &Record{}
+	second.AddControl("001", "43")
+	second.AddData("245", '0', '0', Subfield{'a', "Bumi Manusia"})
+	secondRaw, _ := Marshal(second)
+
+	// Some exports put a line break between records
+	stream := append(append(append([]byte{}, first...), "\r\n"...), secondRaw...)
+	d := NewReader(bytes.NewReader(stream))
+
+	var titles []string
+	for {
+		r, err := d.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, _ := r.Field("245")
+		titles = append(titles, f.Subfield('a'))
+	}
+	if want := []string{"Laskar Pelangi", "Bumi Manusia"}; !reflect.DeepEqual(titles, want) {
+		t.Errorf("titles = %v, want %v", titles, want)
+	}
+}
+
+func TestMarshalRejectsOversizedField(t *testing.T) {
+	r := &Record{}
+	r.AddData("520", ' ', ' ', Subfield{'a', strings.Repeat("x", 10000)})
+	if _, err := Marshal(r); !errors.Is(err, ErrTooLong) {
+		t.Errorf("Marshal error = %v, want ErrTooLong", err)
+	}
+}
+
+func TestUnmarshalRejectsMalformedRecords(t *testing.T) {
+	raw, _ := Marshal(sampleRecord())
+	for name, broken := range map[string][]byte{
+		"short":             raw[:20],
+		"base address":      append(append(append([]byte{}, raw[:12]...), "abcde"...), raw[17:]...),
+		"directory overrun": append(append(append([]byte{}, raw[:27]...), "9999"...), raw[31:]...),
+	} {
+		if _, err := Unmarshal(broken); err == nil {
+			t.Errorf("%s: Unmarshal accepted a malformed record", name)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+// Package marc reads and writes MARC 21 bibliographic records in the two
+// interchange formats libraries use: ISO 2709 binary ("MARC21", usually
+// .mrc) and MARCXML. It only models records; mapping them to catalog data
+// is left to the caller.
+package marc
+
+import (
+	"strings"
+)
+
+// Record is a single MARC record.
+type Record struct {
+	Leader string // 24 characters; a default leader is used when empty
+	Fields []Field
+}
+
+// Field is a control field (tags 001-009, Value set) or a data field
+// (indicators and subfields set).
+type Field struct {
+	Tag       string
+	Value     string
+	Ind1      byte
+	Ind2      byte
+	Subfields []Subfield
+}
+
+// Subfield is one coded value of a data field.
+type Subfield struct {
+	Code  byte
+	Value string
+}
+
+// defaultLeader describes a new, UTF-8 encoded language material monograph.
+// Record length and base address (positions 0-4 and 12-16) are filled in
+// when the record is written.
+const defaultLeader = "00000nam a2200000 i 4500"
+
+// IsControl reports whether the field is a control field (tag 00X).
+func (f Field) IsControl() bool {
+	return strings.HasPrefix(f.Tag, "00")
+}
+
+// Subfield returns the first value of the subfield with the given code.
+func (f Field) Subfield(code byte) string {
+	for _, sf := range f.Subfields {
+		if sf.Code == code {
+			return sf.Value
+		}
+	}
+	return ""
+}
+
+// AddControl appends a control field.
+func (r *Record) AddControl(tag, value string) {
+	r.Fields = append(r.Fields, Field{Tag: tag, Value: value})
+}
+
+// AddData appends a data field, skipping subfields with empty values. The
+// field is omitted altogether when no subfield has a value.
+func (r *Record) AddData(tag string, ind1, ind2 byte, subfields ...Subfield) {
+	kept := make([]Subfield, 0, len(subfields))
+	for _, sf := range subfields {
+		if sf.Value != "" {
+			kept = append(kept, sf)
+		}
+	}
+	if len(kept) == 0 {
+		return
+	}
+	r.Fields = append(r.Fields, Field{Tag: tag, Ind1: ind1, Ind2: ind2, Subfields: kept})
+}
+
+// Field returns the first field with the given tag.
+func (r *Record) Field(tag string) (Field, bool) {
+	for _, f := range r.Fields {
+		if f.Tag == tag {
+			return f, true
+		}
+	}
+	return Field{}, false
+}
+
+func (r *Record) leader() string {
+	if len(r.Leader) == 24 {
+		return r.Leader
+	}
+	return defaultLeader
+}
+
+// indicator renders a blank indicator as a space.
+func indicator(b byte) byte {
+	if b == 0 {
+		return ' '
+	}
+	return b
+}
@@ -0,0 +1,138 @@
+package marc
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// XMLContentType is the media type of MARCXML documents.
+const XMLContentType = "application/marcxml+xml"
+
+// Namespace is the MARCXML (MARC 21 slim) namespace.
+const Namespace = "http://www.loc.gov/MARC21/slim"
+
+type xmlRecord struct {
+	XMLName       xml.Name          `xml:"record"`
+	Leader        string            `xml:"leader"`
+	ControlFields []xmlControlField `xml:"controlfield"`
+	DataFields    []xmlDataField    `xml:"datafield"`
+}
+
+type xmlControlField struct {
+	Tag   string `xml:"tag,attr"`
+	Value string `xml:",chardata"`
+}
+
+type xmlDataField struct {
+	Tag       string        `xml:"tag,attr"`
+	Ind1      string        `xml:"ind1,attr"`
+	Ind2      string        `xml:"ind2,attr"`
+	Subfields []xmlSubfield `xml:"subfield"`
+}
+
+type xmlSubfield struct {
+	Code  string `xml:"code,attr"`
+	Value string `xml:",chardata"`
+}
+
+// XMLWriter streams records into a MARCXML <collection>.
+type XMLWriter struct {
+	w       io.Writer
+	enc     *xml.Encoder
+	started bool
+}
+
+// NewXMLWriter returns an XMLWriter writing to w. Call Close to end the collection.
+func NewXMLWriter(w io.Writer) *XMLWriter {
+	return &XMLWriter{w: w, enc: xml.NewEncoder(w)}
+}
+
+// Write appends a record to the collection.
+func (x *XMLWriter) Write(r *Record) error {
+	if !x.started {
+		x.started = true
+		if _, err := fmt.Fprintf(x.w, "%s<collection xmlns=%q>\n", xml.Header, Namespace); err != nil {
+			return err
+		}
+	}
+
+	out := xmlRecord{Leader: r.leader()}
+	// MARCXML lists control fields before data fields; tag order is kept otherwise
+	for _, f := range r.Fields {
+		if f.IsControl() {
+			out.ControlFields = append(out.ControlFields, xmlControlField{Tag: f.Tag, Value: f.Value})
+			continue
+		}
+		df := xmlDataField{Tag: f.Tag, Ind1: string(indicator(f.Ind1)), Ind2: string(indicator(f.Ind2))}
+		for _, sf := range f.Subfields {
+			df.Subfields = append(df.Subfields, xmlSubfield{Code: string(sf.Code), Value: sf.Value})
+		}
+		out.DataFields = append(out.DataFields, df)
+	}
+	if err := x.enc.Encode(out); err != nil {
+		return err
+	}
+	_, err := io.WriteString(x.w, "\n")
+	return err
+}
+
+// Close ends the collection. It does not close the underlying writer.
+func (x *XMLWriter) Close() error {
+	if !x.started {
+		if _, err := fmt.Fprintf(x.w, "%s<collection xmlns=%q>\n", xml.Header, Namespace); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(x.w, "</collection>\n")
+	return err
+}
+
+// XMLReader decodes the <record> elements of a MARCXML document, whether the
+// root is a <collection> or a single <record>.
+type XMLReader struct {
+	dec *xml.Decoder
+}
+
+// NewXMLReader returns an XMLReader reading from r.
+func NewXMLReader(r io.Reader) *XMLReader {
+	return &XMLReader{dec: xml.NewDecoder(r)}
+}
+
+// Read returns the next record, or io.EOF when there are no more.
+func (x *XMLReader) Read() (*Record, error) {
+	for {
+		tok, err := x.dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "record" {
+			continue
+		}
+
+		var in xmlRecord
+		if err := x.dec.DecodeElement(&in, &start); err != nil {
+			return nil, err
+		}
+		r := &Record{Leader: in.Leader}
+		for _, cf := range in.ControlFields {
+			r.AddControl(cf.Tag, cf.Value)
+		}
+		for _, df := range in.DataFields {
+			f := Field{Tag: df.Tag, Ind1: firstByte(df.Ind1), Ind2: firstByte(df.Ind2)}
+			for _, sf := range df.Subfields {
+				f.Subfields = append(f.Subfields, Subfield{Code: firstByte(sf.Code), Value: sf.Value})
+			}
+			r.Fields = append(r.Fields, f)
+		}
+		return r, nil
+	}
+}
+
+func firstByte(s string) byte {
+	if s == "" {
+		return ' '
+	}
+	return s[0]
+}
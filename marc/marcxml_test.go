@@ -0,0 +1,82 @@
+package marc
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestXMLRoundTrip(t *testing.T) {
+	in := sampleRecord()
+	in.Leader = "00000nam a2200000 i 4500"
+
+	var buf bytes.Buffer
+	w := NewXMLWriter(&buf)
+	if err := w.Write(in); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `xmlns="`+Namespace+`"`) {
+		t.Error("collection does not declare the MARCXML namespace")
+	}
+
+	r := NewXMLReader(&buf)
+	out, err := r.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Leader != in.Leader {
+		t.Errorf("leader = %q, want %q", out.Leader, in.Leader)
+	}
+	if !reflect.DeepEqual(out.Fields, in.Fields) {
+		t.Errorf("fields after round trip:\n got %+v\nwant %+v", out.Fields, in.Fields)
+	}
+	if _, err := r.Read(); !errors.Is(err, io.EOF) {
+		t.Errorf("second Read error = %v, want io.EOF", err)
+	}
+}
+
+func TestXMLToISO2709(t *testing.T) {
+	// A record read from MARCXML writes a valid ISO 2709 record
+	var buf bytes.Buffer
+	w := NewXMLWriter(&buf)
+	w.Write(sampleRecord())
+	w.Close()
+
+	rec, err := NewXMLReader(&buf).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := Marshal(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	back, err := Unmarshal(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(back.Fields, sampleRecord().Fields) {
+		t.Errorf("fields = %+v, want %+v", back.Fields, sampleRecord().Fields)
+	}
+}
+
+func TestXMLReaderSingleRecordRoot(t *testing.T) {
+	doc := `<record xmlns="` + Namespace + `"><leader>00000nam a2200000 i 4500</leader>` +
+		`<controlfield tag="001">7</controlfield>` +
+		`<datafield tag="245" ind1="1" ind2="0"><subfield code="a">Ronggeng Dukuh Paruk</subfield></datafield></record>`
+	rec, err := NewXMLReader(strings.NewReader(doc)).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f, _ := rec.Field("245"); f.Subfield('a') != "Ronggeng Dukuh Paruk" || f.Ind1 != '1' || f.Ind2 != '0' {
+		t.Errorf("245 = %+v", f)
+	}
+	if f, _ := rec.Field("001"); f.Value != "7" {
+		t.Errorf("001 = %q, want 7", f.Value)
+	}
+}
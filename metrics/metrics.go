@@ -0,0 +1,164 @@
+package metrics
+
+import (
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metrics.go
+// This file defines the Prometheus metrics exposed on /metrics: HTTP
+// traffic, the database connection pool and domain events.
+
+const namespace = "pojok_baca"
+
+// Registry holds every metric of the application. A dedicated registry keeps
+// the output limited to what we register plus the Go/process collectors.
+var Registry = prometheus.NewRegistry()
+
+var (
+	// HTTPRequests counts handled requests by route template, method and status.
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Total number of HTTP requests.",
+	}, []string{"method", "route", "status"})
+
+	// HTTPDuration observes request latency by route template and method.
+	HTTPDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency in seconds.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	// MailSent counts email deliveries by purpose and result ("success" or "failure").
+	MailSent = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "mail_sent_total",
+		Help:      "Total number of emails sent, by purpose and result.",
+	}, []string{"purpose", "result"})
+
+	// CacheHits counts catalog cache hits by resource ("books", "categories").
+	CacheHits = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_hits_total",
+		Help:      "Total number of cache hits, by resource.",
+	}, []string{"resource"})
+
+	// CacheMisses counts catalog cache misses by resource.
+	CacheMisses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_misses_total",
+		Help:      "Total number of cache misses, by resource.",
+	}, []string{"resource"})
+
+	// Logins counts successful logins.
+	Logins = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "logins_total",
+		Help:      "Total number of successful logins.",
+	})
+
+	// LoginFailures counts rejected login attempts.
+	LoginFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "login_failures_total",
+		Help:      "Total number of failed login attempts.",
+	})
+
+	// Registrations counts newly registered users.
+	Registrations = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "registrations_total",
+		Help:      "Total number of user registrations.",
+	})
+
+	// BooksCreated counts books added to the catalog.
+	BooksCreated = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "books_created_total",
+		Help:      "Total number of books created.",
+	})
+
+	// EnrichmentLookups counts metadata lookups by provider and result
+	// ("found", "not_found" or "error").
+	EnrichmentLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "enrichment_lookups_total",
+		Help:      "Total number of book metadata lookups, by provider and result.",
+	}, []string{"provider", "result"})
+
+	// RecommendationRuns counts runs of the similar books job by result
+	// ("success", "error" or "skipped" while another instance runs it).
+	RecommendationRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "recommendation_runs_total",
+		Help:      "Total number of similar book computations, by result.",
+	}, []string{"result"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests,
+		HTTPDuration,
+		MailSent,
+		CacheHits,
+		CacheMisses,
+		Logins,
+		LoginFailures,
+		Registrations,
+		BooksCreated,
+		EnrichmentLookups,
+		RecommendationRuns,
+	)
+}
+
+// RegisterDB exposes the connection pool statistics (db.Stats()) of a database handle.
+func RegisterDB(db *sql.DB, name string) {
+	Registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// Middleware records request count and latency for every request. Routes are
+// labelled by their template (e.g. /api/v1/books/:id) to keep cardinality low.
+func Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		err := c.Next()
+
+		// Let the app's error handler decide the final status of a returned error.
+		status := c.Response().StatusCode()
+		if err != nil {
+			if fe, ok := err.(*fiber.Error); ok {
+				status = fe.Code
+			} else {
+				status = fiber.StatusInternalServerError
+			}
+		}
+
+		// Requests that matched no route only passed through app.Use middleware,
+		// whose route path is "/". Group them so random paths don't create series.
+		route := "unmatched"
+		if r := c.Route(); r != nil && r.Path != "/" {
+			route = r.Path
+		}
+
+		method := c.Method()
+		HTTPRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+		HTTPDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+		return err
+	}
+}
+
+// Handler serves the registry in the Prometheus text exposition format.
+func Handler() fiber.Handler {
+	return adaptor.HTTPHandler(promhttp.HandlerFor(Registry, promhttp.HandlerOpts{}))
+}
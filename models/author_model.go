@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Author represents the 'authors' table in the database
+type Author struct {
+	AuthorID    int       `json:"author_id" db:"author_id"`
+	NamaPenulis string    `json:"nama_penulis" db:"nama_penulis" validate:"required,max=255"`
+	Bio         string    `json:"bio" db:"bio" validate:"max=65535"`
+	FotoURL     string    `json:"foto_url" db:"foto_url" validate:"max=255"` // Photo path/URL
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`                // Set by the database
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`                // Set by the database on every change
+}
+
+// BookAuthor links a book to an author in a given role ('book_authors' table)
+type BookAuthor struct {
+	AuthorID    int    `json:"author_id" validate:"required,gt=0"`
+	NamaPenulis string `json:"nama_penulis,omitempty"`                                    // Filled in on reads
+	Peran       string `json:"peran" validate:"omitempty,oneof=author translator editor"` // Defaults to author
+}
+
+// AuthorBook is a book listed on an author's page, with the author's role in it
+type AuthorBook struct {
+	Book
+	Peran string `json:"peran"`
+}
@@ -1,13 +1,24 @@
 package models
 
+import "time"
+
 // Book represents the 'books' table in the database
 type Book struct {
 	BookID      int    `json:"book_id" db:"book_id"` // Corresponds to book_id in DB
-	Judul       string `json:"judul" db:"judul"`
-	Penulis     string `json:"penulis" db:"penulis"`
-	Penerbit    string `json:"penerbit" db:"penerbit"`
-	TahunTerbit int    `json:"tahun_terbit" db:"tahun_terbit"`
-	Sinopsis    string `json:"sinopsis" db:"sinopsis"`
-	ImageURL    string `json:"image_url" db:"image_url"` // New field for image path/URL
-	CategoryID  int    `json:"category_id" db:"category_id"` // Foreign key to categories
-}
\ No newline at end of file
+	Judul       string `json:"judul" db:"judul" validate:"required,max=255"`
+	ISBN        *string `json:"isbn" db:"isbn" validate:"omitempty,isbn"` // ISBN-13 without hyphens; nil when unknown
+	Penulis     string `json:"penulis" db:"penulis" validate:"required,max=255"`
+	Penerbit    string `json:"penerbit" db:"penerbit" validate:"required,max=255"` // Display text; follows the linked publisher's name
+	PublisherID *int   `json:"publisher_id" db:"publisher_id" validate:"omitempty,gt=0"` // Foreign key to publishers; linked by penerbit when omitted
+	TahunTerbit int    `json:"tahun_terbit" db:"tahun_terbit" validate:"required,min=1000,notfutureyear"`
+	Sinopsis    string `json:"sinopsis" db:"sinopsis" validate:"max=65535"`
+	ImageURL    string `json:"image_url" db:"image_url" validate:"max=255"` // New field for image path/URL
+	CategoryID  int    `json:"category_id" db:"category_id" validate:"required,gt=0"` // Foreign key to categories; the primary category
+	CategoryIDs []int  `json:"category_ids" db:"-" validate:"dive,gt=0"` // Every category of the book, primary first
+	Tags        []string `json:"tags" db:"-" validate:"dive,required,max=50"` // Free-form tags, lower case
+	Authors     []BookAuthor `json:"authors" db:"-" validate:"dive"` // Linked authors in order; penulis stays the display text
+	AverageRating *float64 `json:"average_rating" db:"-"` // Mean of the visible reviews' ratings; nil without reviews
+	ReviewCount   int      `json:"review_count" db:"-"`   // Number of visible reviews
+	CreatedAt   time.Time `json:"created_at" db:"created_at"` // Set by the database
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"` // Set by the database on every change
+}
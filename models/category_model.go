@@ -1,8 +1,13 @@
 package models
 
+import "time"
+
 // Category represents the 'categories' table in the database
 type Category struct {
 	CategoryID  int    `json:"category_id" db:"category_id"` // Corresponds to category_id in DB
-	NamaKategori string `json:"nama_kategori" db:"nama_kategori"`
-	ImageURL    string `json:"image_url" db:"image_url"` // New field for image path/URL
-}
\ No newline at end of file
+	ParentID    *int   `json:"parent_id" db:"parent_id" validate:"omitempty,gt=0"` // Parent category; nil for a top-level category
+	NamaKategori string `json:"nama_kategori" db:"nama_kategori" validate:"required,max=100"`
+	ImageURL    string `json:"image_url" db:"image_url" validate:"max=255"` // New field for image path/URL
+	CreatedAt   time.Time `json:"created_at" db:"created_at"` // Set by the database
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"` // Set by the database on every change
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Publisher represents the 'publishers' table in the database
+type Publisher struct {
+	PublisherID  int       `json:"publisher_id" db:"publisher_id"`
+	NamaPenerbit string    `json:"nama_penerbit" db:"nama_penerbit" validate:"required,max=255"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"` // Set by the database
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"` // Set by the database on every change
+}
+
+// PublisherMerge is the body of a publisher merge: every book of the
+// sources moves to the target and the sources are deleted
+type PublisherMerge struct {
+	TargetID  int   `json:"target_id" validate:"required,gt=0"`
+	SourceIDs []int `json:"source_ids" validate:"required,min=1,dive,gt=0"`
+}
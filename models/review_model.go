@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Review represents the 'book_reviews' table in the database: a member's
+// star rating of a book with an optional written review
+type Review struct {
+	ReviewID    int       `json:"review_id" db:"review_id"`
+	BookID      int       `json:"book_id" db:"book_id"`
+	UserID      int       `json:"user_id" db:"user_id"`
+	NamaLengkap string    `json:"nama_lengkap" db:"-"` // The reviewer's name, from users
+	Rating      int       `json:"rating" db:"rating" validate:"required,min=1,max=5"`
+	Ulasan      string    `json:"ulasan" db:"ulasan" validate:"max=5000"` // Empty for a rating without text
+	Hidden      bool      `json:"hidden" db:"hidden"`                     // Set by librarians; hidden reviews are not listed or counted
+	Flagged     bool      `json:"flagged" db:"flagged"`                   // Set by librarians to mark a review for a closer look
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`             // Set by the database
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`             // Set by the database on every change
+}
+
+// ReviewModeration is the body of a moderation request; omitted fields are
+// left as they are
+type ReviewModeration struct {
+	Hidden  *bool `json:"hidden"`
+	Flagged *bool `json:"flagged"`
+}
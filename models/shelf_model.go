@@ -0,0 +1,60 @@
+package models
+
+import "time"
+
+// Shelf kinds. Every user has one shelf of each built-in kind, and a book
+// is on at most one of them; custom shelves are named lists of any books.
+const (
+	ShelfWantToRead = "want_to_read"
+	ShelfReading    = "reading"
+	ShelfFinished   = "finished"
+	ShelfCustom     = "custom"
+)
+
+// Shelf represents the 'shelves' table in the database
+type Shelf struct {
+	ShelfID   int       `json:"shelf_id" db:"shelf_id"`
+	UserID    int       `json:"user_id" db:"user_id"`
+	Jenis     string    `json:"jenis" db:"jenis"` // One of the shelf kinds; custom for lists created by the user
+	NamaRak   string    `json:"nama_rak" db:"nama_rak" validate:"required,max=100"`
+	Public    bool      `json:"public" db:"is_public"`
+	Slug      *string   `json:"slug" db:"slug"` // Set while public; the shelf is shared at /api/v1/shelves/:slug
+	BookCount int       `json:"book_count" db:"-"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"` // Set by the database
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"` // Set by the database on every change
+}
+
+// ShelfBook is a book on a shelf with the owner's reading progress
+type ShelfBook struct {
+	Book
+	Progress   int       `json:"progress"`    // Percentage read, 0-100
+	StartedOn  *string   `json:"started_on"`  // YYYY-MM-DD; nil when not started
+	FinishedOn *string   `json:"finished_on"` // YYYY-MM-DD; nil when not finished
+	AddedAt    time.Time `json:"added_at"`
+}
+
+// ShelfWithBooks is a shelf with the books on it, most recently added first
+type ShelfWithBooks struct {
+	Shelf
+	Books []ShelfBook `json:"books"`
+}
+
+// SharedShelf is a public shelf as seen through its slug; the owner's
+// reading progress is not shared
+type SharedShelf struct {
+	NamaRak   string    `json:"nama_rak"`
+	Jenis     string    `json:"jenis"`
+	Slug      string    `json:"slug"`
+	Pemilik   string    `json:"pemilik"` // The owner's name
+	UpdatedAt time.Time `json:"updated_at"`
+	Books     []Book    `json:"books"`
+}
+
+// ShelfEntry is the body of adding a book to a shelf, which also updates
+// the reading progress; omitted fields are left as they are and an empty
+// date clears it
+type ShelfEntry struct {
+	Progress   *int    `json:"progress" validate:"omitempty,min=0,max=100"`
+	StartedOn  *string `json:"started_on" validate:"omitempty,datetime=2006-01-02"`
+	FinishedOn *string `json:"finished_on" validate:"omitempty,datetime=2006-01-02"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Tag represents the 'tags' table in the database
+type Tag struct {
+	TagID     int       `json:"tag_id" db:"tag_id"`
+	NamaTag   string    `json:"nama_tag" db:"nama_tag" validate:"required,max=50"` // Stored in lower case
+	CreatedAt time.Time `json:"created_at" db:"created_at"`                        // Set by the database
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`                        // Set by the database on every change
+}
+
+// TagCount is a tag in the tag cloud, with the number of books carrying it
+type TagCount struct {
+	Tag
+	BookCount int `json:"book_count"`
+}
+
+// TagMerge is the body of a tag merge: every book of the sources gets the
+// target tag and the sources are deleted
+type TagMerge struct {
+	TargetID  int   `json:"target_id" validate:"required,gt=0"`
+	SourceIDs []int `json:"source_ids" validate:"required,min=1,dive,gt=0"`
+}
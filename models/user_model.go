@@ -4,16 +4,24 @@ import "time"
 
 type User struct {
     UserID     int       `json:"user_id" db:"user_id"`
-    NamaLengkap string    `json:"nama_lengkap" db:"nama_lengkap"`
-    NIM        string    `json:"nim" db:"nim"`
-    Email      string    `json:"email" db:"email"`
-    Password   string    `json:"password" db:"password"`
+    NamaLengkap string    `json:"nama_lengkap" db:"nama_lengkap" validate:"required,max=100"`
+    NIM        string    `json:"nim" db:"nim" validate:"required,nim"`
+    Email      string    `json:"email" db:"email" validate:"required,email,max=100"`
+    Password   string    `json:"password" db:"password" validate:"required,strongpassword,max=72"`
+    Role       string    `json:"role" db:"role"` // RoleMember or RoleLibrarian; never taken from the request body
     CreatedAt  time.Time `json:"created_at" db:"created_at"`
     UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
 }
 
+// User roles. Everyone who registers is a member; librarians are appointed
+// in the database and moderate reviews.
+const (
+    RoleMember    = "member"
+    RoleLibrarian = "librarian"
+)
+
 // UserLogin struct for handling login requests
 type UserLogin struct {
-    Email    string `json:"email" validate:"required"` // UBAH DARI NIM KE EMAIL
+    Email    string `json:"email" validate:"required,email"` // UBAH DARI NIM KE EMAIL
     Password string `json:"password" validate:"required"`
-}
\ No newline at end of file
+}
@@ -0,0 +1,236 @@
+// Package recommend precomputes "you might also like" suggestions.
+//
+// A background job periodically scores how similar every pair of books is,
+// from the users who viewed both and from their categories, authors and the
+// terms of their title and synopsis, and stores the most similar books of
+// each in book_similarities. The API reads that table for similar books and
+// for personal recommendations.
+package recommend
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"pojok_baca_api/cache"
+	"pojok_baca_api/config"
+	"pojok_baca_api/database"
+	"pojok_baca_api/metrics"
+)
+
+// refreshTimeout bounds one computation, including writing the results
+const refreshTimeout = 5 * time.Minute
+
+// insertBatch is how many similarities are written per INSERT
+const insertBatch = 500
+
+// lockName is the MariaDB named lock held while similarities are
+// recomputed, so only one API instance runs the job at a time
+const lockName = "recommendations"
+
+// ErrLocked is returned by Refresh when another instance is already
+// recomputing the similarities
+var ErrLocked = errors.New("recommend: another refresh is running")
+
+// Run recomputes the similarities on the primary database right away and
+// then every cfg.Interval until ctx is cancelled
+func Run(ctx context.Context, cfg config.RecommendationsConfig) {
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+	for {
+		refresh(ctx, database.DB, cfg.MaxSimilar)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refresh runs one computation and logs its outcome
+func refresh(ctx context.Context, db *sql.DB, maxSimilar int) {
+	ctx, cancel := context.WithTimeout(ctx, refreshTimeout)
+	defer cancel()
+
+	start := time.Now()
+	books, pairs, err := Refresh(ctx, db, maxSimilar)
+	if err != nil {
+		// Cancelled by shutdown: not a failure
+		if errors.Is(ctx.Err(), context.Canceled) {
+			return
+		}
+		if errors.Is(err, ErrLocked) {
+			metrics.RecommendationRuns.WithLabelValues("skipped").Inc()
+			log.Println("Rekomendasi buku sedang dihitung oleh instans lain; dilewati")
+			return
+		}
+		metrics.RecommendationRuns.WithLabelValues("error").Inc()
+		log.Printf("Gagal menghitung rekomendasi buku: %v", err)
+		return
+	}
+	metrics.RecommendationRuns.WithLabelValues("success").Inc()
+	log.Printf("Rekomendasi buku diperbarui: %d buku, %d pasangan (%s)", books, pairs, time.Since(start).Round(time.Millisecond))
+}
+
+// Refresh recomputes the similar books of every book and replaces the
+// stored ones. It returns the number of books and of stored pairs, or
+// ErrLocked without doing anything while another instance is refreshing.
+func Refresh(ctx context.Context, db *sql.DB, maxSimilar int) (int, int, error) {
+	// Named locks belong to a session, so they are taken and released on
+	// one dedicated connection
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer conn.Close()
+
+	var acquired sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 0)", lockName).Scan(&acquired); err != nil {
+		return 0, 0, err
+	}
+	if acquired.Int64 != 1 {
+		return 0, 0, ErrLocked
+	}
+	defer func() {
+		// Released even when the run was cancelled
+		var released sql.NullInt64
+		if err := conn.QueryRowContext(context.WithoutCancel(ctx), "SELECT RELEASE_LOCK(?)", lockName).Scan(&released); err != nil {
+			log.Printf("Gagal melepas kunci rekomendasi: %v", err)
+		}
+	}()
+
+	items, err := loadItems(ctx, db)
+	if err != nil {
+		return 0, 0, err
+	}
+	views, err := loadViews(ctx, db)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	similar := Compute(items, views, maxSimilar)
+	pairs, err := store(ctx, db, similar)
+	if err != nil {
+		return 0, 0, err
+	}
+	cache.Default.Invalidate(ctx, cache.AllSimilarBooks, cache.PopularBooksKey)
+	return len(items), pairs, nil
+}
+
+// loadItems loads the categories, authors, title and synopsis of every book
+func loadItems(ctx context.Context, db *sql.DB) ([]Item, error) {
+	rows, err := db.QueryContext(ctx, "SELECT book_id, judul, COALESCE(sinopsis, '') FROM books ORDER BY book_id")
+	if err != nil {
+		return nil, err
+	}
+	var items []Item
+	index := map[int]int{}
+	for rows.Next() {
+		var item Item
+		var judul, sinopsis string
+		if err := rows.Scan(&item.BookID, &judul, &sinopsis); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		item.Text = judul + " " + sinopsis
+		index[item.BookID] = len(items)
+		items = append(items, item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, link := range []struct {
+		query string
+		add   func(item *Item, id int)
+	}{
+		{"SELECT book_id, category_id FROM book_categories", func(item *Item, id int) { item.Categories = append(item.Categories, id) }},
+		{"SELECT book_id, author_id FROM book_authors", func(item *Item, id int) { item.Authors = append(item.Authors, id) }},
+	} {
+		rows, err := db.QueryContext(ctx, link.query)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var bookID, id int
+			if err := rows.Scan(&bookID, &id); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			if i, ok := index[bookID]; ok {
+				link.add(&items[i], id)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+// loadViews loads the books each user viewed, most recent first
+func loadViews(ctx context.Context, db *sql.DB) (map[int][]int, error) {
+	rows, err := db.QueryContext(ctx, "SELECT user_id, book_id FROM user_book_views ORDER BY user_id, last_viewed_at DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	views := map[int][]int{}
+	for rows.Next() {
+		var userID, bookID int
+		if err := rows.Scan(&userID, &bookID); err != nil {
+			return nil, err
+		}
+		views[userID] = append(views[userID], bookID)
+	}
+	return views, rows.Err()
+}
+
+// store replaces every stored similarity in one transaction, so readers
+// see either the old or the new set. A book deleted while the job ran
+// fails the run; the next one catches up.
+func store(ctx context.Context, db *sql.DB, similar map[int][]Similar) (int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM book_similarities"); err != nil {
+		return 0, err
+	}
+
+	var rows []string
+	var args []interface{}
+	pairs := 0
+	flush := func() error {
+		if len(rows) == 0 {
+			return nil
+		}
+		_, err := tx.ExecContext(ctx, "INSERT INTO book_similarities (book_id, similar_book_id, score) VALUES "+strings.Join(rows, ", "), args...)
+		rows, args = rows[:0], args[:0]
+		return err
+	}
+	for bookID, list := range similar {
+		for _, s := range list {
+			rows = append(rows, "(?, ?, ?)")
+			args = append(args, bookID, s.BookID, s.Score)
+			pairs++
+			if len(rows) == insertBatch {
+				if err := flush(); err != nil {
+					return 0, err
+				}
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return 0, err
+	}
+	return pairs, tx.Commit()
+}
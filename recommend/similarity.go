@@ -0,0 +1,252 @@
+package recommend
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Weights of the similarity signals. Content similarity combines the
+// categories, authors and the terms of the title and synopsis; the final
+// score mixes it with how often the books are viewed by the same users.
+const (
+	categoryWeight = 0.4
+	authorWeight   = 0.3
+	textWeight     = 0.3
+
+	coViewWeight  = 0.5
+	contentWeight = 0.5
+
+	// minScore drops pairs too weakly related to be worth suggesting
+	minScore = 0.01
+	// maxTerms is how many of its highest weighted terms describe a book
+	maxTerms = 25
+	// maxViewsPerUser bounds the pairs counted for one user to their most
+	// recently viewed books
+	maxViewsPerUser = 100
+)
+
+// stopWords are common Indonesian and English words that say nothing about
+// what a book is about
+var stopWords = map[string]bool{
+	"dan": true, "yang": true, "untuk": true, "dengan": true, "dari": true, "dalam": true,
+	"ini": true, "itu": true, "pada": true, "adalah": true, "akan": true, "oleh": true,
+	"sebagai": true, "juga": true, "tidak": true, "atau": true, "bagi": true, "para": true,
+	"buku": true, "karena": true, "telah": true, "serta": true, "lebih": true, "dapat": true,
+	"the": true, "and": true, "for": true, "with": true, "from": true, "this": true,
+	"that": true, "are": true, "was": true, "his": true, "her": true, "their": true,
+	"into": true, "who": true, "book": true, "has": true, "have": true, "its": true,
+}
+
+// Item is what the job knows about a book
+type Item struct {
+	BookID     int
+	Categories []int
+	Authors    []int
+	Text       string // Title and synopsis
+}
+
+// Similar is a book similar to another, with a score between 0 and 1
+type Similar struct {
+	BookID int
+	Score  float64
+}
+
+// terms splits text into lower-case words of at least three letters,
+// leaving out stop words
+func terms(text string) []string {
+	var result []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len([]rune(word)) >= 3 && !stopWords[word] {
+			result = append(result, word)
+		}
+	}
+	return result
+}
+
+// termVectors weighs the terms of every item by TF-IDF and keeps the
+// maxTerms heaviest, normalized to unit length so a dot product is the
+// cosine similarity
+func termVectors(items []Item) []map[string]float64 {
+	counts := make([]map[string]int, len(items))
+	df := map[string]int{}
+	for i, item := range items {
+		counts[i] = map[string]int{}
+		for _, term := range terms(item.Text) {
+			if counts[i][term] == 0 {
+				df[term]++
+			}
+			counts[i][term]++
+		}
+	}
+
+	vectors := make([]map[string]float64, len(items))
+	for i, tf := range counts {
+		type weighted struct {
+			term   string
+			weight float64
+		}
+		var ws []weighted
+		for term, n := range tf {
+			// Terms every book has do not tell books apart
+			idf := math.Log(float64(len(items)) / float64(df[term]))
+			if idf > 0 {
+				ws = append(ws, weighted{term, float64(n) * idf})
+			}
+		}
+		sort.Slice(ws, func(a, b int) bool {
+			if ws[a].weight != ws[b].weight {
+				return ws[a].weight > ws[b].weight
+			}
+			return ws[a].term < ws[b].term
+		})
+		if len(ws) > maxTerms {
+			ws = ws[:maxTerms]
+		}
+
+		var norm float64
+		for _, w := range ws {
+			norm += w.weight * w.weight
+		}
+		norm = math.Sqrt(norm)
+		vectors[i] = make(map[string]float64, len(ws))
+		for _, w := range ws {
+			vectors[i][w.term] = w.weight / norm
+		}
+	}
+	return vectors
+}
+
+// pairScore accumulates the signals between one book and a candidate
+type pairScore struct {
+	sharedCategories int
+	sharedAuthors    int
+	text             float64
+	coViews          int
+}
+
+// Compute scores every pair of items that share a category, an author, a
+// term or a viewer, and returns the maxSimilar most similar books of each.
+// views lists the books each user viewed, most recent first.
+func Compute(items []Item, views map[int][]int, maxSimilar int) map[int][]Similar {
+	index := make(map[int]int, len(items))
+	for i, item := range items {
+		index[item.BookID] = i
+	}
+	vectors := termVectors(items)
+
+	// Inverted indexes find the candidates without comparing every pair
+	byCategory := map[int][]int{}
+	byAuthor := map[int][]int{}
+	byTerm := map[string][]int{}
+	for i, item := range items {
+		for _, id := range item.Categories {
+			byCategory[id] = append(byCategory[id], i)
+		}
+		for _, id := range item.Authors {
+			byAuthor[id] = append(byAuthor[id], i)
+		}
+		for term := range vectors[i] {
+			byTerm[term] = append(byTerm[term], i)
+		}
+	}
+
+	// Co-views: how many users viewed both books, and each book's viewers
+	viewers := make([]int, len(items))
+	coViews := make([]map[int]int, len(items))
+	for _, books := range views {
+		if len(books) > maxViewsPerUser {
+			books = books[:maxViewsPerUser]
+		}
+		var seen []int
+		for _, id := range books {
+			if i, ok := index[id]; ok {
+				seen = append(seen, i)
+			}
+		}
+		for a, i := range seen {
+			viewers[i]++
+			for _, j := range seen[a+1:] {
+				if coViews[i] == nil {
+					coViews[i] = map[int]int{}
+				}
+				if coViews[j] == nil {
+					coViews[j] = map[int]int{}
+				}
+				coViews[i][j]++
+				coViews[j][i]++
+			}
+		}
+	}
+
+	result := make(map[int][]Similar, len(items))
+	for i, item := range items {
+		candidates := map[int]*pairScore{}
+		get := func(j int) *pairScore {
+			p, ok := candidates[j]
+			if !ok {
+				p = &pairScore{}
+				candidates[j] = p
+			}
+			return p
+		}
+		for _, id := range item.Categories {
+			for _, j := range byCategory[id] {
+				get(j).sharedCategories++
+			}
+		}
+		for _, id := range item.Authors {
+			for _, j := range byAuthor[id] {
+				get(j).sharedAuthors++
+			}
+		}
+		for term, w := range vectors[i] {
+			for _, j := range byTerm[term] {
+				get(j).text += w * vectors[j][term]
+			}
+		}
+		for j, n := range coViews[i] {
+			get(j).coViews = n
+		}
+		delete(candidates, i)
+
+		var similar []Similar
+		for j, p := range candidates {
+			other := items[j]
+			content := categoryWeight*jaccard(p.sharedCategories, len(item.Categories), len(other.Categories)) +
+				authorWeight*jaccard(p.sharedAuthors, len(item.Authors), len(other.Authors)) +
+				textWeight*p.text
+			var coView float64
+			if p.coViews > 0 {
+				coView = float64(p.coViews) / math.Sqrt(float64(viewers[i]*viewers[j]))
+			}
+			if score := contentWeight*content + coViewWeight*coView; score >= minScore {
+				similar = append(similar, Similar{BookID: other.BookID, Score: math.Round(score*10000) / 10000})
+			}
+		}
+		sort.Slice(similar, func(a, b int) bool {
+			if similar[a].Score != similar[b].Score {
+				return similar[a].Score > similar[b].Score
+			}
+			return similar[a].BookID < similar[b].BookID
+		})
+		if len(similar) > maxSimilar {
+			similar = similar[:maxSimilar]
+		}
+		if len(similar) > 0 {
+			result[item.BookID] = similar
+		}
+	}
+	return result
+}
+
+// jaccard is the Jaccard index of two sets of sizes a and b sharing shared members
+func jaccard(shared, a, b int) float64 {
+	if shared == 0 {
+		return 0
+	}
+	return float64(shared) / float64(a+b-shared)
+}
@@ -0,0 +1,186 @@
+package recommend
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestTerms(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"", nil},
+		{"Laskar Pelangi", []string{"laskar", "pelangi"}},
+		// Stop words and words shorter than three letters are left out
+		{"Buku tentang AI dan robot", []string{"tentang", "robot"}},
+		{"The Lord of the Rings", []string{"lord", "rings"}},
+		// Punctuation separates words; digits are kept
+		{"Sejarah-Indonesia, 1945!", []string{"sejarah", "indonesia", "1945"}},
+	}
+	for _, tt := range tests {
+		if got := terms(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("terms(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestTermVectors(t *testing.T) {
+	tests := []struct {
+		name  string
+		items []Item
+		// wantTerms lists the terms kept for each item
+		wantTerms [][]string
+	}{
+		{
+			name:      "empty input",
+			items:     nil,
+			wantTerms: [][]string{},
+		},
+		{
+			name: "terms every book has are dropped",
+			items: []Item{
+				{BookID: 1, Text: "naga merah"},
+				{BookID: 2, Text: "naga biru"},
+			},
+			wantTerms: [][]string{{"merah"}, {"biru"}},
+		},
+		{
+			name: "a book without text has an empty vector",
+			items: []Item{
+				{BookID: 1, Text: "naga"},
+				{BookID: 2, Text: ""},
+			},
+			wantTerms: [][]string{{"naga"}, {}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vectors := termVectors(tt.items)
+			if len(vectors) != len(tt.wantTerms) {
+				t.Fatalf("got %d vectors, want %d", len(vectors), len(tt.wantTerms))
+			}
+			for i, want := range tt.wantTerms {
+				if len(vectors[i]) != len(want) {
+					t.Errorf("vector %d = %v, want terms %q", i, vectors[i], want)
+					continue
+				}
+				for _, term := range want {
+					if _, ok := vectors[i][term]; !ok {
+						t.Errorf("vector %d = %v, missing %q", i, vectors[i], term)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestTermVectorsUnitLengthAndMaxTerms(t *testing.T) {
+	// One long text next to a book sharing none of its words
+	text := ""
+	for i := 0; i < maxTerms+10; i++ {
+		text += " kata" + string(rune('a'+i%26)) + string(rune('a'+i/26))
+	}
+	vectors := termVectors([]Item{{BookID: 1, Text: text}, {BookID: 2, Text: "lain"}})
+
+	if len(vectors[0]) != maxTerms {
+		t.Errorf("kept %d terms, want %d", len(vectors[0]), maxTerms)
+	}
+	var norm float64
+	for _, w := range vectors[0] {
+		norm += w * w
+	}
+	if math.Abs(norm-1) > 1e-9 {
+		t.Errorf("squared norm = %v, want 1", norm)
+	}
+}
+
+func TestCompute(t *testing.T) {
+	tests := []struct {
+		name       string
+		items      []Item
+		views      map[int][]int
+		maxSimilar int
+		want       map[int][]Similar
+	}{
+		{
+			name:       "empty input",
+			maxSimilar: 10,
+			want:       map[int][]Similar{},
+		},
+		{
+			name: "shared category only",
+			items: []Item{
+				{BookID: 1, Categories: []int{1}},
+				{BookID: 2, Categories: []int{1}},
+			},
+			maxSimilar: 10,
+			want: map[int][]Similar{
+				1: {{BookID: 2, Score: 0.2}},
+				2: {{BookID: 1, Score: 0.2}},
+			},
+		},
+		{
+			name: "ranked by combined content similarity",
+			items: []Item{
+				{BookID: 1, Categories: []int{1}, Authors: []int{10}, Text: "naga api"},
+				{BookID: 2, Categories: []int{1}, Authors: []int{10}, Text: "naga api"},
+				{BookID: 3, Categories: []int{1}, Authors: []int{11}, Text: "resep masakan"},
+				{BookID: 4, Categories: []int{2}, Authors: []int{12}, Text: "fisika kuantum"},
+			},
+			maxSimilar: 10,
+			want: map[int][]Similar{
+				1: {{BookID: 2, Score: 0.5}, {BookID: 3, Score: 0.2}},
+				2: {{BookID: 1, Score: 0.5}, {BookID: 3, Score: 0.2}},
+				3: {{BookID: 1, Score: 0.2}, {BookID: 2, Score: 0.2}},
+			},
+		},
+		{
+			name: "co-views relate books without shared content",
+			items: []Item{
+				{BookID: 1},
+				{BookID: 2},
+				{BookID: 3},
+			},
+			// Book 99 is unknown and ignored
+			views:      map[int][]int{7: {1, 2, 99}, 8: {2, 1}, 9: {3}},
+			maxSimilar: 10,
+			want: map[int][]Similar{
+				1: {{BookID: 2, Score: 0.5}},
+				2: {{BookID: 1, Score: 0.5}},
+			},
+		},
+		{
+			name: "truncated to maxSimilar with ties broken by book id",
+			items: []Item{
+				{BookID: 5, Categories: []int{1}},
+				{BookID: 4, Categories: []int{1}},
+				{BookID: 3, Categories: []int{1}},
+				{BookID: 2, Categories: []int{1}},
+			},
+			maxSimilar: 2,
+			want: map[int][]Similar{
+				2: {{BookID: 3, Score: 0.2}, {BookID: 4, Score: 0.2}},
+				3: {{BookID: 2, Score: 0.2}, {BookID: 4, Score: 0.2}},
+				4: {{BookID: 2, Score: 0.2}, {BookID: 3, Score: 0.2}},
+				5: {{BookID: 2, Score: 0.2}, {BookID: 3, Score: 0.2}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Compute(tt.items, tt.views, tt.maxSimilar)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Compute = %v, want %v", got, tt.want)
+			}
+			for id, list := range got {
+				for _, s := range list {
+					if s.BookID == id {
+						t.Errorf("book %d is listed as similar to itself", id)
+					}
+				}
+			}
+		})
+	}
+}
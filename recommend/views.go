@@ -0,0 +1,63 @@
+package recommend
+
+import (
+	"context"
+	"database/sql"
+	"log"
+
+	"pojok_baca_api/database"
+)
+
+// viewQueueSize bounds the views waiting to be written. Views arriving
+// while the queue is full are dropped: they only nudge recommendations.
+const viewQueueSize = 1024
+
+type view struct {
+	userID, bookID int
+}
+
+var views = make(chan view, viewQueueSize)
+
+// RecordView queues a view of a book by a user without waiting for the
+// database. RecordViews writes the queued views.
+func RecordView(userID, bookID int) {
+	select {
+	case views <- view{userID: userID, bookID: bookID}:
+	default:
+		log.Printf("Antrean buku yang dilihat penuh; buku %d yang dilihat pengguna %d tidak dicatat", bookID, userID)
+	}
+}
+
+// RecordViews writes queued views to db until ctx is cancelled, then writes
+// the views still queued and returns
+func RecordViews(ctx context.Context, db *sql.DB) {
+	for {
+		select {
+		case v := <-views:
+			writeView(db, v)
+		case <-ctx.Done():
+			for {
+				select {
+				case v := <-views:
+					writeView(db, v)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// writeView counts one view. Failures are only logged.
+func writeView(db *sql.DB, v view) {
+	ctx, cancel := database.WithTimeout(context.Background())
+	defer cancel()
+
+	_, err := db.ExecContext(ctx,
+		"INSERT INTO user_book_views (user_id, book_id) VALUES (?, ?)"+
+			" ON DUPLICATE KEY UPDATE view_count = view_count + 1, last_viewed_at = CURRENT_TIMESTAMP(6)",
+		v.userID, v.bookID)
+	if err != nil {
+		log.Printf("Gagal mencatat buku %d yang dilihat pengguna %d: %v", v.bookID, v.userID, err)
+	}
+}
@@ -1,54 +1,156 @@
 package routes
 
 import (
+	"pojok_baca_api/config"
+	"pojok_baca_api/database"
+	"pojok_baca_api/enrichment"
 	"pojok_baca_api/handlers"
+	"pojok_baca_api/health"
+	"pojok_baca_api/mailer"
+	"pojok_baca_api/metrics"
+	"pojok_baca_api/models"
+	"pojok_baca_api/tracing"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 )
 
-func SetupRoutes(app *fiber.App) {
-	app.Use(logger.New())
+// probePaths are hit constantly by the orchestrator and the metrics scraper
+// and are kept out of the access log and traces.
+var probePaths = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+	"/version": true,
+	"/metrics": true,
+}
+
+func SetupRoutes(app *fiber.App, cfg *config.Config, mail *mailer.Mailer, enricher *enrichment.Service, checker *health.Checker) {
+	app.Use(tracing.Middleware(func(c *fiber.Ctx) bool {
+		return probePaths[c.Path()]
+	}))
+	app.Use(logger.New(logger.Config{
+		Next: func(c *fiber.Ctx) bool {
+			return probePaths[c.Path()]
+		},
+	}))
+	app.Use(metrics.Middleware())
 
 	// ===================================================================
 	// PENTING: Middleware CORS harus diatur sebelum rute apapun,
 	// termasuk sebelum app.Static()
 	// ===================================================================
 	app.Use(cors.New(cors.Config{
-		AllowOrigins: "*", // Ini mengizinkan semua origin. Untuk produksi, ubah ke origin spesifik.
-		AllowHeaders: "Origin, Content-Type, Accept, Authorization",
-		AllowMethods: "GET, POST, HEAD, PUT, DELETE, PATCH",
+		AllowOrigins:  "*", // Ini mengizinkan semua origin. Untuk produksi, ubah ke origin spesifik.
+		AllowHeaders:  "Origin, Content-Type, Accept, Accept-Language, Authorization, If-Match, If-None-Match, If-Modified-Since, traceparent, tracestate",
+		ExposeHeaders: "ETag, Last-Modified, Content-Language",
+		AllowMethods:  "GET, POST, HEAD, PUT, DELETE, PATCH",
 	}))
 
 	// ===================================================================
 	// PENTING: Tambahkan baris ini untuk melayani file statis (gambar)
 	// Pastikan ini ada dan setelah CORS middleware.
 	// ===================================================================
-	app.Static("/public", "./public") // <--- PASTI BARIS INI ADA!
+	app.Static("/public", cfg.App.PublicDir) // <--- PASTI BARIS INI ADA!
+
+	// --- Health, Build Info & Metrics (tanpa autentikasi, di luar /api/v1) ---
+	app.Get("/healthz", handlers.Healthz)
+	app.Get("/readyz", handlers.Readyz(checker))
+	app.Get("/version", handlers.Version)
+	app.Get("/metrics", metrics.Handler())
 
 	api := app.Group("/api/v1")
 
+	// Query handler dibatalkan jika klien memutus koneksi sebelum selesai
+	api.Use(database.CancelOnDisconnect())
+
+	// Klien yang baru saja mengubah data membaca dari primary, bukan replica
+	api.Use(database.ReadYourWrites(cfg.Database.ReadYourWritesWindow))
+
 	// --- Authentication Routes ---
-	api.Post("/login", handlers.Login)
+	api.Post("/login", handlers.Login(cfg.Auth.SessionTTL))
+	api.Post("/logout", handlers.RequireAuth, handlers.Logout)
 	api.Post("/register", handlers.Register)
 
 	// Route untuk fungsionalitas Lupa Password
-	api.Post("/password-reset/request", handlers.RequestPasswordReset)
+	api.Post("/password-reset/request", handlers.RequestPasswordReset(mail))
 	api.Post("/password-reset/verify", handlers.VerifyResetCode)
 	api.Post("/password-reset/set-new-password", handlers.SetNewPassword)
 
+	// Catalog changes are made by librarians; reading is public. Writes,
+	// imports and metadata lookups without a librarian's bearer token get
+	// 401 (not signed in) or 403 (not a librarian).
+	librarian := handlers.RequireRole(models.RoleLibrarian)
+
 	// --- Book Routes (CRUD) ---
 	api.Get("/books", handlers.GetAllBooks)
-	api.Get("/books/:id", handlers.GetBookByID)
-	api.Post("/books", handlers.CreateBook)
-	api.Put("/books/:id", handlers.UpdateBook)
-	api.Delete("/books/:id", handlers.DeleteBook)
+	// Fixed paths are registered before /books/:id so they are not taken as an id
+	api.Get("/books/export", handlers.ExportBooks)
+	api.Post("/books/import", handlers.RequireAuth, librarian, handlers.ImportBooks)
+	api.Get("/books/isbn/:isbn", handlers.GetBookByISBN)
+	api.Get("/books/enrich", handlers.RequireAuth, librarian, handlers.LookupBookMetadata(enricher))
+	// Signed-in users' views feed the recommendations
+	api.Get("/books/:id", handlers.OptionalAuth, handlers.GetBookByID)
+	api.Get("/books/:id/similar", handlers.GetSimilarBooks)
+	api.Get("/books/:id/reviews", handlers.GetBookReviews)
+	api.Post("/books/:id/reviews", handlers.RequireAuth, handlers.CreateReview)
+	api.Post("/books", handlers.RequireAuth, librarian, handlers.CreateBook(enricher))
+	api.Put("/books/:id", handlers.RequireAuth, librarian, handlers.UpdateBook)
+	api.Patch("/books/:id", handlers.RequireAuth, librarian, handlers.PatchBook)
+	api.Delete("/books/:id", handlers.RequireAuth, librarian, handlers.DeleteBook)
+
+	// --- Review Routes (own reviews; moderation by librarians) ---
+	api.Get("/reviews", handlers.RequireAuth, librarian, handlers.GetReviews)
+	api.Put("/reviews/:id", handlers.RequireAuth, handlers.UpdateReview)
+	api.Delete("/reviews/:id", handlers.RequireAuth, handlers.DeleteReview)
+	api.Put("/reviews/:id/moderation", handlers.RequireAuth, librarian, handlers.ModerateReview)
+
+	// --- Shelf Routes (reading lists and progress of the signed-in user) ---
+	shelves := api.Group("/me/shelves", handlers.RequireAuth, handlers.EnsureDefaultShelves)
+	shelves.Get("/", handlers.GetMyShelves)
+	shelves.Post("/", handlers.CreateShelf)
+	shelves.Get("/:shelf", handlers.GetMyShelf)
+	shelves.Put("/:shelf", handlers.UpdateShelf)
+	shelves.Delete("/:shelf", handlers.DeleteShelf)
+	shelves.Put("/:shelf/books/:book_id", handlers.PutShelfBook)
+	shelves.Delete("/:shelf/books/:book_id", handlers.RemoveShelfBook)
+	// Shared shelves are public
+	api.Get("/shelves/:slug", handlers.GetSharedShelf)
+
+	// --- Recommendation Routes ---
+	api.Get("/me/recommendations", handlers.RequireAuth, handlers.GetMyRecommendations)
+
+	// --- Author Routes (CRUD) ---
+	api.Get("/authors", handlers.GetAllAuthors)
+	api.Get("/authors/:id", handlers.GetAuthorByID)
+	api.Get("/authors/:id/books", handlers.GetAuthorBooks)
+	api.Post("/authors", handlers.RequireAuth, librarian, handlers.CreateAuthor)
+	api.Put("/authors/:id", handlers.RequireAuth, librarian, handlers.UpdateAuthor)
+	api.Patch("/authors/:id", handlers.RequireAuth, librarian, handlers.PatchAuthor)
+	api.Delete("/authors/:id", handlers.RequireAuth, librarian, handlers.DeleteAuthor)
+
+	// --- Publisher Routes (CRUD, deduplication) ---
+	api.Get("/publishers", handlers.GetAllPublishers)
+	// Deduplication is an admin tool for librarians
+	api.Get("/publishers/duplicates", handlers.RequireAuth, librarian, handlers.FindDuplicatePublishers)
+	api.Post("/publishers/merge", handlers.RequireAuth, librarian, handlers.MergePublishers)
+	api.Get("/publishers/:id", handlers.GetPublisherByID)
+	api.Post("/publishers", handlers.RequireAuth, librarian, handlers.CreatePublisher)
+	api.Put("/publishers/:id", handlers.RequireAuth, librarian, handlers.UpdatePublisher)
+	api.Patch("/publishers/:id", handlers.RequireAuth, librarian, handlers.PatchPublisher)
+	api.Delete("/publishers/:id", handlers.RequireAuth, librarian, handlers.DeletePublisher)
+
+	// --- Tag Routes (tag cloud, rename, merge) ---
+	api.Get("/tags", handlers.GetTagCloud)
+	api.Post("/tags/merge", handlers.RequireAuth, librarian, handlers.MergeTags)
+	api.Put("/tags/:id", handlers.RequireAuth, librarian, handlers.RenameTag)
 
 	// --- Category Routes (CRUD) ---
 	api.Get("/categories", handlers.GetAllCategories)
+	api.Get("/categories/tree", handlers.GetCategoryTree)
 	api.Get("/categories/:id", handlers.GetCategoryByID)
-	api.Post("/categories", handlers.CreateCategory)
-	api.Put("/categories/:id", handlers.UpdateCategory)
-	api.Delete("/categories/:id", handlers.DeleteCategory)
+	api.Post("/categories", handlers.RequireAuth, librarian, handlers.CreateCategory)
+	api.Put("/categories/:id", handlers.RequireAuth, librarian, handlers.UpdateCategory)
+	api.Patch("/categories/:id", handlers.RequireAuth, librarian, handlers.PatchCategory)
+	api.Delete("/categories/:id", handlers.RequireAuth, librarian, handlers.DeleteCategory)
 }
\ No newline at end of file
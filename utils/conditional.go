@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// conditional.go
+// This file implements HTTP conditional requests: ETag/Last-Modified
+// validators, 304 Not Modified for GET and If-Match preconditions for
+// optimistic concurrency on PUT/PATCH/DELETE.
+
+// ETag returns a strong entity tag for a JSON representation.
+func ETag(payload []byte) string {
+	sum := sha256.Sum256(payload)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// NotModified sets the ETag (and Last-Modified, unless zero) response
+// headers and reports whether the client's cached copy is still current,
+// in which case the handler should reply with 304. If-None-Match takes
+// precedence over If-Modified-Since.
+func NotModified(c *fiber.Ctx, etag string, lastModified time.Time) bool {
+	c.Set(fiber.HeaderETag, etag)
+	if !lastModified.IsZero() {
+		c.Set(fiber.HeaderLastModified, lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if inm := c.Get(fiber.HeaderIfNoneMatch); inm != "" {
+		return etagListMatches(inm, etag, true)
+	}
+	if ims := c.Get(fiber.HeaderIfModifiedSince); ims != "" && !lastModified.IsZero() {
+		since, err := http.ParseTime(ims)
+		if err != nil {
+			return false
+		}
+		// HTTP dates have one-second precision
+		return !lastModified.Truncate(time.Second).After(since)
+	}
+	return false
+}
+
+// PreconditionFailed reports whether an If-Match header is present and does
+// not match the current ETag. A missing header means no precondition.
+func PreconditionFailed(c *fiber.Ctx, currentETag string) bool {
+	im := c.Get(fiber.HeaderIfMatch)
+	if im == "" {
+		return false
+	}
+	return !etagListMatches(im, currentETag, false)
+}
+
+// HasIfMatch reports whether the request carries an If-Match precondition.
+func HasIfMatch(c *fiber.Ctx) bool {
+	return c.Get(fiber.HeaderIfMatch) != ""
+}
+
+// etagListMatches checks a comma-separated list of entity tags (or "*")
+// against etag. Weak comparison ignores the W/ prefix (If-None-Match);
+// strong comparison never matches a weak tag (If-Match).
+func etagListMatches(list, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if strings.HasPrefix(candidate, "W/") {
+			if !weak {
+				continue
+			}
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
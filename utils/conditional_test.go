@@ -0,0 +1,156 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// serve runs handler for a GET request with the given headers and returns
+// the response
+func serve(t *testing.T, headers map[string]string, handler fiber.Handler) *http.Response {
+	t.Helper()
+	app := fiber.New()
+	app.Get("/", handler)
+	req := httptest.NewRequest("GET", "/", nil)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+func TestETag(t *testing.T) {
+	a := ETag([]byte(`{"book_id":1}`))
+	if len(a) != 34 || a[0] != '"' || a[len(a)-1] != '"' {
+		t.Errorf("ETag = %s, want a quoted 32-digit hex tag", a)
+	}
+	if a != ETag([]byte(`{"book_id":1}`)) {
+		t.Error("ETag is not stable for the same payload")
+	}
+	if a == ETag([]byte(`{"book_id":2}`)) {
+		t.Error("different payloads have the same ETag")
+	}
+}
+
+func TestETagListMatches(t *testing.T) {
+	const etag = `"abc"`
+	tests := []struct {
+		list string
+		weak bool
+		want bool
+	}{
+		{`"abc"`, true, true},
+		{`"abc"`, false, true},
+		{`"xyz"`, true, false},
+		{`"xyz", "abc"`, true, true},
+		{` "xyz" ,"abc" `, false, true},
+		{`*`, false, true},
+		{`*`, true, true},
+		// Weak tags match only with weak comparison
+		{`W/"abc"`, true, true},
+		{`W/"abc"`, false, false},
+		{`W/"abc", "abc"`, false, true},
+		// Unquoted tags are not the same tag
+		{`abc`, true, false},
+		{``, true, false},
+	}
+	for _, tt := range tests {
+		if got := etagListMatches(tt.list, etag, tt.weak); got != tt.want {
+			t.Errorf("etagListMatches(%q, %q, weak=%v) = %v, want %v", tt.list, etag, tt.weak, got, tt.want)
+		}
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	const etag = `"abc"`
+	modified := time.Date(2024, 5, 1, 10, 30, 15, 500_000_000, time.UTC)
+	httpDate := func(t time.Time) string { return t.Format(http.TimeFormat) }
+
+	tests := []struct {
+		name         string
+		headers      map[string]string
+		lastModified time.Time
+		want         bool
+	}{
+		{"no validators", nil, modified, false},
+		{"matching If-None-Match", map[string]string{"If-None-Match": etag}, modified, true},
+		{"weak If-None-Match", map[string]string{"If-None-Match": `W/"abc"`}, modified, true},
+		{"stale If-None-Match", map[string]string{"If-None-Match": `"old"`}, modified, false},
+		{"If-None-Match *", map[string]string{"If-None-Match": "*"}, modified, true},
+		{"same second If-Modified-Since", map[string]string{"If-Modified-Since": httpDate(modified)}, modified, true},
+		{"later If-Modified-Since", map[string]string{"If-Modified-Since": httpDate(modified.Add(time.Hour))}, modified, true},
+		{"earlier If-Modified-Since", map[string]string{"If-Modified-Since": httpDate(modified.Add(-time.Second))}, modified, false},
+		{"malformed If-Modified-Since", map[string]string{"If-Modified-Since": "yesterday"}, modified, false},
+		// Without Last-Modified only the ETag can validate
+		{"If-Modified-Since without Last-Modified", map[string]string{"If-Modified-Since": httpDate(modified)}, time.Time{}, false},
+		// If-None-Match takes precedence over If-Modified-Since
+		{
+			"stale If-None-Match with fresh If-Modified-Since",
+			map[string]string{"If-None-Match": `"old"`, "If-Modified-Since": httpDate(modified)},
+			modified,
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got bool
+			resp := serve(t, tt.headers, func(c *fiber.Ctx) error {
+				got = NotModified(c, etag, tt.lastModified)
+				return nil
+			})
+			if got != tt.want {
+				t.Errorf("NotModified = %v, want %v", got, tt.want)
+			}
+			if resp.Header.Get("ETag") != etag {
+				t.Errorf("ETag header = %q, want %q", resp.Header.Get("ETag"), etag)
+			}
+			wantLastModified := ""
+			if !tt.lastModified.IsZero() {
+				wantLastModified = "Wed, 01 May 2024 10:30:15 GMT"
+			}
+			if got := resp.Header.Get("Last-Modified"); got != wantLastModified {
+				t.Errorf("Last-Modified header = %q, want %q", got, wantLastModified)
+			}
+		})
+	}
+}
+
+func TestPreconditionFailed(t *testing.T) {
+	const etag = `"abc"`
+	tests := []struct {
+		ifMatch    string
+		wantHas    bool
+		wantFailed bool
+	}{
+		{"", false, false},
+		{etag, true, false},
+		{`"old", "abc"`, true, false},
+		{"*", true, false},
+		{`"old"`, true, true},
+		// If-Match uses strong comparison
+		{`W/"abc"`, true, true},
+	}
+	for _, tt := range tests {
+		var has, failed bool
+		headers := map[string]string{}
+		if tt.ifMatch != "" {
+			headers["If-Match"] = tt.ifMatch
+		}
+		serve(t, headers, func(c *fiber.Ctx) error {
+			has = HasIfMatch(c)
+			failed = PreconditionFailed(c, etag)
+			return nil
+		})
+		if has != tt.wantHas || failed != tt.wantFailed {
+			t.Errorf("If-Match %q: HasIfMatch = %v, PreconditionFailed = %v; want %v, %v", tt.ifMatch, has, failed, tt.wantHas, tt.wantFailed)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package utils
+
+import "strings"
+
+// NormalizeISBN strips hyphens and spaces from an ISBN-10 or ISBN-13, checks
+// its check digit and returns it as an ISBN-13. The second result is false
+// when the input is not a valid ISBN.
+func NormalizeISBN(isbn string) (string, bool) {
+	digits := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(isbn)))
+
+	switch len(digits) {
+	case 10:
+		sum := 0
+		for i := 0; i < 10; i++ {
+			var d int
+			switch c := digits[i]; {
+			case c >= '0' && c <= '9':
+				d = int(c - '0')
+			case c == 'X' && i == 9:
+				d = 10
+			default:
+				return "", false
+			}
+			sum += d * (10 - i)
+		}
+		if sum%11 != 0 {
+			return "", false
+		}
+		isbn13 := "978" + digits[:9]
+		return isbn13 + string(isbn13CheckDigit(isbn13)), true
+	case 13:
+		for i := 0; i < 13; i++ {
+			if digits[i] < '0' || digits[i] > '9' {
+				return "", false
+			}
+		}
+		if !strings.HasPrefix(digits, "978") && !strings.HasPrefix(digits, "979") {
+			return "", false
+		}
+		if isbn13CheckDigit(digits[:12]) != digits[12] {
+			return "", false
+		}
+		return digits, true
+	}
+	return "", false
+}
+
+// isbn13CheckDigit computes the check digit of the first 12 digits of an ISBN-13
+func isbn13CheckDigit(digits string) byte {
+	sum := 0
+	for i := 0; i < 12; i++ {
+		d := int(digits[i] - '0')
+		if i%2 == 1 {
+			d *= 3
+		}
+		sum += d
+	}
+	return byte('0' + (10-sum%10)%10)
+}
@@ -0,0 +1,56 @@
+package utils
+
+import "testing"
+
+func TestNormalizeISBN(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		// ISBN-10 converted to ISBN-13
+		{"0-306-40615-2", "9780306406157", true},
+		{"0306406152", "9780306406157", true},
+		{" 0 306 40615 2 ", "9780306406157", true},
+		// X check digit, either case
+		{"0-8044-2957-X", "9780804429573", true},
+		{"080442957x", "9780804429573", true},
+		// ISBN-13
+		{"978-0-306-40615-7", "9780306406157", true},
+		{"9780306406157", "9780306406157", true},
+		{"979-10-90636-07-1", "9791090636071", true},
+
+		// Wrong check digits
+		{"0306406153", "", false},
+		{"9780306406158", "", false},
+		// X anywhere but the check digit of an ISBN-10
+		{"X306406152", "", false},
+		{"978030640615X", "", false},
+		// Not a book prefix
+		{"9770306406155", "", false},
+		// Wrong length or characters
+		{"", "", false},
+		{"12345", "", false},
+		{"97803064061a7", "", false},
+		{"03064061522", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := NormalizeISBN(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("NormalizeISBN(%q) = %q, %v; want %q, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestISBN13CheckDigit(t *testing.T) {
+	for prefix, want := range map[string]byte{
+		"978030640615": '7',
+		"978080442957": '3',
+		"978979224861": '6',
+		"979109063607": '1',
+	} {
+		if got := isbn13CheckDigit(prefix); got != want {
+			t.Errorf("isbn13CheckDigit(%q) = %q, want %q", prefix, got, want)
+		}
+	}
+}
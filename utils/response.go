@@ -1,20 +1,35 @@
 package utils
 
-import "github.com/gofiber/fiber/v2"
+import (
+	"pojok_baca_api/i18n"
+	"pojok_baca_api/tracing"
+
+	"github.com/gofiber/fiber/v2"
+)
 
 // JSONResponse standardizes successful API JSON responses.
-// It takes a Fiber context, HTTP status code, a message string, and data (can be nil).
+// It takes a Fiber context, HTTP status code, a message key, and data (can be nil).
+// The message key is resolved through the i18n catalogs using the request's Accept-Language.
 func JSONResponse(c *fiber.Ctx, statusCode int, message string, data interface{}) error {
+	// Serialization of large payloads (e.g. the book list) shows up as its own span.
+	_, span := tracing.Tracer("http").Start(c.UserContext(), "json.encode")
+	defer span.End()
+
+	locale := i18n.Locale(c)
+	c.Set(fiber.HeaderContentLanguage, locale)
 	return c.Status(statusCode).JSON(fiber.Map{
-		"message": message,
+		"message": i18n.T(locale, message),
 		"data":    data,
 	})
 }
 
 // ErrorResponse standardizes API error responses.
-// It takes a Fiber context, HTTP status code, and an error message string.
-func ErrorResponse(c *fiber.Ctx, statusCode int, message string) error {
+// It takes a Fiber context, HTTP status code, a message key and optional format arguments.
+// The message key is resolved through the i18n catalogs using the request's Accept-Language.
+func ErrorResponse(c *fiber.Ctx, statusCode int, message string, args ...interface{}) error {
+	locale := i18n.Locale(c)
+	c.Set(fiber.HeaderContentLanguage, locale)
 	return c.Status(statusCode).JSON(fiber.Map{
-		"error": message,
+		"error": i18n.T(locale, message, args...),
 	})
-}
\ No newline at end of file
+}
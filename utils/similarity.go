@@ -0,0 +1,35 @@
+package utils
+
+// EditDistance returns the Levenshtein distance between a and b, counted in
+// runes: the number of single-character insertions, deletions and
+// substitutions that turn a into b.
+func EditDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+// NormalizedEditDistance returns EditDistance divided by the length of the
+// longer string: 0 for equal strings, 1 for strings with nothing in common.
+func NormalizedEditDistance(a, b string) float64 {
+	longest := max(len([]rune(a)), len([]rune(b)))
+	if longest == 0 {
+		return 0
+	}
+	return float64(EditDistance(a, b)) / float64(longest)
+}
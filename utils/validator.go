@@ -0,0 +1,153 @@
+package utils
+
+import (
+	"errors"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"pojok_baca_api/i18n"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+// validator.go
+// This file wires struct-tag based request validation (`validate:"..."`)
+// so handlers can check a whole request body at once instead of
+// hand-rolling emptiness checks for each field.
+
+// FieldError describes a single field that failed validation.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+
+	kind reflect.Kind // kind of the failing value, used to pick the message wording
+}
+
+// nimPattern matches a student number (NIM): 8 to 20 digits.
+var nimPattern = regexp.MustCompile(`^[0-9]{8,20}$`)
+
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+
+	// Report fields by their JSON name so clients can map errors back to inputs.
+	v.RegisterTagNameFunc(func(fld reflect.StructField) string {
+		name := strings.SplitN(fld.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return fld.Name
+		}
+		return name
+	})
+
+	v.RegisterValidation("nim", func(fl validator.FieldLevel) bool {
+		return nimPattern.MatchString(fl.Field().String())
+	})
+	v.RegisterValidation("strongpassword", func(fl validator.FieldLevel) bool {
+		return IsStrongPassword(fl.Field().String())
+	})
+	v.RegisterValidation("notfutureyear", func(fl validator.FieldLevel) bool {
+		return fl.Field().Int() <= int64(time.Now().Year())
+	})
+	// Replaces the built-in isbn rule: also accepts hyphens and checks the prefix
+	v.RegisterValidation("isbn", func(fl validator.FieldLevel) bool {
+		_, ok := NormalizeISBN(fl.Field().String())
+		return ok
+	})
+
+	return v
+}
+
+// IsStrongPassword reports whether a password has at least 8 characters
+// and contains an uppercase letter, a lowercase letter and a digit.
+func IsStrongPassword(password string) bool {
+	if len([]rune(password)) < 8 {
+		return false
+	}
+	var hasUpper, hasLower, hasDigit bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		}
+	}
+	return hasUpper && hasLower && hasDigit
+}
+
+// ValidateStruct validates s against its `validate` tags and returns every
+// failing field. It returns nil when s is valid.
+func ValidateStruct(s interface{}) []FieldError {
+	err := validate.Struct(s)
+	if err == nil {
+		return nil
+	}
+
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return []FieldError{{Rule: "invalid", Message: err.Error()}}
+	}
+
+	fieldErrors := make([]FieldError, 0, len(validationErrors))
+	for _, fe := range validationErrors {
+		fieldErrors = append(fieldErrors, FieldError{
+			Field: fe.Field(),
+			Rule:  fe.Tag(),
+			Param: fe.Param(),
+			kind:  fe.Kind(),
+		})
+	}
+	return fieldErrors
+}
+
+// fieldErrorMessage builds a human readable message for a failed rule in the given locale.
+func fieldErrorMessage(locale string, fe FieldError) string {
+	switch fe.Rule {
+	case "required", "email", "nim", "strongpassword", "notfutureyear", "numeric", "integer", "exists", "isbn", "unique", "acyclic", "datetime", "notbeforestart":
+		return i18n.T(locale, "validation."+fe.Rule, fe.Field)
+	case "max", "min":
+		if fe.kind == reflect.String {
+			return i18n.T(locale, "validation."+fe.Rule+"_string", fe.Field, fe.Param)
+		}
+		return i18n.T(locale, "validation."+fe.Rule, fe.Field, fe.Param)
+	case "gt", "len":
+		return i18n.T(locale, "validation."+fe.Rule, fe.Field, fe.Param)
+	default:
+		return i18n.T(locale, "validation.invalid", fe.Field, fe.Rule)
+	}
+}
+
+// LocalizeFieldErrors fills in the message of every field error in the
+// request's negotiated language.
+func LocalizeFieldErrors(c *fiber.Ctx, fieldErrors []FieldError) {
+	locale := i18n.Locale(c)
+	for i := range fieldErrors {
+		if fieldErrors[i].Message == "" {
+			fieldErrors[i].Message = fieldErrorMessage(locale, fieldErrors[i])
+		}
+	}
+}
+
+// ValidationErrorResponse writes a 422 response listing every failing field,
+// with messages in the request's negotiated language.
+func ValidationErrorResponse(c *fiber.Ctx, fieldErrors []FieldError) error {
+	locale := i18n.Locale(c)
+	LocalizeFieldErrors(c, fieldErrors)
+	c.Set(fiber.HeaderContentLanguage, locale)
+	return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+		"error":  i18n.T(locale, "validation.failed"),
+		"errors": fieldErrors,
+	})
+}
@@ -0,0 +1,140 @@
+package utils
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestIsStrongPassword(t *testing.T) {
+	tests := []struct {
+		password string
+		want     bool
+	}{
+		{"Rahasia123", true},
+		{"Abcdefg1", true},
+		// Letters outside ASCII count too
+		{"Ĉapitalé9", true},
+		// Too short
+		{"Abcdef1", false},
+		{"", false},
+		// Missing a character class
+		{"rahasia123", false},
+		{"RAHASIA123", false},
+		{"RahasiaSekali", false},
+		// Eight characters counted as runes, not bytes
+		{"Éé1ééé", false},
+	}
+	for _, tt := range tests {
+		if got := IsStrongPassword(tt.password); got != tt.want {
+			t.Errorf("IsStrongPassword(%q) = %v, want %v", tt.password, got, tt.want)
+		}
+	}
+}
+
+func TestValidateStructCustomRules(t *testing.T) {
+	type request struct {
+		NIM      string `json:"nim" validate:"omitempty,nim"`
+		Password string `json:"password" validate:"omitempty,strongpassword"`
+		Year     int    `json:"tahun_terbit" validate:"omitempty,notfutureyear"`
+		ISBN     string `json:"isbn" validate:"omitempty,isbn"`
+	}
+	thisYear := time.Now().Year()
+
+	tests := []struct {
+		name string
+		req  request
+		// want lists the failing fields and their rules
+		want []FieldError
+	}{
+		{"empty", request{}, nil},
+		{"all valid", request{NIM: "12345678", Password: "Rahasia123", Year: thisYear, ISBN: "978-0-306-40615-7"}, nil},
+		{"longest nim", request{NIM: "12345678901234567890"}, nil},
+		{"isbn-10 with x", request{ISBN: "0-8044-2957-X"}, nil},
+		{"short nim", request{NIM: "1234567"}, []FieldError{{Field: "nim", Rule: "nim"}}},
+		{"long nim", request{NIM: "123456789012345678901"}, []FieldError{{Field: "nim", Rule: "nim"}}},
+		{"nim with letters", request{NIM: "1234567a"}, []FieldError{{Field: "nim", Rule: "nim"}}},
+		{"weak password", request{Password: "rahasia123"}, []FieldError{{Field: "password", Rule: "strongpassword"}}},
+		{"next year", request{Year: thisYear + 1}, []FieldError{{Field: "tahun_terbit", Rule: "notfutureyear"}}},
+		{"bad isbn checksum", request{ISBN: "9780306406158"}, []FieldError{{Field: "isbn", Rule: "isbn"}}},
+		{
+			"every field fails",
+			request{NIM: "x", Password: "x", Year: thisYear + 1, ISBN: "x"},
+			[]FieldError{
+				{Field: "nim", Rule: "nim"},
+				{Field: "password", Rule: "strongpassword"},
+				{Field: "tahun_terbit", Rule: "notfutureyear"},
+				{Field: "isbn", Rule: "isbn"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []FieldError
+			for _, fe := range ValidateStruct(&tt.req) {
+				got = append(got, FieldError{Field: fe.Field, Rule: fe.Rule, Param: fe.Param})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ValidateStruct = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateStructReportsParamsAndJSONNames(t *testing.T) {
+	type request struct {
+		Judul  string `json:"judul" validate:"required,max=5"`
+		Hidden string `json:"-" validate:"required"`
+		NoTag  string `validate:"required"`
+	}
+	got := ValidateStruct(&request{Judul: "terlalu panjang"})
+	want := []FieldError{
+		{Field: "judul", Rule: "max", Param: "5"},
+		// Fields hidden from JSON are reported by their Go name
+		{Field: "Hidden", Rule: "required"},
+		{Field: "NoTag", Rule: "required"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("ValidateStruct = %+v, want %d errors", got, len(want))
+	}
+	for i := range want {
+		if got[i].Field != want[i].Field || got[i].Rule != want[i].Rule || got[i].Param != want[i].Param {
+			t.Errorf("error %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestFieldErrorMessage(t *testing.T) {
+	type request struct {
+		Judul string `json:"judul" validate:"max=3"`
+		Tahun int    `json:"tahun" validate:"max=3"`
+	}
+	errs := ValidateStruct(&request{Judul: "panjang", Tahun: 4})
+	if len(errs) != 2 {
+		t.Fatalf("ValidateStruct = %+v, want 2 errors", errs)
+	}
+
+	tests := []struct {
+		locale string
+		fe     FieldError
+		want   string
+	}{
+		{"en", FieldError{Field: "nim", Rule: "nim"}, "nim must consist of 8 to 20 digits"},
+		{"en", FieldError{Field: "tahun_terbit", Rule: "notfutureyear"}, "tahun_terbit must not be later than the current year"},
+		{"en", FieldError{Field: "isbn", Rule: "isbn"}, "isbn must be a valid ISBN-10 or ISBN-13"},
+		// Strings are measured in characters, numbers by value
+		{"en", errs[0], "judul must be at most 3 characters"},
+		{"en", errs[1], "tahun must be at most 3"},
+		{"en", FieldError{Field: "kode", Rule: "len", Param: "6"}, "kode must be exactly 6 characters"},
+		// Rules without a message of their own
+		{"en", FieldError{Field: "url", Rule: "url"}, "url is invalid (url)"},
+	}
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			if got := fieldErrorMessage(tt.locale, tt.fe); got != tt.want {
+				t.Errorf("fieldErrorMessage(%q, %+v) = %q, want %q", tt.locale, tt.fe, got, tt.want)
+			}
+		})
+	}
+}
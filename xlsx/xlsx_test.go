@@ -0,0 +1,156 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestColumnName(t *testing.T) {
+	tests := []struct {
+		i    int
+		want string
+	}{
+		{0, "A"},
+		{1, "B"},
+		{25, "Z"},
+		{26, "AA"},
+		{27, "AB"},
+		{51, "AZ"},
+		{52, "BA"},
+		{701, "ZZ"},
+		{702, "AAA"},
+	}
+	for _, tt := range tests {
+		if got := columnName(tt.i); got != tt.want {
+			t.Errorf("columnName(%d) = %q, want %q", tt.i, got, tt.want)
+		}
+	}
+}
+
+// sheet is the part of a worksheet the tests read back
+type sheet struct {
+	Rows []struct {
+		R     int `xml:"r,attr"`
+		Cells []struct {
+			Ref    string `xml:"r,attr"`
+			Type   string `xml:"t,attr"`
+			Value  string `xml:"v"`
+			Inline string `xml:"is>t"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// readParts unzips a workbook into its parts
+func readParts(t *testing.T, data []byte) map[string][]byte {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("not a zip archive: %v", err)
+	}
+	parts := map[string][]byte{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts[f.Name] = body
+	}
+	return parts
+}
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, "Buku & <Katalog>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := [][]interface{}{
+		{"book_id", "judul", "rating"},
+		{1, "Laskar Pelangi", 4.5},
+		{int64(2), "Tom & Jerry <2>", nil},
+	}
+	for _, row := range rows {
+		if err := w.WriteRow(row...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	parts := readParts(t, buf.Bytes())
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/worksheets/sheet1.xml"} {
+		if _, ok := parts[name]; !ok {
+			t.Errorf("missing part %s", name)
+		}
+	}
+
+	var wb struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := xml.Unmarshal(parts["xl/workbook.xml"], &wb); err != nil {
+		t.Fatalf("workbook.xml: %v", err)
+	}
+	if len(wb.Sheets) != 1 || wb.Sheets[0].Name != "Buku & <Katalog>" {
+		t.Errorf("sheets = %+v, want one named %q", wb.Sheets, "Buku & <Katalog>")
+	}
+
+	var s sheet
+	if err := xml.Unmarshal(parts["xl/worksheets/sheet1.xml"], &s); err != nil {
+		t.Fatalf("sheet1.xml: %v", err)
+	}
+	type cell struct{ ref, typ, value string }
+	var got [][]cell
+	for i, row := range s.Rows {
+		if row.R != i+1 {
+			t.Errorf("row %d has r=%d", i+1, row.R)
+		}
+		var cells []cell
+		for _, c := range row.Cells {
+			value := c.Value
+			if c.Type == "inlineStr" {
+				value = c.Inline
+			}
+			cells = append(cells, cell{c.Ref, c.Type, value})
+		}
+		got = append(got, cells)
+	}
+	want := [][]cell{
+		{{"A1", "inlineStr", "book_id"}, {"B1", "inlineStr", "judul"}, {"C1", "inlineStr", "rating"}},
+		{{"A2", "", "1"}, {"B2", "inlineStr", "Laskar Pelangi"}, {"C2", "", "4.5"}},
+		// Anything that is not a number is written as text
+		{{"A3", "", "2"}, {"B3", "inlineStr", "Tom & Jerry <2>"}, {"C3", "inlineStr", "<nil>"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("cells = %v, want %v", got, want)
+	}
+}
+
+func TestWriterEmptySheet(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, "Kosong")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	var s sheet
+	if err := xml.Unmarshal(readParts(t, buf.Bytes())["xl/worksheets/sheet1.xml"], &s); err != nil {
+		t.Fatalf("sheet1.xml: %v", err)
+	}
+	if len(s.Rows) != 0 {
+		t.Errorf("empty sheet has %d rows", len(s.Rows))
+	}
+}